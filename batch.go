@@ -0,0 +1,435 @@
+package imagedraw
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Batch 根据数据行批量渲染图片 如证书、胸牌
+//
+// 文本、图片地址和文件名中的{列名}会被替换为每一行中对应列的值
+// 基础图片、字体和不含变量的图片只会加载一次 所有行共用
+type Batch struct {
+	base     *Image
+	items    []batchItem
+	fileName string
+	workers  int
+	loader   TemplateLoader
+
+	mu     sync.Mutex
+	images map[string]*Image
+}
+
+// BatchReport 批量渲染的结果
+type BatchReport struct {
+	//总行数
+	Total int
+	//成功的行数
+	Succeeded int
+	//失败的行 按行号排序
+	Failed []BatchRowError
+}
+
+// BatchRowError 渲染失败的行
+type BatchRowError struct {
+	//行号 从1开始 不包含csv的表头
+	Row int
+	//输出文件 文件名无法生成时为空
+	File string
+	Err  error
+}
+
+func (e BatchRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+//批量渲染中的元素 每一行生成一个FillItem
+type batchItem interface {
+	item(b *Batch, row map[string]string) (FillItem, error)
+}
+
+// NewBatch 创建批量渲染 base为基础图片 fileName为输出文件名 如out/{id}.png 扩展名为png、jpg或jpeg
+func NewBatch(base *Image, fileName string) *Batch {
+	return &Batch{
+		base:     base,
+		fileName: fileName,
+		workers:  runtime.GOMAXPROCS(0),
+		loader:   FileLoader{},
+		images:   make(map[string]*Image),
+	}
+}
+
+// SetWorkers 设置同时渲染的数量 默认CPU核数
+func (b *Batch) SetWorkers(n int) *Batch {
+	if n < 1 {
+		n = 1
+	}
+	b.workers = n
+	return b
+}
+
+// SetLoader 设置图片的加载方式 默认从本地路径或者http链接加载
+func (b *Batch) SetLoader(loader TemplateLoader) *Batch {
+	b.loader = loader
+	return b
+}
+
+// AddText 添加文本 文本和超出提示符中的{列名}会被替换 每一行共用t的字体
+func (b *Batch) AddText(t *Text) *Batch {
+	b.items = append(b.items, batchText{t})
+	return b
+}
+
+// AddImage 添加图片 src中的{列名}会被替换 p为加载后执行的操作 可以为nil
+func (b *Batch) AddImage(src string, p *Pipeline, x, y, w, h int) *Batch {
+	b.items = append(b.items, batchImage{
+		src:      src,
+		pipeline: p,
+		area:     image.Rect(x, y, x+w, y+h),
+	})
+	return b
+}
+
+// AddItem 添加一个每一行都相同的元素
+func (b *Batch) AddItem(item FillItem) *Batch {
+	b.items = append(b.items, batchStatic{item})
+	return b
+}
+
+type batchText struct {
+	text *Text
+}
+
+func (t batchText) item(b *Batch, row map[string]string) (FillItem, error) {
+	text := t.text.Copy()
+	if text.lines != nil {
+		lines := make([]string, len(text.lines))
+		for i, line := range text.lines {
+			s, err := expandColumns(line, row)
+			if err != nil {
+				return nil, err
+			}
+			lines[i] = s
+		}
+		text.lines = lines
+	} else {
+		s, err := expandColumns(text.s, row)
+		if err != nil {
+			return nil, err
+		}
+		text.s = s
+	}
+	outStr, err := expandColumns(text.outStr, row)
+	if err != nil {
+		return nil, err
+	}
+	text.outStr = outStr
+	return text, nil
+}
+
+type batchImage struct {
+	src      string
+	pipeline *Pipeline
+	area     image.Rectangle
+}
+
+func (i batchImage) item(b *Batch, row map[string]string) (FillItem, error) {
+	src, err := expandColumns(i.src, row)
+	if err != nil {
+		return nil, err
+	}
+	//含有{列名}的图片每一行都不同 不缓存 避免所有行的图片都留在内存中
+	img, err := b.loadImage(src, i.pipeline, !batchColumnRegexp.MatchString(i.src))
+	if err != nil {
+		return nil, err
+	}
+	//共用的图片不能修改 复制一份设置绘制区域
+	item := *img
+	item.area = i.area
+	return &item, nil
+}
+
+type batchStatic struct {
+	fillItem FillItem
+}
+
+func (s batchStatic) item(b *Batch, row map[string]string) (FillItem, error) {
+	return s.fillItem, nil
+}
+
+//加载图片 cache为true时只加载一次 所有行共用
+func (b *Batch) loadImage(src string, p *Pipeline, cache bool) (*Image, error) {
+	key := src
+	if p != nil {
+		key += "|" + p.String()
+	}
+	if cache {
+		b.mu.Lock()
+		img, ok := b.images[key]
+		b.mu.Unlock()
+		if ok {
+			return img, nil
+		}
+	}
+	img, err := b.loader.LoadImage(src)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		img = p.Apply(img)
+	}
+	if cache {
+		b.mu.Lock()
+		b.images[key] = img
+		b.mu.Unlock()
+	}
+	return img, nil
+}
+
+var batchColumnRegexp = regexp.MustCompile(`\{([^{}]+)\}`)
+
+//将字符串中的{列名}替换为列的值
+func expandColumns(s string, row map[string]string) (string, error) {
+	var err error
+	result := batchColumnRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		name := match[1 : len(match)-1]
+		value, ok := row[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown column %q", name)
+		}
+		return value
+	})
+	return result, err
+}
+
+//生成文件名 列的值中的路径分隔符会被替换 防止写到输出目录之外
+func (b *Batch) outputFile(row map[string]string) (string, error) {
+	safe := make(map[string]string, len(row))
+	for k, v := range row {
+		v = strings.NewReplacer("/", "_", "\\", "_").Replace(v)
+		if v == "." || v == ".." {
+			v = "_"
+		}
+		safe[k] = v
+	}
+	return expandColumns(b.fileName, safe)
+}
+
+//检查文件名的扩展名 扩展名中含有列时在生成每一行的文件名之后检查
+func (b *Batch) checkFileName() error {
+	if strings.ContainsAny(filepath.Ext(b.fileName), "{}") {
+		return nil
+	}
+	if _, err := imageExt(b.fileName); err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	return nil
+}
+
+// Run 渲染每一行 文件名不合法时每一行都失败
+func (b *Batch) Run(rows []map[string]string) *BatchReport {
+	total := len(rows)
+	report, err := b.RunContext(context.Background(), func() (map[string]string, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	})
+	if err != nil {
+		//只有文件名不合法时返回错误 此时没有读取任何行
+		report = &BatchReport{Total: total}
+		for i := 0; i < total; i++ {
+			report.Failed = append(report.Failed, BatchRowError{Row: i + 1, Err: err})
+		}
+	}
+	return report
+}
+
+// RunCSV 渲染csv的每一行 第一行为列名 无法解析的行记为失败 继续渲染后面的行
+func (b *Batch) RunCSV(reader io.Reader) (*BatchReport, error) {
+	if err := b.checkFileName(); err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(reader)
+	//列数不同的行缺少的列在替换时报错
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("batch: read csv header: %w", err)
+	}
+	return b.RunContext(context.Background(), func() (map[string]string, error) {
+		record, err := r.Read()
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				return nil, BatchRowError{Err: err}
+			}
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		return row, nil
+	})
+}
+
+// RunJSONLines 渲染每一行json对象 空行会被跳过 无法解析的行记为失败 继续渲染后面的行
+func (b *Batch) RunJSONLines(reader io.Reader) (*BatchReport, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	return b.RunContext(context.Background(), func() (map[string]string, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var values map[string]interface{}
+			decoder := json.NewDecoder(strings.NewReader(text))
+			decoder.UseNumber()
+			if err := decoder.Decode(&values); err != nil {
+				return nil, BatchRowError{Err: fmt.Errorf("line %d: %w", line, err)}
+			}
+			row := make(map[string]string, len(values))
+			for k, v := range values {
+				if v == nil {
+					row[k] = ""
+				} else {
+					row[k] = fmt.Sprint(v)
+				}
+			}
+			return row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	})
+}
+
+// RunContext 使用next依次读取每一行并渲染 next返回io.EOF时结束
+// 返回BatchRowError时该行记为失败 继续读取下一行 返回其他错误时停止读取并返回该错误
+//
+// 文件名的扩展名不合法时不读取任何行 返回nil和错误
+func (b *Batch) RunContext(ctx context.Context, next func() (map[string]string, error)) (*BatchReport, error) {
+	if err := b.checkFileName(); err != nil {
+		return nil, err
+	}
+	type job struct {
+		n   int
+		row map[string]string
+	}
+	jobs := make(chan job)
+	results := make(chan BatchRowError)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				file, err := b.render(ctx, j.row)
+				results <- BatchRowError{Row: j.n, File: file, Err: err}
+			}
+		}()
+	}
+
+	var readErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for n := 1; ; n++ {
+			row, err := next()
+			if rowErr, ok := err.(BatchRowError); ok {
+				rowErr.Row = n
+				select {
+				case results <- rowErr:
+					continue
+				case <-ctx.Done():
+					readErr = ctx.Err()
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+			select {
+			case jobs <- job{n: n, row: row}:
+			case <-ctx.Done():
+				readErr = ctx.Err()
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	report := &BatchReport{}
+	for result := range results {
+		report.Total++
+		if result.Err != nil {
+			report.Failed = append(report.Failed, result)
+		} else {
+			report.Succeeded++
+		}
+	}
+	sort.Slice(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Row < report.Failed[j].Row
+	})
+	return report, readErr
+}
+
+//渲染一行并保存 返回输出文件
+func (b *Batch) render(ctx context.Context, row map[string]string) (string, error) {
+	file, err := b.outputFile(row)
+	if err != nil {
+		return "", err
+	}
+	if _, err = imageExt(file); err != nil {
+		return file, err
+	}
+	items := make([]FillItem, 0, len(b.items))
+	for _, bi := range b.items {
+		item, err := bi.item(b, row)
+		if err != nil {
+			return file, err
+		}
+		items = append(items, item)
+	}
+
+	dst := b.base.Copy()
+	rc := NewRenderContext(dst.img)
+	rc.Context = ctx
+	if _, err = dst.FillContext(rc, items...); err != nil {
+		return file, err
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return file, err
+		}
+	}
+	return file, dst.SaveAs(file)
+}
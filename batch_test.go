@@ -0,0 +1,128 @@
+package imagedraw
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//记录每个地址加载次数的loader
+type countingLoader struct {
+	mu    sync.Mutex
+	loads map[string]int
+}
+
+func (l *countingLoader) LoadImage(src string) (*Image, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads[src]++
+	return NewBaseImage(4, 4), nil
+}
+
+func (l *countingLoader) LoadFont(name string) (IDrawString, error) {
+	return nil, errors.New("no fonts")
+}
+
+func TestBatchCachesOnlyStaticImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	loader := &countingLoader{loads: map[string]int{}}
+	b := NewBatch(NewBaseImage(20, 20), filepath.Join(dir, "{id}.png")).
+		SetLoader(loader).
+		SetWorkers(2).
+		AddImage("logo.png", nil, 0, 0, 4, 4).
+		AddImage("{photo}", nil, 4, 4, 4, 4)
+	rows := []map[string]string{
+		{"id": "1", "photo": "a.png"},
+		{"id": "2", "photo": "b.png"},
+		{"id": "3", "photo": "a.png"},
+	}
+	report := b.Run(rows)
+	if report.Succeeded != len(rows) {
+		t.Fatalf("got %d succeeded, failed %v", report.Succeeded, report.Failed)
+	}
+	if loader.loads["logo.png"] != 1 {
+		t.Errorf("static image loaded %d times, want 1", loader.loads["logo.png"])
+	}
+	if loader.loads["a.png"] != 2 || loader.loads["b.png"] != 1 {
+		t.Errorf("per-row images loaded %v, want every row to load its own image", loader.loads)
+	}
+	if len(b.images) != 1 {
+		t.Errorf("cached %d images, want only the static one", len(b.images))
+	}
+}
+
+func TestBatchRejectsBadFileName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rows := []map[string]string{{"id": "1", "ext": "gif"}, {"id": "2", "ext": "png"}}
+	for _, name := range []string{"{id}", "{id}.gif", "{id}."} {
+		b := NewBatch(NewBaseImage(4, 4), filepath.Join(dir, name))
+		if _, err := b.RunJSONLines(strings.NewReader(`{"id": 1}`)); err == nil {
+			t.Errorf("%s: RunJSONLines got no error", name)
+		}
+		report := b.Run(rows)
+		if report.Total != len(rows) || len(report.Failed) != len(rows) {
+			t.Errorf("%s: got %d failed of %d, want every row to fail", name, len(report.Failed), report.Total)
+		}
+	}
+
+	//扩展名来自列时每一行单独检查
+	report := NewBatch(NewBaseImage(4, 4), filepath.Join(dir, "{id}.{ext}")).Run(rows)
+	if report.Succeeded != 1 || len(report.Failed) != 1 || report.Failed[0].Row != 1 {
+		t.Fatalf("got %d succeeded, failed %v", report.Succeeded, report.Failed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1.gif")); !os.IsNotExist(err) {
+		t.Errorf("file with an unsupported extension was created: %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "2.png" {
+		t.Errorf("got files %v, want only 2.png", files)
+	}
+}
+
+//无法解析的行记为失败 不影响其他行
+func TestBatchSkipsBadRows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := NewBatch(NewBaseImage(4, 4), filepath.Join(dir, "{id}.png"))
+	csvInput := "id,name\n1,a\n2\n3,b\"c\n4,d\n"
+	report, err := b.RunCSV(strings.NewReader(csvInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 4 || report.Succeeded != 3 || len(report.Failed) != 1 || report.Failed[0].Row != 3 {
+		t.Errorf("csv: got %d succeeded of %d, failed %v, want row 3 to fail", report.Succeeded, report.Total, report.Failed)
+	}
+
+	jsonInput := "{\"id\": 5}\n{\"id\": \n\n{\"id\": 6}\n"
+	report, err = b.RunJSONLines(strings.NewReader(jsonInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Succeeded != 2 || len(report.Failed) != 1 || report.Failed[0].Row != 2 {
+		t.Errorf("json: got %d succeeded of %d, failed %v, want row 2 to fail", report.Succeeded, report.Total, report.Failed)
+	}
+	if len(report.Failed) == 1 && !strings.Contains(report.Failed[0].Error(), "line 2") {
+		t.Errorf("json: got error %v, want it to name line 2", report.Failed[0])
+	}
+}
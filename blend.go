@@ -0,0 +1,343 @@
+package imagedraw
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+)
+
+// BlendMode 绘制到另外一张图片上时的混合模式 与W3C Compositing and Blending规范相同 混合后按source-over合成
+type BlendMode int
+
+const (
+	//正常 与draw.Over相同
+	BlendNormal BlendMode = iota
+	//正片叠底
+	BlendMultiply BlendMode = iota
+	//滤色
+	BlendScreen BlendMode = iota
+	//叠加
+	BlendOverlay BlendMode = iota
+	//变暗
+	BlendDarken BlendMode = iota
+	//变亮
+	BlendLighten BlendMode = iota
+	//颜色减淡
+	BlendColorDodge BlendMode = iota
+	//颜色加深
+	BlendColorBurn BlendMode = iota
+	//强光
+	BlendHardLight BlendMode = iota
+	//柔光
+	BlendSoftLight BlendMode = iota
+	//差值
+	BlendDifference BlendMode = iota
+	//排除
+	BlendExclusion BlendMode = iota
+	//色相 使用上层的色相和下层的饱和度、亮度
+	BlendHue BlendMode = iota
+	//饱和度 使用上层的饱和度和下层的色相、亮度
+	BlendSaturation BlendMode = iota
+	//颜色 使用上层的色相、饱和度和下层的亮度
+	BlendColor BlendMode = iota
+	//明度 使用上层的亮度和下层的色相、饱和度
+	BlendLuminosity BlendMode = iota
+)
+
+//混合模式的名称 与css的mix-blend-mode相同
+var blendModeNames = map[string]BlendMode{
+	"normal":      BlendNormal,
+	"multiply":    BlendMultiply,
+	"screen":      BlendScreen,
+	"overlay":     BlendOverlay,
+	"darken":      BlendDarken,
+	"lighten":     BlendLighten,
+	"color-dodge": BlendColorDodge,
+	"color-burn":  BlendColorBurn,
+	"hard-light":  BlendHardLight,
+	"soft-light":  BlendSoftLight,
+	"difference":  BlendDifference,
+	"exclusion":   BlendExclusion,
+	"hue":         BlendHue,
+	"saturation":  BlendSaturation,
+	"color":       BlendColor,
+	"luminosity":  BlendLuminosity,
+}
+
+// ParseBlendMode 根据名称返回混合模式 名称与css的mix-blend-mode相同 如multiply soft-light
+func ParseBlendMode(name string) (BlendMode, error) {
+	if m, ok := blendModeNames[strings.ToLower(name)]; ok {
+		return m, nil
+	}
+	return 0, fmt.Errorf("unknown blend mode %q", name)
+}
+
+//可以设置混合模式的元素
+type blendItem interface {
+	blendMode() BlendMode
+}
+
+//可分离的混合函数 cb为下层 cs为上层 都是未预乘的0到1
+func blendChannel(mode BlendMode, cb, cs float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return cb * cs
+	case BlendScreen:
+		return cb + cs - cb*cs
+	case BlendOverlay:
+		return blendChannel(BlendHardLight, cs, cb)
+	case BlendDarken:
+		return math.Min(cb, cs)
+	case BlendLighten:
+		return math.Max(cb, cs)
+	case BlendColorDodge:
+		if cb == 0 {
+			return 0
+		}
+		if cs >= 1 {
+			return 1
+		}
+		return math.Min(1, cb/(1-cs))
+	case BlendColorBurn:
+		if cb >= 1 {
+			return 1
+		}
+		if cs <= 0 {
+			return 0
+		}
+		return 1 - math.Min(1, (1-cb)/cs)
+	case BlendHardLight:
+		if cs <= 0.5 {
+			return cb * 2 * cs
+		}
+		return blendChannel(BlendScreen, cb, 2*cs-1)
+	case BlendSoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		var d float64
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		} else {
+			d = math.Sqrt(cb)
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case BlendDifference:
+		return math.Abs(cb - cs)
+	case BlendExclusion:
+		return cb + cs - 2*cb*cs
+	}
+	return cs
+}
+
+//未预乘的rgb 0到1
+type blendColor [3]float64
+
+func (c blendColor) lum() float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+func (c blendColor) sat() float64 {
+	return math.Max(c[0], math.Max(c[1], c[2])) - math.Min(c[0], math.Min(c[1], c[2]))
+}
+
+//将超出0到1的颜色按亮度收回
+func (c blendColor) clip() blendColor {
+	l := c.lum()
+	n := math.Min(c[0], math.Min(c[1], c[2]))
+	x := math.Max(c[0], math.Max(c[1], c[2]))
+	for k := range c {
+		if n < 0 {
+			c[k] = l + (c[k]-l)*l/(l-n)
+		}
+		if x > 1 {
+			c[k] = l + (c[k]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
+
+func (c blendColor) setLum(l float64) blendColor {
+	d := l - c.lum()
+	return blendColor{c[0] + d, c[1] + d, c[2] + d}.clip()
+}
+
+func (c blendColor) setSat(s float64) blendColor {
+	//最大、中间、最小分量的下标
+	maxK, midK, minK := 0, 1, 2
+	if c[maxK] < c[midK] {
+		maxK, midK = midK, maxK
+	}
+	if c[midK] < c[minK] {
+		midK, minK = minK, midK
+	}
+	if c[maxK] < c[midK] {
+		maxK, midK = midK, maxK
+	}
+	var r blendColor
+	if c[maxK] > c[minK] {
+		r[midK] = (c[midK] - c[minK]) * s / (c[maxK] - c[minK])
+		r[maxK] = s
+	}
+	return r
+}
+
+//混合后的颜色 cb为下层 cs为上层
+func blendPixel(mode BlendMode, cb, cs blendColor) blendColor {
+	switch mode {
+	case BlendHue:
+		return cs.setSat(cb.sat()).setLum(cb.lum())
+	case BlendSaturation:
+		return cb.setSat(cs.sat()).setLum(cb.lum())
+	case BlendColor:
+		return cs.setLum(cb.lum())
+	case BlendLuminosity:
+		return cb.setLum(cs.lum())
+	}
+	var r blendColor
+	for k := range r {
+		r[k] = blendChannel(mode, cb[k], cs[k])
+	}
+	return r
+}
+
+//按混合模式合成一个预乘的像素 dst、src为0到1 返回合成后的预乘颜色
+//co = cs*(1-ab) + cb*(1-as) + as*ab*B(Cb,Cs)
+func blendPremul(mode BlendMode, dst, src [4]float64) [4]float64 {
+	as, ab := src[3], dst[3]
+	if as <= 0 {
+		return dst
+	}
+	if ab <= 0 {
+		return src
+	}
+	var cb, cs blendColor
+	for k := 0; k < 3; k++ {
+		cb[k] = math.Min(1, dst[k]/ab)
+		cs[k] = math.Min(1, src[k]/as)
+	}
+	b := blendPixel(mode, cb, cs)
+	var out [4]float64
+	for k := 0; k < 3; k++ {
+		out[k] = src[k]*(1-ab) + dst[k]*(1-as) + as*ab*clamp01(b[k])
+	}
+	out[3] = as + ab*(1-as)
+	return out
+}
+
+//与draw.DrawMask相同 但是按混合模式合成 mask为nil时不使用蒙版 linear为true时在线性光中混合
+func blendDraw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, mask image.Image, mp image.Point, mode BlendMode, linear bool) {
+	//与draw.DrawMask相同 按dst、src、mask的范围缩小r
+	orig := r.Min
+	r = r.Intersect(dst.Bounds()).Intersect(src.Bounds().Add(orig.Sub(sp)))
+	if mask != nil {
+		r = r.Intersect(mask.Bounds().Add(orig.Sub(mp)))
+	}
+	if r.Empty() {
+		return
+	}
+	srcAt := premulSource(src)
+	maskAt := func(x, y int) float64 { return 1 }
+	if m, ok := mask.(*image.Alpha); ok {
+		maskAt = func(x, y int) float64 { return float64(m.Pix[m.PixOffset(x, y)]) / 255 }
+	} else if mask != nil {
+		maskAt = func(x, y int) float64 {
+			_, _, _, a := mask.At(x, y).RGBA()
+			return float64(a) / 0xffff
+		}
+	}
+	dx, dy := sp.X-orig.X, sp.Y-orig.Y
+	mx, my := mp.X-orig.X, mp.Y-orig.Y
+	pixel := func(x, y int, d [4]float64) ([4]float64, bool) {
+		s := srcAt(x+dx, y+dy)
+		if linear {
+			s = linearPremul(s)
+		}
+		if m := maskAt(x+mx, y+my); m < 1 {
+			for k := range s {
+				s[k] *= m
+			}
+		}
+		if s[3] <= 0 {
+			return d, false
+		}
+		return blendPremul(mode, d, s), true
+	}
+	if rgba, ok := dst.(*image.RGBA); ok {
+		parallelRows(r.Min.Y, r.Max.Y, r.Dx(), func(from, to int) {
+			for y := from; y < to; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					i := rgba.PixOffset(x, y)
+					p := rgba.Pix[i : i+4]
+					var d [4]float64
+					if linear {
+						d = linearPixel(p)
+					} else {
+						d = [4]float64{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255}
+					}
+					o, ok := pixel(x, y, d)
+					if !ok {
+						continue
+					}
+					if linear {
+						setSRGBPixel(p, o)
+						continue
+					}
+					a := clampUint8(o[3] * 255)
+					p[3] = a
+					for k := 0; k < 3; k++ {
+						p[k] = minUint8(clampUint8(o[k]*255), a)
+					}
+				}
+			}
+		})
+		return
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, ca := dst.At(x, y).RGBA()
+			d := [4]float64{float64(cr) / 0xffff, float64(cg) / 0xffff, float64(cb) / 0xffff, float64(ca) / 0xffff}
+			if linear {
+				d = linearPremul(d)
+			}
+			o, ok := pixel(x, y, d)
+			if !ok {
+				continue
+			}
+			if linear {
+				o = srgbPremul(o)
+			}
+			a := uint16(clamp01(o[3])*0xffff + 0.5)
+			c := color.RGBA64{A: a}
+			c.R = uint16(math.Min(clamp01(o[0])*0xffff+0.5, float64(a)))
+			c.G = uint16(math.Min(clamp01(o[1])*0xffff+0.5, float64(a)))
+			c.B = uint16(math.Min(clamp01(o[2])*0xffff+0.5, float64(a)))
+			dst.Set(x, y, c)
+		}
+	}
+}
+
+//返回读取src预乘颜色的函数 0到1
+func premulSource(src image.Image) func(x, y int) [4]float64 {
+	switch s := src.(type) {
+	case *image.RGBA:
+		return func(x, y int) [4]float64 {
+			i := s.PixOffset(x, y)
+			p := s.Pix[i : i+4]
+			return [4]float64{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255}
+		}
+	case *image.Uniform:
+		r, g, b, a := s.RGBA()
+		c := [4]float64{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, float64(a) / 0xffff}
+		return func(x, y int) [4]float64 {
+			return c
+		}
+	}
+	return func(x, y int) [4]float64 {
+		r, g, b, a := src.At(x, y).RGBA()
+		return [4]float64{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, float64(a) / 0xffff}
+	}
+}
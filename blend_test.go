@@ -0,0 +1,173 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+//按W3C规范中的公式手算的结果
+func TestBlendChannel(t *testing.T) {
+	cases := []struct {
+		mode   BlendMode
+		cb, cs float64
+		want   float64
+	}{
+		{BlendNormal, 0.25, 0.6, 0.6},
+		{BlendMultiply, 0.25, 0.6, 0.15},
+		{BlendScreen, 0.25, 0.6, 0.7},
+		{BlendOverlay, 0.25, 0.6, 0.3},
+		{BlendOverlay, 0.8, 0.6, 0.84},
+		{BlendDarken, 0.25, 0.6, 0.25},
+		{BlendLighten, 0.25, 0.6, 0.6},
+		{BlendColorDodge, 0.25, 0.6, 0.625},
+		{BlendColorDodge, 0, 1, 0},
+		{BlendColorDodge, 0.5, 1, 1},
+		{BlendColorBurn, 0.8, 0.6, 2.0 / 3},
+		{BlendColorBurn, 0.25, 0.6, 0},
+		{BlendColorBurn, 1, 0, 1},
+		{BlendHardLight, 0.25, 0.6, 0.4},
+		{BlendHardLight, 0.25, 0.3, 0.15},
+		{BlendSoftLight, 0.25, 0.6, 0.3},
+		{BlendSoftLight, 0.64, 0.75, 0.72},
+		{BlendSoftLight, 0.5, 0.25, 0.375},
+		{BlendDifference, 0.25, 0.6, 0.35},
+		{BlendExclusion, 0.25, 0.6, 0.55},
+	}
+	for _, c := range cases {
+		if got := blendChannel(c.mode, c.cb, c.cs); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("mode %d B(%v, %v) = %v, want %v", c.mode, c.cb, c.cs, got, c.want)
+		}
+	}
+}
+
+//不可分离的混合模式保留下层或上层的亮度
+func TestBlendNonSeparable(t *testing.T) {
+	cb := blendColor{0.8, 0.3, 0.1}
+	cs := blendColor{0.1, 0.5, 0.9}
+	cases := []struct {
+		mode BlendMode
+		lum  float64
+	}{
+		{BlendHue, cb.lum()},
+		{BlendSaturation, cb.lum()},
+		{BlendColor, cb.lum()},
+		{BlendLuminosity, cs.lum()},
+	}
+	for _, c := range cases {
+		got := blendPixel(c.mode, cb, cs)
+		if math.Abs(got.lum()-c.lum) > 1e-9 {
+			t.Errorf("mode %d: lum %v, want %v", c.mode, got.lum(), c.lum)
+		}
+		for k, v := range got {
+			if v < 0 || v > 1 {
+				t.Errorf("mode %d: channel %d = %v out of range", c.mode, k, v)
+			}
+		}
+	}
+	//饱和度模式使用上层的饱和度 色相模式使用下层的饱和度
+	if got := blendPixel(BlendSaturation, cb, cs); math.Abs(got.sat()-cs.sat()) > 1e-9 {
+		t.Errorf("saturation: sat %v, want %v", got.sat(), cs.sat())
+	}
+	if got := blendPixel(BlendHue, cb, cs); math.Abs(got.sat()-cb.sat()) > 1e-9 {
+		t.Errorf("hue: sat %v, want %v", got.sat(), cb.sat())
+	}
+	//灰色的饱和度为0 颜色模式得到灰色
+	gray := blendColor{0.5, 0.5, 0.5}
+	if got := blendPixel(BlendColor, cb, gray); got.sat() > 1e-9 {
+		t.Errorf("color with gray source: %v", got)
+	}
+}
+
+//co = cs*(1-ab) + cb*(1-as) + as*ab*B(Cb,Cs)
+func TestBlendPremul(t *testing.T) {
+	dst := [4]float64{0.2, 0.1, 0.3, 0.5}
+	src := [4]float64{0.3, 0.3, 0, 0.6}
+	got := blendPremul(BlendMultiply, dst, src)
+	//Cb=(0.4,0.2,0.6) Cs=(0.5,0.5,0)
+	want := [4]float64{
+		0.3*0.5 + 0.2*0.4 + 0.3*0.2,
+		0.3*0.5 + 0.1*0.4 + 0.3*0.1,
+		0 + 0.3*0.4 + 0,
+		0.6 + 0.5*0.4,
+	}
+	for k := range want {
+		if math.Abs(got[k]-want[k]) > 1e-9 {
+			t.Errorf("channel %d = %v, want %v", k, got[k], want[k])
+		}
+	}
+	if got := blendPremul(BlendScreen, dst, [4]float64{}); got != dst {
+		t.Errorf("transparent source changed dst: %v", got)
+	}
+	if got := blendPremul(BlendScreen, [4]float64{}, src); got != src {
+		t.Errorf("transparent dst: %v, want source", got)
+	}
+}
+
+//正常模式与draw.Over相同
+func TestBlendDrawNormalMatchesDraw(t *testing.T) {
+	src := randomRGBA(23, 17, 10)
+	mask := image.NewAlpha(src.Rect)
+	for i := range mask.Pix {
+		mask.Pix[i] = uint8(i * 7)
+	}
+	for _, m := range []image.Image{nil, mask} {
+		want := randomRGBA(23, 17, 11)
+		got := convertRGBA(want)
+		generic := modelImage{convertRGBA(want)}
+		r := image.Rect(3, 2, 20, 15)
+		draw.DrawMask(want, r, src, image.Pt(1, 1), m, image.Point{}, draw.Over)
+		blendDraw(got, r, src, image.Pt(1, 1), m, image.Point{}, BlendNormal, false)
+		blendDraw(generic, r, src, image.Pt(1, 1), m, image.Point{}, BlendNormal, false)
+		for i := range want.Pix {
+			if absDiff(got.Pix[i], want.Pix[i]) > 1 {
+				t.Fatalf("mask %v: byte %d got %d, want %d", m != nil, i, got.Pix[i], want.Pix[i])
+			}
+		}
+		genericPix := generic.Image.(*image.RGBA).Pix
+		for i := range want.Pix {
+			if absDiff(genericPix[i], want.Pix[i]) > 1 {
+				t.Fatalf("mask %v generic: byte %d got %d, want %d", m != nil, i, genericPix[i], want.Pix[i])
+			}
+		}
+	}
+}
+
+//白色上正片叠底、黑色上滤色不改变不透明的上层
+func TestBlendDrawIdentities(t *testing.T) {
+	src := randomRGBA(16, 16, 12)
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 255
+	}
+	cases := []struct {
+		mode       BlendMode
+		background color.RGBA
+	}{
+		{BlendMultiply, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{BlendScreen, color.RGBA{A: 255}},
+		{BlendLighten, color.RGBA{A: 255}},
+		{BlendDarken, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+	for _, c := range cases {
+		dst := uniformImage(16, 16, c.background).img.(*image.RGBA)
+		blendDraw(dst, dst.Rect, src, image.Point{}, nil, image.Point{}, c.mode, false)
+		for i := range dst.Pix {
+			if absDiff(dst.Pix[i], src.Pix[i]) > 1 {
+				t.Fatalf("mode %d: byte %d got %d, want %d", c.mode, i, dst.Pix[i], src.Pix[i])
+			}
+		}
+	}
+}
+
+func TestParseBlendMode(t *testing.T) {
+	for name, mode := range blendModeNames {
+		if got, err := ParseBlendMode(name); err != nil || got != mode {
+			t.Errorf("%q: got %v, %v", name, got, err)
+		}
+	}
+	if _, err := ParseBlendMode("dissolve"); err == nil {
+		t.Error("unknown blend mode accepted")
+	}
+}
@@ -0,0 +1,51 @@
+package imagedraw
+
+import (
+	"image"
+	"math"
+)
+
+//横向盒式模糊并转置 src为w*h个像素 返回h*w个像素 超出边界的像素取最近的边缘像素
+func boxBlurTranspose(src []uint8, w, h, radius int) []uint8 {
+	dst := make([]uint8, len(src))
+	size := uint32(2*radius + 1)
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			row := src[y*w*4 : (y+1)*w*4]
+			var sum [4]uint32
+			for k := -radius; k <= radius; k++ {
+				p := clampIndex(k, w) * 4
+				for c := 0; c < 4; c++ {
+					sum[c] += uint32(row[p+c])
+				}
+			}
+			for x := 0; x < w; x++ {
+				o := (x*h + y) * 4
+				in := clampIndex(x+radius+1, w) * 4
+				out := clampIndex(x-radius, w) * 4
+				for c := 0; c < 4; c++ {
+					dst[o+c] = uint8((sum[c] + size/2) / size)
+					sum[c] = sum[c] + uint32(row[in+c]) - uint32(row[out+c])
+				}
+			}
+		}
+	})
+	return dst
+}
+
+//模糊 三次盒式模糊近似高斯模糊 sigma为标准差
+func blur(img image.Image, sigma float64) *image.RGBA {
+	src := convertImage(img).(*image.RGBA)
+	radius := int(math.Round(math.Sqrt(sigma*sigma+0.25) - 0.5))
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if radius <= 0 || w == 0 || h == 0 {
+		return src
+	}
+	pix := src.Pix
+	for i := 0; i < 3; i++ {
+		//两次转置后回到原来的方向 相当于横向和纵向各模糊一次
+		pix = boxBlurTranspose(boxBlurTranspose(pix, w, h, radius), h, w, radius)
+	}
+	src.Pix = pix
+	return src
+}
@@ -0,0 +1,325 @@
+// imagedraw 命令行工具 对单张图片进行常用操作
+//
+//	imagedraw resize -w 200 -h 100 -i in.jpg -o out.png
+//	imagedraw resize -w 200 -h 200 -m cover -anchor top -t lanczos3 -i in.jpg -o thumb.jpg
+//	cat in.jpg | imagedraw circle -r 100 | imagedraw opacity -v 50 -f png > out.png
+//
+// -i 默认从标准输入读取 -o 默认写入标准输出 输出格式根据-f或者-o的扩展名确定 默认png
+//
+//	imagedraw serve -addr :8080 -images ./assets -fonts ./fonts
+//
+// serve 启动模板渲染服务 POST /render 设置-key后同时启动图片代理服务 GET /proxy/<签名>/<操作>/<图片地址>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yeyudekuangxiang/imagedraw"
+)
+
+//子命令
+type command struct {
+	usage string
+	//注册子命令的参数 返回对图片的操作
+	setup func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error)
+}
+
+var commands = map[string]command{
+	"resize": {
+		usage: "调整尺寸 -w 宽度 -h 高度 其中一边为0时按比例计算 -t 缩放方式(bilinear cubic lanczos3 mitchell catmullrom nearest area) -m 模式(stretch fit thumbnail cover contain)",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			w := fs.Int("w", 0, "宽度")
+			h := fs.Int("h", 0, "高度")
+			t := fs.String("t", "bilinear", "缩放方式 bilinear cubic lanczos3 mitchell catmullrom nearest area")
+			m := fs.String("m", "stretch", "模式 stretch拉伸 fit保持比例缩放到以内 thumbnail只缩小 cover填满并剪切 contain缩放到以内并填充")
+			anchor := fs.String("anchor", "center", "cover剪切和contain摆放的位置 center top-left top top-right left right bottom-left bottom bottom-right")
+			background := fs.String("bg", "", "contain填充的颜色 如#ffffff 默认透明")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				if *w < 0 || *h < 0 || (*w == 0 && *h == 0) {
+					return nil, fmt.Errorf("-w and -h must not be negative and at least one must be positive")
+				}
+				resizeType, err := imagedraw.ParseResizeType(*t)
+				if err != nil {
+					return nil, err
+				}
+				a, err := imagedraw.ParseAnchor(*anchor)
+				if err != nil {
+					return nil, err
+				}
+				switch *m {
+				case "stretch":
+					return img.Resize(*w, *h, resizeType), nil
+				case "fit":
+					return img.Fit(*w, *h, resizeType), nil
+				case "thumbnail":
+					return img.Thumbnail(*w, *h, resizeType), nil
+				}
+				if *w == 0 || *h == 0 {
+					return nil, fmt.Errorf("-m %s needs both -w and -h", *m)
+				}
+				switch *m {
+				case "cover":
+					return img.Cover(*w, *h, a, resizeType), nil
+				case "contain":
+					bg := color.NRGBA{}
+					if *background != "" {
+						if bg, err = imagedraw.ParseColor(*background); err != nil {
+							return nil, err
+						}
+					}
+					return img.Contain(*w, *h, bg, a, resizeType), nil
+				}
+				return nil, fmt.Errorf("unknown mode %q", *m)
+			}
+		},
+	},
+	"cut": {
+		usage: "剪切方形 -x -y 开始坐标 -w 宽度 -h 高度",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			x := fs.Int("x", 0, "开始x坐标")
+			y := fs.Int("y", 0, "开始y坐标")
+			w := fs.Int("w", 0, "宽度")
+			h := fs.Int("h", 0, "高度")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				if *w <= 0 || *h <= 0 {
+					return nil, fmt.Errorf("-w and -h must be positive")
+				}
+				return img.Cut(*x, *y, *w, *h), nil
+			}
+		},
+	},
+	"circle": {
+		usage: "截取圆形 -x -y 圆心坐标 默认图片中心 -r 半径 默认短边的一半",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			x := fs.Int("x", -1, "圆心x坐标 默认图片中心")
+			y := fs.Int("y", -1, "圆心y坐标 默认图片中心")
+			r := fs.Int("r", 0, "半径 默认短边的一半")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				cx, cy := centerOr(img, *x, *y)
+				radius := *r
+				if radius <= 0 {
+					radius = img.Width() / 2
+					if img.Height() < img.Width() {
+						radius = img.Height() / 2
+					}
+				}
+				return img.Circle(cx, cy, radius), nil
+			}
+		},
+	},
+	"ellipse": {
+		usage: "截取椭圆 -x -y 中心坐标 默认图片中心 -w 横半轴 -h 竖半轴 默认图片宽高的一半",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			x := fs.Int("x", -1, "中心x坐标 默认图片中心")
+			y := fs.Int("y", -1, "中心y坐标 默认图片中心")
+			w := fs.Int("w", 0, "横半轴长度 默认宽度的一半")
+			h := fs.Int("h", 0, "竖半轴长度 默认高度的一半")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				cx, cy := centerOr(img, *x, *y)
+				rw, rh := *w, *h
+				if rw <= 0 {
+					rw = img.Width() / 2
+				}
+				if rh <= 0 {
+					rh = img.Height() / 2
+				}
+				return img.Ellipse(cx, cy, rw, rh), nil
+			}
+		},
+	},
+	"radius": {
+		usage: "圆角 -r 四个角的半径 -lt -rt -rb -lb 分别设置 设置了的角优先于-r 可以为0",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			r := fs.Uint("r", 0, "四个角的半径")
+			lt := fs.Uint("lt", 0, "左上角半径")
+			rt := fs.Uint("rt", 0, "右上角半径")
+			rb := fs.Uint("rb", 0, "右下角半径")
+			lb := fs.Uint("lb", 0, "左下角半径")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				//只有命令行中出现的参数才覆盖-r 0也是有效的值
+				set := map[string]bool{}
+				fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+				pick := func(name string, v *uint) uint {
+					if set[name] {
+						return *v
+					}
+					return *r
+				}
+				return img.BorderRadius(pick("lt", lt), pick("rt", rt), pick("rb", rb), pick("lb", lb)), nil
+			}
+		},
+	},
+	"hue": {
+		usage: "色度 -v -100到100 0不变",
+		setup: colorCommand(func(img *imagedraw.Image, v float64) *imagedraw.Image { return img.Hue(v) }),
+	},
+	"saturation": {
+		usage: "饱和度 -v -100到100 0不变",
+		setup: colorCommand(func(img *imagedraw.Image, v float64) *imagedraw.Image { return img.Saturation(v) }),
+	},
+	"brightness": {
+		usage: "亮度 -v -100到100 0不变",
+		setup: colorCommand(func(img *imagedraw.Image, v float64) *imagedraw.Image { return img.Brightness(v) }),
+	},
+	"opacity": {
+		usage: "不透明度 -v 0-100 100为完全不透明 0为完全透明",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			v := fs.Uint("v", 100, "不透明度 0-100")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				if *v > 100 {
+					return nil, fmt.Errorf("-v must be between 0 and 100")
+				}
+				return img.Opacity(uint32(*v)), nil
+			}
+		},
+	},
+	"pipe": {
+		usage: "依次执行多个操作 -p 操作字符串 如cut:800,200,200,200|radius:20|resize:192x108:cubic",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			p := fs.String("p", "", "操作字符串")
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				return img.Pipe(*p)
+			}
+		},
+	},
+	"convert": {
+		usage: "转换图片格式 使用-f或者-o的扩展名指定格式",
+		setup: func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+				return img, nil
+			}
+		},
+	},
+}
+
+//色度、饱和度、亮度调整
+func colorCommand(fn func(img *imagedraw.Image, v float64) *imagedraw.Image) func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+	return func(fs *flag.FlagSet) func(img *imagedraw.Image) (*imagedraw.Image, error) {
+		v := fs.Float64("v", 0, "-100到100 0不变")
+		return func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			if *v < -100 || *v > 100 {
+				return nil, fmt.Errorf("-v must be between -100 and 100")
+			}
+			return fn(img, *v), nil
+		}
+	}
+}
+
+//坐标小于0时返回图片中心
+func centerOr(img *imagedraw.Image, x, y int) (int, int) {
+	if x < 0 {
+		x = img.Width() / 2
+	}
+	if y < 0 {
+		y = img.Height() / 2
+	}
+	return x, y
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "imagedraw:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: imagedraw <command> [-i input] [-o output] [-f png|jpg] [options]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "  %-11s %s\n", "serve", "启动模板渲染服务 -addr 监听地址 -images 图片目录 -fonts 字体目录")
+}
+
+func run(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if name == "serve" {
+		return serve(args)
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		if name == "help" || name == "-h" || name == "--help" {
+			usage()
+			return nil
+		}
+		usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	input := fs.String("i", "-", "输入文件 -为标准输入")
+	output := fs.String("o", "-", "输出文件 -为标准输出")
+	format := fs.String("f", "", "输出格式 png jpg 默认根据输出文件扩展名 否则png")
+	apply := cmd.setup(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	ext, err := outputFormat(*format, *output)
+	if err != nil {
+		return err
+	}
+
+	var img *imagedraw.Image
+	if *input == "-" {
+		img, err = imagedraw.LoadImageFromReader(stdin)
+	} else {
+		img, err = imagedraw.LoadImage(*input)
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", *input, err)
+	}
+
+	img, err = apply(img)
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		return img.Encode(stdout, ext)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err = img.Encode(file, ext); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//确定输出格式
+func outputFormat(format, output string) (string, error) {
+	if format == "" && output != "-" {
+		format = strings.TrimPrefix(filepath.Ext(output), ".")
+	}
+	switch strings.ToLower(format) {
+	case "", "png":
+		return "png", nil
+	case "jpg", "jpeg":
+		return "jpg", nil
+	}
+	return "", fmt.Errorf("unsupported output format %q", format)
+}
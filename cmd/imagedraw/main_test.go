@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+//不透明的白色png
+func whitePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRun(t *testing.T) {
+	input := whitePNG(t, 40, 20)
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		stdin   []byte
+		//期望输出图片的大小 为空时期望返回错误
+		size image.Point
+	}{
+		{"resize", "resize", []string{"-w", "10", "-h", "5"}, input, image.Pt(10, 5)},
+		{"resize keep aspect", "resize", []string{"-w", "20"}, input, image.Pt(20, 10)},
+		{"resize cover", "resize", []string{"-w", "10", "-h", "10", "-m", "cover", "-anchor", "left"}, input, image.Pt(10, 10)},
+		{"resize contain", "resize", []string{"-w", "10", "-h", "10", "-m", "contain", "-bg", "#ff0000"}, input, image.Pt(10, 10)},
+		{"cut", "cut", []string{"-x", "5", "-y", "5", "-w", "10", "-h", "8"}, input, image.Pt(10, 8)},
+		{"circle", "circle", nil, input, image.Pt(20, 20)},
+		{"ellipse", "ellipse", []string{"-w", "6", "-h", "4"}, input, image.Pt(12, 8)},
+		{"radius", "radius", []string{"-r", "5", "-lt", "0"}, input, image.Pt(40, 20)},
+		{"hue", "hue", []string{"-v", "30"}, input, image.Pt(40, 20)},
+		{"opacity", "opacity", []string{"-v", "50", "-f", "jpg"}, input, image.Pt(40, 20)},
+		{"pipe", "pipe", []string{"-p", "cut:0,0,20,20|radius:4|resize:10x0"}, input, image.Pt(10, 10)},
+		{"convert", "convert", []string{"-f", "png"}, input, image.Pt(40, 20)},
+		{"help", "help", nil, nil, image.Point{}},
+
+		{"unknown command", "blur", nil, input, image.Point{}},
+		{"unknown flag", "resize", []string{"-x", "1"}, input, image.Point{}},
+		{"extra arguments", "resize", []string{"-w", "10", "extra"}, input, image.Point{}},
+		{"bad format", "convert", []string{"-f", "gif"}, input, image.Point{}},
+		{"bad output extension", "convert", []string{"-o", "out.bmp"}, input, image.Point{}},
+		{"missing input", "convert", []string{"-i", "does-not-exist.png"}, input, image.Point{}},
+		{"invalid input", "convert", nil, []byte("not an image"), image.Point{}},
+		{"resize without size", "resize", nil, input, image.Point{}},
+		{"resize negative", "resize", []string{"-w", "-1", "-h", "10"}, input, image.Point{}},
+		{"resize bad type", "resize", []string{"-w", "10", "-t", "sinc"}, input, image.Point{}},
+		{"resize bad mode", "resize", []string{"-w", "10", "-h", "10", "-m", "tile"}, input, image.Point{}},
+		{"cover needs both sides", "resize", []string{"-w", "10", "-m", "cover"}, input, image.Point{}},
+		{"contain bad color", "resize", []string{"-w", "10", "-h", "10", "-m", "contain", "-bg", "red"}, input, image.Point{}},
+		{"cut without size", "cut", []string{"-w", "10"}, input, image.Point{}},
+		{"hue out of range", "hue", []string{"-v", "101"}, input, image.Point{}},
+		{"opacity out of range", "opacity", []string{"-v", "101"}, input, image.Point{}},
+		{"pipe parse error", "pipe", []string{"-p", "cut:1,2"}, input, image.Point{}},
+	}
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		err := run(tt.command, tt.args, bytes.NewReader(tt.stdin), &stdout)
+		if tt.size == (image.Point{}) {
+			if err == nil && tt.command != "help" {
+				t.Errorf("%s: got no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		img, _, err := image.Decode(&stdout)
+		if err != nil {
+			t.Errorf("%s: decode output: %v", tt.name, err)
+			continue
+		}
+		if size := img.Bounds().Size(); size != tt.size {
+			t.Errorf("%s: got size %v, want %v", tt.name, size, tt.size)
+		}
+	}
+}
+
+//设置为0的角不使用-r
+func TestRunRadiusZeroCorner(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := run("radius", []string{"-r", "10", "-lt", "0"}, bytes.NewReader(whitePNG(t, 40, 40)), &stdout); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0xffff {
+		t.Errorf("top-left corner alpha %d, want opaque", a)
+	}
+	if _, _, _, a := img.At(39, 0).RGBA(); a != 0 {
+		t.Errorf("top-right corner alpha %d, want transparent", a)
+	}
+	if c := color.NRGBAModel.Convert(img.At(20, 20)).(color.NRGBA); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("center got %v, want white", c)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yeyudekuangxiang/imagedraw/server"
+)
+
+//启动http服务
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "监听地址")
+	images := fs.String("images", "", "允许读取图片的目录 多个目录用逗号分隔")
+	fonts := fs.String("fonts", "", "允许读取字体的目录 多个目录用逗号分隔")
+	maxBody := fs.Int64("max-body", 1<<20, "请求体最大字节数")
+	timeout := fs.Duration("timeout", 10*time.Second, "每个请求的超时时间")
+	concurrency := fs.Int("concurrency", 0, "同时渲染的最大数量 默认CPU核数")
+	maxPixels := fs.Int("max-pixels", 40000000, "画布、图片和每个图片操作输出图片的最大像素数")
+	key := fs.String("key", "", "图片代理的签名密钥 为空时不启用/proxy/")
+	hosts := fs.String("hosts", "", "图片代理允许访问的域名 多个域名用逗号分隔")
+	cacheBytes := fs.Int64("cache", 64<<20, "图片代理的缓存字节数")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	loader := server.NewRootLoader(splitList(*images), splitList(*fonts))
+	loader.MaxPixels = *maxPixels
+	mux := http.NewServeMux()
+	mux.Handle("/render", server.NewRenderHandler(server.RenderOptions{
+		Loader:        loader,
+		MaxBodyBytes:  *maxBody,
+		Timeout:       *timeout,
+		MaxConcurrent: *concurrency,
+		MaxPixels:     *maxPixels,
+	}))
+	if *key != "" {
+		mux.Handle("/proxy/", http.StripPrefix("/proxy", server.NewProxyHandler(server.ProxyOptions{
+			Key:           []byte(*key),
+			AllowedHosts:  splitList(*hosts),
+			AllowedDirs:   splitList(*images),
+			Cache:         server.NewMemoryCache(*cacheBytes),
+			Timeout:       *timeout,
+			MaxConcurrent: *concurrency,
+			MaxPixels:     *maxPixels,
+		})))
+	}
+
+	log.Printf("imagedraw: listening on %s", *addr)
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+//逗号分隔的列表
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
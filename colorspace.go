@@ -0,0 +1,150 @@
+package imagedraw
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sync/atomic"
+)
+
+// ColorSpace 缩放、变换、合成图片时计算颜色的空间
+type ColorSpace int
+
+const (
+	//使用SetColorSpace设置的全局值 默认为ColorSpaceSRGB
+	ColorSpaceDefault ColorSpace = iota
+	//直接按sRGB编码的值计算 速度最快 缩小高对比度的图片时边缘和细纹会变暗
+	ColorSpaceSRGB ColorSpace = iota
+	//先转换为线性光 计算后再转换回sRGB 缩小文字截图、细密的纹理时亮度保持正确
+	ColorSpaceLinear ColorSpace = iota
+)
+
+//全局的颜色空间 0为ColorSpaceSRGB
+var colorSpace int32
+
+// SetColorSpace 设置全局的颜色空间 对象和选项中没有指定颜色空间时使用 默认ColorSpaceSRGB
+func SetColorSpace(cs ColorSpace) {
+	if cs == ColorSpaceDefault {
+		cs = ColorSpaceSRGB
+	}
+	atomic.StoreInt32(&colorSpace, int32(cs))
+}
+
+// DefaultColorSpace 返回全局的颜色空间
+func DefaultColorSpace() ColorSpace {
+	if cs := ColorSpace(atomic.LoadInt32(&colorSpace)); cs != ColorSpaceDefault {
+		return cs
+	}
+	return ColorSpaceSRGB
+}
+
+//是否在线性光中计算 ColorSpaceDefault时使用全局的设置
+func (cs ColorSpace) linear() bool {
+	if cs == ColorSpaceDefault {
+		cs = DefaultColorSpace()
+	}
+	return cs == ColorSpaceLinear
+}
+
+//查找表的分段数 表之间线性插值 sRGB在0附近本身就是线性的 插值的误差远小于8位的精度
+const linearTableSize = 4096
+
+var (
+	//8位sRGB到线性光 0到1
+	srgb8ToLinearTable = func() (t [256]float64) {
+		for i := range t {
+			t[i] = srgbToLinearExact(float64(i) / 255)
+		}
+		return t
+	}()
+	srgbToLinearTable = linearTable(srgbToLinearExact)
+	linearToSRGBTable = linearTable(linearToSRGBExact)
+)
+
+func srgbToLinearExact(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGBExact(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func linearTable(fn func(v float64) float64) *[linearTableSize + 1]float64 {
+	var t [linearTableSize + 1]float64
+	for i := range t {
+		t[i] = fn(float64(i) / linearTableSize)
+	}
+	return &t
+}
+
+//在查找表中线性插值 v为0到1
+func lookupTable(t *[linearTableSize + 1]float64, v float64) float64 {
+	v = clamp01(v) * linearTableSize
+	i := int(v)
+	if i >= linearTableSize {
+		return t[linearTableSize]
+	}
+	return t[i] + (t[i+1]-t[i])*(v-float64(i))
+}
+
+//预乘的8位sRGB颜色转换为预乘的线性光颜色 0到1 alpha不变
+func linearPixel(p []uint8) [4]float64 {
+	switch a := p[3]; a {
+	case 0:
+		return [4]float64{}
+	case 255:
+		return [4]float64{srgb8ToLinearTable[p[0]], srgb8ToLinearTable[p[1]], srgb8ToLinearTable[p[2]], 1}
+	}
+	return linearPremul([4]float64{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255})
+}
+
+//预乘的sRGB颜色转换为预乘的线性光颜色 0到1 先去掉alpha再转换 转换后再乘回alpha
+func linearPremul(c [4]float64) [4]float64 {
+	a := c[3]
+	if a <= 0 {
+		return [4]float64{}
+	}
+	for k := 0; k < 3; k++ {
+		c[k] = lookupTable(srgbToLinearTable, c[k]/a) * a
+	}
+	return c
+}
+
+//预乘的线性光颜色转换为预乘的sRGB颜色 0到1
+func srgbPremul(c [4]float64) [4]float64 {
+	a := clamp01(c[3])
+	if a <= 0 {
+		return [4]float64{}
+	}
+	for k := 0; k < 3; k++ {
+		c[k] = lookupTable(linearToSRGBTable, c[k]/a) * a
+	}
+	c[3] = a
+	return c
+}
+
+//将预乘的线性光颜色写入8位的sRGB像素
+func setSRGBPixel(q []uint8, c [4]float64) {
+	s := srgbPremul(c)
+	a := clampUint8(s[3] * 255)
+	q[3] = a
+	for k := 0; k < 3; k++ {
+		q[k] = minUint8(clampUint8(s[k]*255), a)
+	}
+}
+
+//与draw.DrawMask相同 mode不是BlendNormal或者需要在线性光中合成时使用blendDraw
+//draw.Src直接复制像素 与颜色空间无关
+func composite(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, mask image.Image, mp image.Point, op draw.Op, mode BlendMode, linear bool) {
+	if mode == BlendNormal && (op == draw.Src || !linear) {
+		draw.DrawMask(dst, r, src, sp, mask, mp, op)
+		return
+	}
+	blendDraw(dst, r, src, sp, mask, mp, mode, linear)
+}
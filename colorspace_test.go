@@ -0,0 +1,143 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+//查找表插值的误差远小于8位的精度
+func TestLinearTables(t *testing.T) {
+	const tolerance = 0.05 / 255
+	for i := 0; i <= 10000; i++ {
+		v := float64(i) / 10000
+		if d := math.Abs(lookupTable(srgbToLinearTable, v) - srgbToLinearExact(v)); d > tolerance {
+			t.Fatalf("srgb to linear %v: error %v", v, d)
+		}
+		if d := math.Abs(lookupTable(linearToSRGBTable, v) - linearToSRGBExact(v)); d > tolerance {
+			t.Fatalf("linear to srgb %v: error %v", v, d)
+		}
+	}
+	for i, v := range srgb8ToLinearTable {
+		if v != srgbToLinearExact(float64(i)/255) {
+			t.Fatalf("8-bit table %d = %v", i, v)
+		}
+	}
+}
+
+//8位颜色转换为线性光再转换回来不变 半透明时最多差1
+func TestLinearPixelRoundTrip(t *testing.T) {
+	for _, a := range []int{255, 200, 77, 1} {
+		tolerance := 1
+		if a == 255 {
+			tolerance = 0
+		}
+		for v := 0; v <= a; v++ {
+			p := []uint8{uint8(v), uint8(v / 2), uint8(a - v), uint8(a)}
+			var q [4]uint8
+			setSRGBPixel(q[:], linearPixel(p))
+			for k := range q {
+				if absDiff(q[k], p[k]) > tolerance {
+					t.Fatalf("alpha %d: %v round trip to %v", a, p, q)
+				}
+			}
+		}
+	}
+}
+
+//黑白棋盘格缩小到一个像素 线性光中为50%亮度的sRGB值188 sRGB中为128
+func TestLinearCheckerboard(t *testing.T) {
+	checker := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.RGBA{A: 255}
+			if (x+y)%2 == 0 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			checker.SetRGBA(x, y, c)
+		}
+	}
+	for _, c := range []struct {
+		linear bool
+		want   uint8
+	}{{true, 188}, {false, 128}} {
+		got := resample(checker, 1, 1, areaFilter, c.linear).(*image.RGBA).RGBAAt(0, 0)
+		if got.R != c.want || got.G != c.want || got.B != c.want || got.A != 255 {
+			t.Errorf("linear %v: got %v, want %d", c.linear, got, c.want)
+		}
+	}
+}
+
+//50%的白色合成到黑色上
+func TestCompositeLinear(t *testing.T) {
+	src := image.NewUniform(color.RGBA{R: 128, G: 128, B: 128, A: 128})
+	for _, c := range []struct {
+		linear bool
+		want   uint8
+	}{{true, 188}, {false, 128}} {
+		dst := uniformImage(2, 2, color.RGBA{A: 255}).img.(*image.RGBA)
+		composite(dst, dst.Rect, src, image.Point{}, nil, image.Point{}, draw.Over, BlendNormal, c.linear)
+		if got := dst.RGBAAt(1, 1); absDiff(got.R, c.want) > 1 || got.A != 255 {
+			t.Errorf("linear %v: got %v, want %d", c.linear, got, c.want)
+		}
+	}
+}
+
+func TestColorSpaceDefault(t *testing.T) {
+	defer SetColorSpace(DefaultColorSpace())
+	SetColorSpace(ColorSpaceDefault)
+	if DefaultColorSpace() != ColorSpaceSRGB || ColorSpaceDefault.linear() {
+		t.Errorf("default color space %d", DefaultColorSpace())
+	}
+	SetColorSpace(ColorSpaceLinear)
+	if !ColorSpaceDefault.linear() || ColorSpaceSRGB.linear() || !ColorSpaceLinear.linear() {
+		t.Error("ColorSpaceDefault does not follow the global setting")
+	}
+}
+
+//绘制上下文的颜色空间优先于全局的设置 元素单独设置的颜色空间优先于绘制上下文
+func TestRenderContextColorSpace(t *testing.T) {
+	defer SetColorSpace(DefaultColorSpace())
+	half := color.RGBA{R: 128, G: 128, B: 128, A: 128}
+	cases := []struct {
+		global, context, item ColorSpace
+		want                  uint8
+	}{
+		{ColorSpaceSRGB, ColorSpaceDefault, ColorSpaceDefault, 128},
+		{ColorSpaceLinear, ColorSpaceDefault, ColorSpaceDefault, 188},
+		{ColorSpaceSRGB, ColorSpaceLinear, ColorSpaceDefault, 188},
+		{ColorSpaceLinear, ColorSpaceSRGB, ColorSpaceDefault, 128},
+		{ColorSpaceSRGB, ColorSpaceLinear, ColorSpaceSRGB, 128},
+		{ColorSpaceSRGB, ColorSpaceSRGB, ColorSpaceLinear, 188},
+	}
+	for _, c := range cases {
+		SetColorSpace(c.global)
+		dst := uniformImage(2, 2, color.RGBA{A: 255})
+		rc := NewRenderContext(dst.Image())
+		rc.ColorSpace = c.context
+		item := uniformImage(2, 2, half).SetArea(0, 0, 2, 2).SetColorSpace(c.item)
+		if _, err := dst.FillContext(rc, item); err != nil {
+			t.Fatal(err)
+		}
+		if got := dst.Image().(*image.RGBA).RGBAAt(1, 1); absDiff(got.R, c.want) > 1 {
+			t.Errorf("global %d context %d item %d: got %v, want %d", c.global, c.context, c.item, got, c.want)
+		}
+
+		//场景中按不透明度合成图层
+		scene := NewScene(2, 2).SetBackground(color.Black).SetColorSpace(c.context)
+		scene.AddLayer("white", uniformImage(2, 2, color.White).SetArea(0, 0, 2, 2)).SetOpacity(50)
+		out, err := scene.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := uint8(128)
+		if scene.colorSpace.linear() {
+			want = 188
+		}
+		if got := out.Image().(*image.RGBA).RGBAAt(1, 1); absDiff(got.R, want) > 1 {
+			t.Errorf("global %d scene %d: got %v, want %d", c.global, c.context, got, want)
+		}
+	}
+}
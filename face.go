@@ -0,0 +1,184 @@
+package imagedraw
+
+import (
+	"container/list"
+	"image"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+//字体、大小、dpi、hinting相同的font.Face可以共用
+type faceKey struct {
+	font    interface{}
+	size    float64
+	dpi     float64
+	hinting font.Hinting
+}
+
+//最近使用的font.Face 超出容量时淘汰最久未使用的
+type faceCache struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[faceKey]*list.Element
+}
+
+type faceCacheEntry struct {
+	key  faceKey
+	face *cachedFace
+}
+
+var defaultFaceCache = &faceCache{
+	max:   64,
+	ll:    list.New(),
+	items: make(map[faceKey]*list.Element),
+}
+
+// SetFaceCacheSize 设置最多缓存多少个字体大小 默认64 为0时不缓存
+func SetFaceCacheSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c := defaultFaceCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = n
+	c.evict()
+}
+
+//返回缓存的font.Face 不存在时使用create创建
+func (c *faceCache) get(key faceKey, create func() (font.Face, error)) (*cachedFace, error) {
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*faceCacheEntry).face, nil
+	}
+	c.mu.Unlock()
+
+	face, err := create()
+	if err != nil {
+		return nil, err
+	}
+	cf := newCachedFace(face)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	//其他goroutine可能已经创建了
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*faceCacheEntry).face, nil
+	}
+	if c.max > 0 {
+		c.items[key] = c.ll.PushFront(&faceCacheEntry{key: key, face: cf})
+		c.evict()
+	}
+	return cf, nil
+}
+
+func (c *faceCache) evict() {
+	for c.ll.Len() > c.max {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*faceCacheEntry).key)
+	}
+}
+
+//字形的宽度和范围
+type glyphMetrics struct {
+	bounds  fixed.Rectangle26_6
+	advance fixed.Int26_6
+	ok      bool
+}
+
+//可以在多个goroutine中使用的font.Face 缓存每个字形的宽度和范围
+type cachedFace struct {
+	mu      sync.Mutex
+	face    font.Face
+	metrics map[rune]glyphMetrics
+}
+
+func newCachedFace(face font.Face) *cachedFace {
+	return &cachedFace{
+		face:    face,
+		metrics: make(map[rune]glyphMetrics),
+	}
+}
+
+//调用方需要持有锁
+func (f *cachedFace) glyphMetrics(r rune) glyphMetrics {
+	if m, ok := f.metrics[r]; ok {
+		return m
+	}
+	var m glyphMetrics
+	m.bounds, m.advance, m.ok = f.face.GlyphBounds(r)
+	f.metrics[r] = m
+	return m
+}
+
+func (f *cachedFace) Close() error {
+	return nil
+}
+
+// Glyph 返回的mask是一份拷贝 不会被之后的调用覆盖
+func (f *cachedFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dr, mask, maskp, advance, ok := f.face.Glyph(dot, r)
+	if !ok || mask == nil {
+		return dr, mask, maskp, advance, ok
+	}
+	b := image.Rectangle{Min: maskp, Max: maskp.Add(dr.Size())}
+	maskCopy := image.NewAlpha(image.Rectangle{Max: b.Size()})
+	draw.Draw(maskCopy, maskCopy.Bounds(), mask, b.Min, draw.Src)
+	return dr, maskCopy, image.Point{}, advance, ok
+}
+
+func (f *cachedFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := f.glyphMetrics(r)
+	return m.bounds, m.advance, m.ok
+}
+
+func (f *cachedFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := f.glyphMetrics(r)
+	return m.advance, m.ok
+}
+
+func (f *cachedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Kern(r0, r1)
+}
+
+func (f *cachedFace) Metrics() font.Metrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Metrics()
+}
+
+//绘制字符串 整个字符串绘制期间持有锁 不需要拷贝字形
+func (f *cachedFace) drawString(dst draw.Image, src image.Image, dot fixed.Point26_6, s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  src,
+		Face: f.face,
+		Dot:  dot,
+	}
+	d.DrawString(s)
+}
+
+//字符串绘制时覆盖的像素范围 向外多留1像素 包含抗锯齿的边缘
+func (f *cachedFace) stringBounds(dot fixed.Point26_6, s string) image.Rectangle {
+	b, _ := font.BoundString(f, s)
+	b = b.Add(dot)
+	return image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil()).Inset(-1)
+}
@@ -1,17 +1,116 @@
 package imagedraw
 
 import (
+	"context"
+	"image"
+	"image/color"
 	"image/draw"
 )
 
+// FillItem 可以被绘制到图片上的元素 实现该接口即可传入Image.Fill
 type FillItem interface {
-	draw(img draw.Image) (draw.Image, error)
+	// Draw 将元素绘制到dst上 返回绘制后的图片
+	Draw(dst draw.Image, rc *RenderContext) (draw.Image, error)
 }
 
-func fill(dst draw.Image, items ...FillItem) (draw.Image, error) {
+// RenderContext 绘制上下文
+type RenderContext struct {
+	//用于取消绘制
+	Context context.Context
+	//目标图片的范围
+	Bounds image.Rectangle
+	//dpi 元素没有单独设置dpi时使用 为0时使用72
+	Dpi float64
+	//裁剪区域 元素只能绘制在此区域内 为空时不裁剪
+	Clip image.Rectangle
+	//合成时计算颜色的空间 元素没有单独设置时使用 为ColorSpaceDefault时使用SetColorSpace设置的全局值
+	ColorSpace ColorSpace
+}
+
+// NewRenderContext 创建一个绘制到dst上的默认绘制上下文
+func NewRenderContext(dst image.Image) *RenderContext {
+	return &RenderContext{
+		Context: context.Background(),
+		Bounds:  dst.Bounds(),
+		Dpi:     72,
+	}
+}
+
+// Err 绘制被取消时返回取消原因
+func (rc *RenderContext) Err() error {
+	if rc.Context == nil {
+		return nil
+	}
+	return rc.Context.Err()
+}
+
+// ClipRect 返回实际可绘制的区域
+func (rc *RenderContext) ClipRect() image.Rectangle {
+	if rc.Clip.Empty() {
+		return rc.Bounds
+	}
+	return rc.Bounds.Intersect(rc.Clip)
+}
+
+// ClipImage 返回一个只能在裁剪区域内绘制的dst 与dst共享像素
+func (rc *RenderContext) ClipImage(dst draw.Image) draw.Image {
+	r := rc.ClipRect()
+	if r == dst.Bounds() {
+		return dst
+	}
+	if sub, ok := dst.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		if img, ok := sub.SubImage(r).(draw.Image); ok {
+			return img
+		}
+	}
+	return &clipImage{Image: dst, r: r}
+}
+
+//限制绘制范围的draw.Image
+type clipImage struct {
+	draw.Image
+	r image.Rectangle
+}
+
+func (c *clipImage) Bounds() image.Rectangle {
+	return c.r
+}
+
+func (c *clipImage) Set(x, y int, clr color.Color) {
+	if image.Pt(x, y).In(c.r) {
+		c.Image.Set(x, y, clr)
+	}
+}
+
+//元素的颜色空间 cs为ColorSpaceDefault时使用绘制上下文的设置
+func (rc *RenderContext) colorSpace(cs ColorSpace) ColorSpace {
+	if cs != ColorSpaceDefault || rc == nil {
+		return cs
+	}
+	return rc.ColorSpace
+}
+
+//没有单独设置颜色空间的元素是否在线性光中合成
+func (rc *RenderContext) linear() bool {
+	return rc.colorSpace(ColorSpaceDefault).linear()
+}
+
+func (rc *RenderContext) dpi() float64 {
+	if rc == nil || rc.Dpi <= 0 {
+		return 72
+	}
+	return rc.Dpi
+}
+
+func fill(dst draw.Image, rc *RenderContext, items ...FillItem) (draw.Image, error) {
 	var err error
 	for _, item := range items {
-		dst, err = item.draw(dst)
+		if err = rc.Err(); err != nil {
+			return nil, err
+		}
+		dst, err = item.Draw(dst, rc)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,117 @@
+package imagedraw
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+//自定义元素 用指定的颜色填满可绘制的区域 记录收到的绘制上下文
+type solidItem struct {
+	c   color.Color
+	rcs []*RenderContext
+}
+
+func (s *solidItem) Draw(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+	s.rcs = append(s.rcs, rc)
+	clipDst := rc.ClipImage(dst)
+	draw.Draw(clipDst, clipDst.Bounds(), image.NewUniform(s.c), image.Point{}, draw.Src)
+	return dst, nil
+}
+
+func TestFillCustomItem(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	item := &solidItem{c: red}
+	img, err := NewBaseImage(6, 4).Fill(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.rcs) != 1 || item.rcs[0].Bounds != image.Rect(0, 0, 6, 4) || item.rcs[0].dpi() != 72 {
+		t.Fatalf("got render contexts %+v", item.rcs)
+	}
+	if c := img.Image().At(5, 3); c != red {
+		t.Errorf("got %v, want %v", c, red)
+	}
+
+	//rc为nil时使用默认的绘制上下文
+	item = &solidItem{c: red}
+	if _, err := NewBaseImage(6, 4).FillContext(nil, item); err != nil {
+		t.Fatal(err)
+	}
+	if len(item.rcs) != 1 || item.rcs[0] == nil || item.rcs[0].Err() != nil {
+		t.Errorf("got render contexts %+v, want a default context", item.rcs)
+	}
+}
+
+//元素只能绘制在裁剪区域内
+func TestFillClip(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	clip := image.Rect(2, 1, 5, 3)
+	items := []FillItem{
+		&solidItem{c: red},
+		uniformImage(10, 10, red).SetArea(0, 0, 10, 10),
+		NewText("HHHHHHHH").SetFont(testTTF(t)).SetFontSize(12).SetColor(red).SetArea(0, 0, 10, 10),
+	}
+	for _, item := range items {
+		dst := NewBaseImage(10, 10)
+		rc := NewRenderContext(dst.Image())
+		rc.Clip = clip
+		if _, err := dst.FillContext(rc, item); err != nil {
+			t.Fatal(err)
+		}
+		rgba := dst.Image().(*image.RGBA)
+		inside := 0
+		for y := 0; y < 10; y++ {
+			for x := 0; x < 10; x++ {
+				a := rgba.RGBAAt(x, y).A
+				if a == 0 {
+					continue
+				}
+				if image.Pt(x, y).In(clip) {
+					inside++
+				} else {
+					t.Errorf("%T: pixel (%d, %d) outside the clip has alpha %d", item, x, y, a)
+				}
+			}
+		}
+		if inside == 0 {
+			t.Errorf("%T: nothing drawn inside the clip", item)
+		}
+	}
+}
+
+//取消后不再绘制之后的元素
+func TestFillCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &solidItem{c: color.RGBA{R: 255, A: 255}}
+	cancelItem := fillFunc(func(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+		cancel()
+		return dst, nil
+	})
+	last := &solidItem{c: color.RGBA{B: 255, A: 255}}
+
+	dst := NewBaseImage(4, 4)
+	rc := NewRenderContext(dst.Image())
+	rc.Context = ctx
+	if _, err := dst.FillContext(rc, first, cancelItem, last); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if len(first.rcs) != 1 || len(last.rcs) != 0 {
+		t.Errorf("drew %d items before and %d after cancel, want 1 and 0", len(first.rcs), len(last.rcs))
+	}
+
+	//已经取消的上下文不绘制任何元素
+	text := NewText("a").SetFont(testTTF(t))
+	if _, err := text.Draw(dst.Image(), rc); !errors.Is(err, context.Canceled) {
+		t.Errorf("text got %v, want context.Canceled", err)
+	}
+}
+
+type fillFunc func(dst draw.Image, rc *RenderContext) (draw.Image, error)
+
+func (f fillFunc) Draw(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+	return f(dst, rc)
+}
@@ -0,0 +1,278 @@
+package imagedraw
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+)
+
+// Anchor 九宫格锚点 用于Cover剪切时保留的位置和Contain摆放的位置
+type Anchor int
+
+const (
+	//居中
+	AnchorCenter Anchor = iota
+	//左上
+	AnchorTopLeft Anchor = iota
+	//上
+	AnchorTop Anchor = iota
+	//右上
+	AnchorTopRight Anchor = iota
+	//左
+	AnchorLeft Anchor = iota
+	//右
+	AnchorRight Anchor = iota
+	//左下
+	AnchorBottomLeft Anchor = iota
+	//下
+	AnchorBottom Anchor = iota
+	//右下
+	AnchorBottomRight Anchor = iota
+)
+
+//锚点的名称
+var anchorNames = map[string]Anchor{
+	"center":       AnchorCenter,
+	"top-left":     AnchorTopLeft,
+	"top":          AnchorTop,
+	"top-right":    AnchorTopRight,
+	"left":         AnchorLeft,
+	"right":        AnchorRight,
+	"bottom-left":  AnchorBottomLeft,
+	"bottom":       AnchorBottom,
+	"bottom-right": AnchorBottomRight,
+}
+
+// ParseAnchor 根据名称返回锚点 center top-left top top-right left right bottom-left bottom bottom-right
+func ParseAnchor(name string) (Anchor, error) {
+	if a, ok := anchorNames[strings.ToLower(name)]; ok {
+		return a, nil
+	}
+	return 0, fmt.Errorf("unknown anchor %q", name)
+}
+
+//锚点在横向和纵向上的比例 0为左、上 1为右、下
+func (a Anchor) ratio() (float64, float64) {
+	switch a {
+	case AnchorTopLeft:
+		return 0, 0
+	case AnchorTop:
+		return 0.5, 0
+	case AnchorTopRight:
+		return 1, 0
+	case AnchorLeft:
+		return 0, 0.5
+	case AnchorRight:
+		return 1, 0.5
+	case AnchorBottomLeft:
+		return 0, 1
+	case AnchorBottom:
+		return 0.5, 1
+	case AnchorBottomRight:
+		return 1, 1
+	}
+	return 0.5, 0.5
+}
+
+//缩放方式 未指定时使用双线性插值 与Resize相同
+func pickResizeType(resizeType []ResizeType) ResizeType {
+	if len(resizeType) == 0 {
+		return BilinearInterpolation
+	}
+	return resizeType[0]
+}
+
+//宽高中为0的一边按原图比例计算 都为0时为原图尺寸
+func keepAspectSize(sw, sh, w, h int) (int, int) {
+	switch {
+	case w == 0 && h == 0:
+		return sw, sh
+	case w == 0 && sh > 0:
+		return roundSize(float64(sw) * float64(h) / float64(sh)), h
+	case h == 0 && sw > 0:
+		return w, roundSize(float64(sh) * float64(w) / float64(sw))
+	}
+	return w, h
+}
+
+//四舍五入 最小为1
+func roundSize(v float64) int {
+	if n := int(math.Round(v)); n > 1 {
+		return n
+	}
+	return 1
+}
+
+//保持比例放入w*h以内的尺寸 w或h为0时不限制该边
+func fitSize(sw, sh, w, h int) (int, int) {
+	if sw <= 0 || sh <= 0 {
+		return 0, 0
+	}
+	if w <= 0 && h <= 0 {
+		return sw, sh
+	}
+	scale := math.Inf(1)
+	if w > 0 {
+		scale = float64(w) / float64(sw)
+	}
+	if h > 0 {
+		scale = math.Min(scale, float64(h)/float64(sh))
+	}
+	return roundSize(float64(sw) * scale), roundSize(float64(sh) * scale)
+}
+
+// Fit 保持比例缩放到w*h以内 返回的图片宽高不会超过w、h 可能小于其中一边 w或h为0时不限制该边
+func (i *Image) Fit(w, h int, resizeType ...ResizeType) *Image {
+	fw, fh := fitSize(i.Width(), i.Height(), w, h)
+	return i.Resize(fw, fh, pickResizeType(resizeType))
+}
+
+// Thumbnail 与Fit相同 但是只缩小不放大 图片已经在w*h以内时返回一份拷贝
+func (i *Image) Thumbnail(w, h int, resizeType ...ResizeType) *Image {
+	if (w <= 0 || i.Width() <= w) && (h <= 0 || i.Height() <= h) {
+		return i.Copy()
+	}
+	return i.Fit(w, h, resizeType...)
+}
+
+// Cover 保持比例缩放并填满w*h 超出的部分按锚点剪切
+func (i *Image) Cover(w, h int, anchor Anchor, resizeType ...ResizeType) *Image {
+	ax, ay := anchor.ratio()
+	return i.cover(w, h, func(cw, ch float64) (float64, float64) {
+		return (float64(i.Width()) - cw) * ax, (float64(i.Height()) - ch) * ay
+	}, pickResizeType(resizeType))
+}
+
+// CoverFocus 保持比例缩放并填满w*h 剪切时尽量使原图中的(x,y)位于中心
+func (i *Image) CoverFocus(w, h, x, y int, resizeType ...ResizeType) *Image {
+	return i.cover(w, h, func(cw, ch float64) (float64, float64) {
+		return float64(x) - cw/2, float64(y) - ch/2
+	}, pickResizeType(resizeType))
+}
+
+//先从原图中剪切出与w*h比例相同的区域再缩放 position返回剪切区域左上角的位置
+func (i *Image) cover(w, h int, position func(cw, ch float64) (float64, float64), resizeType ResizeType) *Image {
+	sw, sh := i.Width(), i.Height()
+	if sw <= 0 || sh <= 0 || w <= 0 || h <= 0 {
+		return NewImage(image.NewRGBA(image.Rect(0, 0, w, h)))
+	}
+	scale := math.Max(float64(w)/float64(sw), float64(h)/float64(sh))
+	cw, ch := float64(w)/scale, float64(h)/scale
+	x, y := position(cw, ch)
+	x = math.Max(0, math.Min(x, float64(sw)-cw))
+	y = math.Max(0, math.Min(y, float64(sh)-ch))
+	cut := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+cw)), int(math.Round(y+ch))).
+		Intersect(image.Rect(0, 0, sw, sh))
+	//比例相差很大时剪切的宽或高会舍入为0 至少保留1个像素
+	if cut.Dx() < 1 {
+		cut.Min.X = minInt(int(x), sw-1)
+		cut.Max.X = cut.Min.X + 1
+	}
+	if cut.Dy() < 1 {
+		cut.Min.Y = minInt(int(y), sh-1)
+		cut.Max.Y = cut.Min.Y + 1
+	}
+	return i.Cut(cut.Min.X, cut.Min.Y, cut.Dx(), cut.Dy()).SetColorSpace(i.colorSpace).Resize(w, h, resizeType)
+}
+
+// Contain 保持比例缩放到w*h以内 按锚点放在w*h的画布上 空白处填充background
+func (i *Image) Contain(w, h int, background color.Color, anchor Anchor, resizeType ...ResizeType) *Image {
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	return i.contain(canvas, anchor, pickResizeType(resizeType))
+}
+
+// ContainBlur 与Contain相同 但是空白处填充铺满画布并模糊后的原图 sigma为模糊程度
+func (i *Image) ContainBlur(w, h int, sigma float64, anchor Anchor, resizeType ...ResizeType) *Image {
+	t := pickResizeType(resizeType)
+	background := blur(i.Cover(w, h, AnchorCenter, t).img, sigma)
+	return i.contain(background, anchor, t)
+}
+
+func (i *Image) contain(canvas *image.RGBA, anchor Anchor, resizeType ResizeType) *Image {
+	w, h := canvas.Rect.Dx(), canvas.Rect.Dy()
+	fw, fh := fitSize(i.Width(), i.Height(), w, h)
+	if fw > 0 && fh > 0 {
+		linear := i.colorSpace.linear()
+		fit := resize(i.img, fw, fh, resizeType, linear)
+		ax, ay := anchor.ratio()
+		x := int(math.Round(float64(w-fw) * ax))
+		y := int(math.Round(float64(h-fh) * ay))
+		composite(canvas, image.Rect(x, y, x+fw, y+fh), fit, image.Point{}, nil, image.Point{}, draw.Over, BlendNormal, linear)
+	}
+	return NewImage(canvas)
+}
+
+// ObjectFit 图片绘制到area时的缩放方式 与css的object-fit相同
+type ObjectFit int
+
+const (
+	//不缩放 按对齐方式放在area中 默认左上角 超出area的部分不绘制
+	FitNone ObjectFit = iota
+	//拉伸到area的大小
+	FitFill ObjectFit = iota
+	//保持比例缩放到area以内
+	FitContain ObjectFit = iota
+	//保持比例填满area 超出的部分按对齐方式剪切
+	FitCover ObjectFit = iota
+	//图片比area大时与FitContain相同 否则与FitNone相同
+	FitScaleDown ObjectFit = iota
+)
+
+//缩放方式的名称
+var objectFitNames = map[string]ObjectFit{
+	"none":       FitNone,
+	"fill":       FitFill,
+	"contain":    FitContain,
+	"cover":      FitCover,
+	"scale-down": FitScaleDown,
+}
+
+// ParseObjectFit 根据名称返回缩放方式 none fill contain cover scale-down
+func ParseObjectFit(name string) (ObjectFit, error) {
+	if f, ok := objectFitNames[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown object fit %q", name)
+}
+
+//按缩放方式返回要绘制的图片和在area中的范围
+func (i *Image) fitArea() (image.Image, image.Rectangle) {
+	area := i.area
+	aw, ah := area.Dx(), area.Dy()
+	sw, sh := i.Width(), i.Height()
+	fit := i.fit
+	if fit == FitScaleDown {
+		fit = FitContain
+		if sw <= aw && sh <= ah {
+			fit = FitNone
+		}
+	}
+	var img image.Image = i.img
+	w, h := sw, sh
+	if aw > 0 && ah > 0 {
+		switch fit {
+		case FitFill:
+			w, h = aw, ah
+		case FitContain:
+			w, h = fitSize(sw, sh, aw, ah)
+		case FitCover:
+			//先剪切再缩放 结果与area大小相同
+			return i.Cover(aw, ah, i.align, i.resizeType).img, area
+		}
+		if w != sw || h != sh {
+			img = resize(i.img, w, h, i.resizeType, i.colorSpace.linear())
+		}
+	}
+	align := i.align
+	if i.fit == FitNone && !i.alignSet {
+		align = AnchorTopLeft
+	}
+	ax, ay := align.ratio()
+	x := area.Min.X + int(math.Round(float64(aw-w)*ax))
+	y := area.Min.Y + int(math.Round(float64(ah-h)*ay))
+	return img, image.Rect(x, y, x+w, y+h)
+}
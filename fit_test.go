@@ -0,0 +1,176 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFitSize(t *testing.T) {
+	cases := []struct {
+		sw, sh, w, h int
+		wantW, wantH int
+	}{
+		{400, 200, 100, 100, 100, 50},
+		{200, 400, 100, 100, 50, 100},
+		{400, 200, 0, 50, 100, 50},
+		{400, 200, 300, 0, 300, 150},
+		{400, 200, 0, 0, 400, 200},
+		{3, 1000, 10, 10, 1, 10},
+	}
+	for _, c := range cases {
+		if w, h := fitSize(c.sw, c.sh, c.w, c.h); w != c.wantW || h != c.wantH {
+			t.Errorf("fitSize(%d,%d,%d,%d) = %d,%d want %d,%d", c.sw, c.sh, c.w, c.h, w, h, c.wantW, c.wantH)
+		}
+	}
+}
+
+func TestKeepAspectSize(t *testing.T) {
+	cases := []struct {
+		sw, sh, w, h int
+		wantW, wantH int
+	}{
+		{400, 200, 100, 0, 100, 50},
+		{400, 200, 0, 100, 200, 100},
+		{400, 200, 0, 0, 400, 200},
+		{400, 200, 30, 70, 30, 70},
+	}
+	for _, c := range cases {
+		if w, h := keepAspectSize(c.sw, c.sh, c.w, c.h); w != c.wantW || h != c.wantH {
+			t.Errorf("keepAspectSize(%d,%d,%d,%d) = %d,%d want %d,%d", c.sw, c.sh, c.w, c.h, w, h, c.wantW, c.wantH)
+		}
+	}
+}
+
+//左半边红色右半边蓝色的图片
+func halfImage(w, h int) *Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return NewImage(img)
+}
+
+func TestCoverAndContain(t *testing.T) {
+	src := halfImage(40, 20)
+	cover := src.Cover(10, 10, AnchorLeft, NearestNeighbor)
+	if cover.Width() != 10 || cover.Height() != 10 {
+		t.Fatalf("cover size %dx%d", cover.Width(), cover.Height())
+	}
+	//按左侧锚点剪切只保留红色部分
+	if got := cover.img.At(9, 5).(color.RGBA); got.R != 255 || got.B != 0 {
+		t.Errorf("cover left got %v, want red", got)
+	}
+	if got := src.Cover(10, 10, AnchorRight, NearestNeighbor).img.At(0, 5).(color.RGBA); got.B != 255 || got.R != 0 {
+		t.Errorf("cover right got %v, want blue", got)
+	}
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	contain := src.Contain(20, 20, white, AnchorCenter, NearestNeighbor)
+	if contain.Width() != 20 || contain.Height() != 20 {
+		t.Fatalf("contain size %dx%d", contain.Width(), contain.Height())
+	}
+	//20x10的图片居中 上下各留5像素
+	for _, p := range []image.Point{{10, 4}, {10, 15}} {
+		if got := contain.img.At(p.X, p.Y); got != white {
+			t.Errorf("contain %v got %v, want background", p, got)
+		}
+	}
+	if got := contain.img.At(0, 5).(color.RGBA); got.R != 255 || got.G != 0 {
+		t.Errorf("contain got %v, want red", got)
+	}
+}
+
+//设置了缩放方式时默认与css的object-position相同居中对齐 FitNone默认左上角
+func TestImageFitDefaultAlign(t *testing.T) {
+	src := halfImage(40, 20)
+	cases := []struct {
+		fit  ObjectFit
+		want image.Rectangle
+	}{
+		{FitNone, image.Rect(0, 0, 40, 20)},
+		{FitContain, image.Rect(0, 5, 20, 15)},
+		{FitScaleDown, image.Rect(0, 5, 20, 15)},
+		{FitFill, image.Rect(0, 0, 20, 20)},
+		{FitCover, image.Rect(0, 0, 20, 20)},
+	}
+	for _, c := range cases {
+		img, r := src.Copy().SetArea(0, 0, 20, 20).SetFit(c.fit).fitArea()
+		if r != c.want {
+			t.Errorf("fit %d: area %v, want %v", c.fit, r, c.want)
+		}
+		if c.fit == FitCover {
+			//居中剪切 红色和蓝色各保留一半
+			if img.At(9, 10) != (color.RGBA{R: 255, A: 255}) || img.At(10, 10) != (color.RGBA{B: 255, A: 255}) {
+				t.Errorf("cover got %v %v, want red and blue halves", img.At(9, 10), img.At(10, 10))
+			}
+		}
+	}
+	_, r := src.Copy().SetArea(0, 0, 20, 20).SetAlign(AnchorCenter).fitArea()
+	if r != image.Rect(-10, 0, 30, 20) {
+		t.Errorf("center area %v", r)
+	}
+	_, r = src.Copy().SetArea(0, 0, 20, 20).SetFit(FitContain).SetAlign(AnchorTopLeft).fitArea()
+	if r != image.Rect(0, 0, 20, 10) {
+		t.Errorf("top-left area %v", r)
+	}
+}
+
+//比例相差很大时剪切区域至少保留1个像素
+func TestCoverExtremeAspect(t *testing.T) {
+	cases := []struct {
+		src    *Image
+		w, h   int
+		anchor Anchor
+		want   color.RGBA
+	}{
+		{halfImage(4000, 1), 1, 400, AnchorLeft, color.RGBA{R: 255, A: 255}},
+		{halfImage(4000, 1), 1, 400, AnchorRight, color.RGBA{B: 255, A: 255}},
+		{halfImage(1, 4000), 400, 1, AnchorCenter, color.RGBA{B: 255, A: 255}},
+	}
+	for _, c := range cases {
+		got := c.src.Cover(c.w, c.h, c.anchor, NearestNeighbor)
+		if got.Width() != c.w || got.Height() != c.h {
+			t.Fatalf("cover size %dx%d, want %dx%d", got.Width(), got.Height(), c.w, c.h)
+		}
+		for _, p := range []image.Point{{0, 0}, {c.w - 1, c.h - 1}} {
+			if px := got.img.At(p.X, p.Y); px != c.want {
+				t.Errorf("%dx%d %v: pixel %v got %v, want %v", c.w, c.h, c.anchor, p, px, c.want)
+			}
+		}
+	}
+}
+
+func TestContainBlur(t *testing.T) {
+	src := halfImage(40, 20)
+	got := src.ContainBlur(20, 20, 2, AnchorCenter, NearestNeighbor)
+	if got.Width() != 20 || got.Height() != 20 {
+		t.Fatalf("size %dx%d", got.Width(), got.Height())
+	}
+	rgba := got.img.(*image.RGBA)
+	//20x10的图片居中 与Contain相同
+	want := src.Contain(20, 20, color.Transparent, AnchorCenter, NearestNeighbor).img.(*image.RGBA)
+	for y := 5; y < 15; y++ {
+		for x := 0; x < 20; x++ {
+			if rgba.RGBAAt(x, y) != want.RGBAAt(x, y) {
+				t.Fatalf("pixel (%d, %d) got %v, want the contained image %v", x, y, rgba.RGBAAt(x, y), want.RGBAAt(x, y))
+			}
+		}
+	}
+	//上下的空白处是模糊后的原图 不透明 左红右蓝 中间混合
+	for _, y := range []int{0, 4, 15, 19} {
+		left, middle, right := rgba.RGBAAt(1, y), rgba.RGBAAt(10, y), rgba.RGBAAt(18, y)
+		if left.A != 255 || middle.A != 255 || right.A != 255 {
+			t.Errorf("row %d: got %v %v %v, want opaque", y, left, middle, right)
+		}
+		if left.R <= left.B || right.B <= right.R || middle.R == 0 || middle.B == 0 {
+			t.Errorf("row %d: got %v %v %v, want a blurred red to blue", y, left, middle, right)
+		}
+	}
+}
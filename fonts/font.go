@@ -1,21 +1,69 @@
+//内置字体数据由gen.go生成 字体源文件较大没有放在仓库中
+//更新字体时把源文件放到fonts目录后执行go generate 生成gzip压缩的数据
+//仓库中的数据文件还没有重新生成时仍然是旧的base64数据 decode两种都支持
+
+//go:generate go run gen.go -var siyuanheiti -o siyuanheiti.go 思源黑体.ttf
+//go:generate go run gen.go -var siyuanheitibold -o siyuanheitibold.go 思源黑体粗体.otf
+
 package fonts
 
-import "encoding/base64"
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"sync"
+)
 
+var (
+	siyuanheitiOnce     sync.Once
+	siyuanheitiData     []byte
+	siyuanheitiBoldOnce sync.Once
+	siyuanheitiBoldData []byte
+)
+
+// SiYuanHeiTiTTF 返回思源黑体ttf数据 只在第一次调用时解码 返回的数据是共用的 不能修改
 func SiYuanHeiTiTTF() []byte {
-	dbuf := make([]byte, base64.StdEncoding.DecodedLen(len(siyuanheiti)))
-	_, err := base64.StdEncoding.Decode(dbuf, []byte(siyuanheiti))
-	if err != nil {
-		panic(err)
-	}
-	return dbuf
+	siyuanheitiOnce.Do(func() {
+		siyuanheitiData = decode(siyuanheiti)
+	})
+	return siyuanheitiData
 }
 
+// SiYuanHeiTiOTFBold 返回思源黑体粗体otf数据 只在第一次调用时解码 返回的数据是共用的 不能修改
 func SiYuanHeiTiOTFBold() []byte {
-	dbuf := make([]byte, base64.StdEncoding.DecodedLen(len(siyuanheitibold)))
-	_, err := base64.StdEncoding.Decode(dbuf, []byte(siyuanheitibold))
+	siyuanheitiBoldOnce.Do(func() {
+		siyuanheitiBoldData = decode(siyuanheitibold)
+	})
+	return siyuanheitiBoldData
+}
+
+//解码内置的字体数据 gen.go生成的是gzip压缩后的数据 兼容旧的base64数据
+func decode(data string) []byte {
+	raw := []byte(data)
+	if !isGzip(raw) {
+		raw = make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+		n, err := base64.StdEncoding.Decode(raw, []byte(data))
+		if err != nil {
+			panic(err)
+		}
+		raw = raw[:n]
+	}
+	if !isGzip(raw) {
+		return raw
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(raw))
 	if err != nil {
 		panic(err)
 	}
-	return dbuf
+	defer reader.Close()
+	font, err := ioutil.ReadAll(reader)
+	if err != nil {
+		panic(err)
+	}
+	return font
+}
+
+func isGzip(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
 }
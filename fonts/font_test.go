@@ -0,0 +1,28 @@
+package fonts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+)
+
+//gen.go生成的gzip数据和旧的base64数据都能解码
+func TestDecode(t *testing.T) {
+	font := []byte("\x00\x01\x00\x00font data")
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(font)
+	writer.Close()
+
+	cases := map[string]string{
+		"gzip":        compressed.String(),
+		"base64 gzip": base64.StdEncoding.EncodeToString(compressed.Bytes()),
+		"base64":      base64.StdEncoding.EncodeToString(font),
+	}
+	for name, data := range cases {
+		if got := decode(data); !bytes.Equal(got, font) {
+			t.Errorf("%s: got %q, want %q", name, got, font)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// +build ignore
+
+//将字体文件压缩后生成go代码 更新内置字体时把字体源文件放到fonts目录中执行go generate
+//
+//	go run gen.go -var siyuanheiti -o siyuanheiti.go 思源黑体.ttf
+//	go run gen.go -var siyuanheitibold -o siyuanheitibold.go 思源黑体粗体.otf
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+)
+
+func main() {
+	name := flag.String("var", "", "变量名")
+	output := flag.String("o", "", "输出文件")
+	flag.Parse()
+	if *name == "" || *output == "" || flag.NArg() != 1 {
+		log.Fatal("usage: go run gen.go -var name -o output.go font.ttf")
+	}
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err = writer.Write(data); err != nil {
+		log.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen.go from %s; DO NOT EDIT.\n\n", filepath.Base(flag.Arg(0)))
+	fmt.Fprintf(&buf, "package fonts\n\n")
+	//直接保存gzip数据 不再使用base64 二进制文件中只占压缩后的大小
+	fmt.Fprintf(&buf, "var %s = \"", *name)
+	for _, b := range compressed.Bytes() {
+		fmt.Fprintf(&buf, "\\x%02x", b)
+	}
+	buf.WriteString("\"\n")
+	if err = ioutil.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
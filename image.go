@@ -2,6 +2,7 @@ package imagedraw
 
 import (
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
@@ -12,6 +13,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 //hsv颜色模型
@@ -72,7 +74,11 @@ func (hsv *Hsv) Value(v float64) {
 
 //color.Color转HSV对象
 func Color2HSV(c color.Color) Hsv {
-	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return rgba2HSV(color.RGBAModel.Convert(c).(color.RGBA))
+}
+
+//color.RGBA转HSV对象
+func rgba2HSV(rgba color.RGBA) Hsv {
 	r := rgba.R
 	g := rgba.G
 	b := rgba.B
@@ -238,106 +244,25 @@ func circle(img image.Image, x, y, r int) draw.Image {
 	return circle*/
 }
 
-func antiAliasing2(img draw.Image, dx int) draw.Image {
-	img = resize(img, img.Bounds().Max.X*dx, img.Bounds().Max.Y*dx, BilinearInterpolation)
-	newImage := image.NewRGBA(image.Rect(0, 0, img.Bounds().Max.X, img.Bounds().Max.Y))
-	for x := 0; x < img.Bounds().Max.X*dx; x += dx {
-		for y := 0; y < img.Bounds().Max.Y*dx; y += dx {
-			var r, g, b, a uint32
-			for ddx := 0; ddx < dx; ddx++ {
-				for ddy := 0; ddy < dx; ddy++ {
-					r1, g1, b1, a1 := img.At(x+ddx, y+ddy).RGBA()
-					r += r1
-					g += g1
-					b += b1
-					a += a1
-				}
-			}
-			aaa := uint32(math.Pow(float64(dx), 2))
-			c := color.NRGBA64{
-				R: uint16(r / aaa),
-				G: uint16(g / aaa),
-				B: uint16(b / aaa),
-				A: uint16(a / aaa),
-			}
-			newImage.Set(x/dx, y/dx, c)
-		}
-	}
-	return newImage
-}
-
-//抗锯齿???
-func antiAliasing(img draw.Image, dx int) draw.Image {
-	newImage := image.NewRGBA(image.Rect(0, 0, img.Bounds().Max.X, img.Bounds().Max.Y))
-	resizeImage := resize(img, img.Bounds().Max.X*dx, img.Bounds().Max.Y*dx, BilinearInterpolation)
-	for x := 0; x < resizeImage.Bounds().Max.X; x += dx {
-		for y := 0; y < resizeImage.Bounds().Max.Y; y += dx {
-			var r, g, b, a uint32
-			for ddx := 0; ddx < dx; ddx++ {
-				for ddy := 0; ddy < dx; ddy++ {
-					r1, g1, b1, a1 := resizeImage.At(x+ddx, y+ddy).RGBA()
-					r += r1
-					g += g1
-					b += b1
-					a += a1
-				}
-			}
-			aaa := uint32(math.Pow(float64(dx), 2))
-			c := color.NRGBA64{
-				R: uint16(r / aaa),
-				G: uint16(g / aaa),
-				B: uint16(b / aaa),
-				A: uint16(a / aaa),
-			}
-			newImage.Set(x/dx, y/dx, c)
-		}
-	}
-	return newImage
-}
-
-//抗锯齿???
+// Deprecated: Circle、Ellipse、BorderRadius的边缘已经抗锯齿 不再需要调用 返回一份拷贝
 func (i *Image) AntiAliasing(dx int) *Image {
-	return NewImage(antiAliasing(i.img, dx))
+	return i.Copy()
 }
 
 //截取椭圆
 func ellipse(img image.Image, x, y, w, h int) draw.Image {
-	ellipse := image.NewRGBA(image.Rect(0, 0, 2*w, 2*h))
-	if w > h {
-		f := math.Sqrt(float64(w*w - h*h))
-		f1 := float64(x) - f //f1 y
-		f2 := float64(x) + f //f2 y
-
-		for x1 := 0; x1 < 2*w; x1++ {
-			for y1 := 0; y1 < 2*h; y1++ {
-				px := x - w + x1
-				py := y - h + y1
-				length := math.Sqrt(math.Pow(float64(px)-f1, 2)+math.Pow(float64(py-y), 2)) + math.Sqrt(math.Pow(float64(px)-f2, 2)+math.Pow(float64(py-y), 2))
-				if length <= float64(2*w) {
-					ellipse.Set(x1, y1, img.At(px, py))
-				}
-			}
-		}
-	} else {
-		f := math.Sqrt(float64(h*h - w*w))
-		f1 := float64(y) - f //x f1
-		f2 := float64(y) + f //x f2
-		for x1 := 0; x1 < 2*w; x1++ {
-			for y1 := 0; y1 < 2*h; y1++ {
-				px := x - w + x1
-				py := y - h + y1
-				length := math.Sqrt(math.Pow(float64(px-x), 2)+math.Pow(float64(py)-f1, 2)) + math.Sqrt(math.Pow(float64(px-x), 2)+math.Pow(float64(py)-f2, 2))
-				if length <= float64(2*h) {
-					ellipse.Set(x1, y1, img.At(px, py))
-				}
-			}
-		}
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
 	}
-	return ellipse
+	return ellipseRGBA(src, x, y, w, h)
 }
 
 //截取方形
 func cut(img image.Image, x, y, x1, y1 int) draw.Image {
+	if src, ok := img.(*image.RGBA); ok {
+		return cutRGBA(src, x, y, x1, y1)
+	}
 	cutImage := image.NewRGBA(image.Rect(0, 0, x1-x, y1-y))
 	for dx := x; dx < x1; dx++ {
 		for dy := y; dy < y1; dy++ {
@@ -349,6 +274,12 @@ func cut(img image.Image, x, y, x1, y1 int) draw.Image {
 
 //将img image.Image转化为draw.Image
 func convertImage(img image.Image) draw.Image {
+	switch src := img.(type) {
+	case *image.RGBA:
+		return convertRGBA(src)
+	case *image.NRGBA:
+		return convertNRGBA(src)
+	}
 	newImg := image.NewRGBA(img.Bounds())
 	for x := 0; x < img.Bounds().Max.X; x++ {
 		for y := 0; y < img.Bounds().Max.Y; y++ {
@@ -358,20 +289,35 @@ func convertImage(img image.Image) draw.Image {
 	return newImg
 }
 
-//将图片保存在本地
+//将图片保存在本地 格式由扩展名决定 扩展名不支持时不会创建文件
 func saveAs(img image.Image, path string) error {
+	ext, err := imageExt(path)
+	if err != nil {
+		return err
+	}
 	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	return saveWriter(img, filepath.Ext(path)[1:], file)
+	return saveWriter(img, ext, file)
+}
+
+//返回保存图片时使用的扩展名 png jpg jpeg 不区分大小写
+func imageExt(path string) (string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch ext {
+	case "png", "jpg", "jpeg":
+		return ext, nil
+	}
+	return "", fmt.Errorf("unsupported image extension %q in %q, must be .png, .jpg or .jpeg", filepath.Ext(path), path)
 }
+
 func saveWriter(img image.Image, ext string, writer io.Writer) error {
 	switch ext {
 	case "png":
 		return png.Encode(writer, img)
-	case "jpg":
+	case "jpg", "jpeg":
 		return jpeg.Encode(writer, img, nil)
 	}
 	return errors.New("ext not support")
@@ -384,21 +330,67 @@ const (
 	CubicConvolution ResizeType = iota
 	//双线性插值缩放
 	BilinearInterpolation ResizeType = iota
+	//Lanczos插值缩放 窗口大小为3 适合缩略图
+	Lanczos3 ResizeType = iota
+	//Mitchell-Netravali样条(B=C=1/3)缩放 比较平滑
+	MitchellNetravali ResizeType = iota
+	//Catmull-Rom样条缩放 比较锐利
+	CatmullRom ResizeType = iota
+	//最近邻缩放 不产生新的颜色 适合像素画
+	NearestNeighbor ResizeType = iota
+	//区域平均缩放 适合大比例缩小
+	AreaAverage ResizeType = iota
 )
 
+//缩放方式的名称
+var resizeTypeNames = map[string]ResizeType{
+	"cubic":      CubicConvolution,
+	"bilinear":   BilinearInterpolation,
+	"lanczos3":   Lanczos3,
+	"lanczos":    Lanczos3,
+	"mitchell":   MitchellNetravali,
+	"catmullrom": CatmullRom,
+	"nearest":    NearestNeighbor,
+	"area":       AreaAverage,
+}
+
+//根据名称返回缩放方式 cubic三次卷积插值 bilinear双线性插值 lanczos3 mitchell catmullrom nearest area
+func ParseResizeType(name string) (ResizeType, error) {
+	if t, ok := resizeTypeNames[strings.ToLower(name)]; ok {
+		return t, nil
+	}
+	return 0, fmt.Errorf("unknown resize type %q", name)
+}
+
 //调整图片尺寸
-func resize(img image.Image, w, h int, resizeType ResizeType) draw.Image {
+func resize(img image.Image, w, h int, resizeType ResizeType, linear bool) draw.Image {
 	switch resizeType {
 	case CubicConvolution:
-		return cubicConvolution(img, w, h)
+		return cubicConvolution(img, w, h, DefaultCubicA, linear)
 	case BilinearInterpolation:
-		return bilinearInterpolation(img, w, h)
+		return bilinearInterpolation(img, w, h, linear)
+	case Lanczos3:
+		return resample(img, w, h, kernelFilter(3, lanczosKernel(3)), linear)
+	case MitchellNetravali:
+		return resample(img, w, h, kernelFilter(2, bcSplineKernel(1.0/3, 1.0/3)), linear)
+	case CatmullRom:
+		return resample(img, w, h, kernelFilter(2, bcSplineKernel(0, 0.5)), linear)
+	case NearestNeighbor:
+		return resample(img, w, h, nearestFilter, linear)
+	case AreaAverage:
+		return resample(img, w, h, areaFilter, linear)
 	}
 	return nil
 }
 
-//双线性插值
-func bilinearInterpolation(img image.Image, w, h int) draw.Image {
+//双线性插值 linear为true时在线性光中插值
+func bilinearInterpolation(img image.Image, w, h int, linear bool) draw.Image {
+	if src, ok := img.(*image.RGBA); ok {
+		return bilinearInterpolationRGBA(src, w, h, linear)
+	}
+	if linear {
+		return bilinearInterpolationRGBA(convertImage(img).(*image.RGBA), w, h, linear)
+	}
 	newImage := image.NewRGBA(image.Rect(0, 0, w, h))
 	for x := 0; x < w; x++ {
 		for y := 0; y < h; y++ {
@@ -424,129 +416,6 @@ func bilinearInterpolation(img image.Image, w, h int) draw.Image {
 	return newImage
 }
 
-//三次卷积插值
-func cubicConvolution(img image.Image, w, h int) draw.Image {
-	newImage := image.NewRGBA(image.Rect(0, 0, w, h))
-	for x := 0; x < w; x++ {
-		for y := 0; y < h; y++ {
-			xf := float64(x*img.Bounds().Max.X) / float64(w)
-			yf := float64(y*img.Bounds().Max.Y) / float64(h)
-			i := math.Floor(xf)
-			j := math.Floor(yf)
-			u := xf - i
-			v := yf - j
-			A := [][]float64{
-				{
-					cubicConvolutionS(1 + v),
-					cubicConvolutionS(v),
-					cubicConvolutionS(1 - v),
-					cubicConvolutionS(2 - v),
-				},
-			}
-			C := [][]float64{
-				{cubicConvolutionS(1 + u)},
-				{cubicConvolutionS(u)},
-				{cubicConvolutionS(1 - u)},
-				{cubicConvolutionS(2 - u)},
-			}
-			BR := make([][]float64, 0)
-			BG := make([][]float64, 0)
-			BB := make([][]float64, 0)
-			BA := make([][]float64, 0)
-			for m := -1; m < 3; m++ {
-				rb := make([]float64, 0)
-				gb := make([]float64, 0)
-				bb := make([]float64, 0)
-				ab := make([]float64, 0)
-				for n := -1; n < 3; n++ {
-					r, g, b, a := img.At(int(i)+m, int(j)+n).RGBA()
-					rb = append(rb, float64(r))
-					gb = append(gb, float64(g))
-					bb = append(bb, float64(b))
-					ab = append(ab, float64(a))
-				}
-				BR = append(BR, rb)
-				BG = append(BG, gb)
-				BB = append(BB, bb)
-				BA = append(BA, ab)
-			}
-			r := matrixMultiplication(matrixMultiplication(A, BR), C)
-			g := matrixMultiplication(matrixMultiplication(A, BG), C)
-			b := matrixMultiplication(matrixMultiplication(A, BB), C)
-			a := matrixMultiplication(matrixMultiplication(A, BA), C)
-
-			if len(r) > 0 && len(r[0]) > 0 && len(g) > 0 && len(g[0]) > 0 && len(b) > 0 && len(b[0]) > 0 && len(a) > 0 && len(a[0]) > 0 {
-				newImage.Set(x, y, color.RGBA64{
-					R: floatToUnit16(r[0][0]), G: floatToUnit16(g[0][0]), B: floatToUnit16(b[0][0]), A: floatToUnit16(a[0][0]),
-				})
-			}
-		}
-	}
-	return newImage
-}
-
-//三次卷积插值采样公式
-func cubicConvolutionS(x float64) float64 {
-	//当a取不同值时可以用来逼近不同的样条函数（常用值-0.5, -0.75）
-	a := -1.0
-	x = math.Abs(x)
-	if x >= 2 {
-		return 0
-	} else if x >= 1 && x < 2 {
-		return -4*a + 8*a*x - 5*a*math.Pow(x, 2) + a*math.Pow(x, 3)
-	} else {
-		return 1 - (a+3)*math.Pow(x, 2) + (a+2)*math.Pow(x, 3)
-	}
-}
-func floatToUnit16(a float64) uint16 {
-	return uint16(a)
-}
-
-//矩阵相乘
-func matrixMultiplication(a [][]float64, b [][]float64) [][]float64 {
-	if len(a) == 0 || len(b) == 0 {
-		return nil
-	}
-	//a的行数
-	rowA := len(a)
-	//a的列数
-	colA := len(a[0])
-	//b的行数
-	rowB := len(b)
-	//b的列数
-	colB := len(b[0])
-
-	if colA != rowB {
-		return nil
-	}
-
-	mm := make([][]float64, 0)
-
-	//循环a的行
-	for i := 0; i < rowA; i++ {
-		//检测a矩阵每一行的列数是否都是colA
-		if len(a[i]) != colA {
-			return nil
-		}
-		rowList := make([]float64, 0)
-		//循环b的列
-		for n := 0; n < colB; n++ {
-			sum := 0.0
-			//a[i][k] * b[k][n]
-			for k := 0; k < colA; k++ {
-				//检测b的列
-				if len(b[k]) != colB {
-					return nil
-				}
-				sum += a[i][k] * b[k][n]
-			}
-			rowList = append(rowList, sum)
-		}
-		mm = append(mm, rowList)
-	}
-	return mm
-}
-
 //设置不透明度 0-100 100为完全不透明 0为完全透明
 func opacity(img image.Image, transparency uint32) draw.Image {
 	if transparency < 0 {
@@ -555,6 +424,9 @@ func opacity(img image.Image, transparency uint32) draw.Image {
 	if transparency > 100 {
 		transparency = 100
 	}
+	if src, ok := img.(*image.RGBA); ok {
+		return opacityRGBA(src, transparency)
+	}
 	opacity := image.NewRGBA(img.Bounds())
 	for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
 		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
@@ -572,6 +444,12 @@ func opacity(img image.Image, transparency uint32) draw.Image {
 
 //色度 -100到100 0不变
 func hue(img image.Image, h float64) draw.Image {
+	if src, ok := img.(*image.RGBA); ok {
+		return hsvRGBA(src, func(hsv Hsv) Hsv {
+			hsv.Hue(h)
+			return hsv
+		})
+	}
 	hue := image.NewRGBA(img.Bounds())
 	for x := 0; x < img.Bounds().Max.X; x++ {
 		for y := 0; y < img.Bounds().Max.Y; y++ {
@@ -585,6 +463,12 @@ func hue(img image.Image, h float64) draw.Image {
 
 //饱和度  -100到100 0不变
 func saturation(img image.Image, s float64) draw.Image {
+	if src, ok := img.(*image.RGBA); ok {
+		return hsvRGBA(src, func(hsv Hsv) Hsv {
+			hsv.Saturation(s)
+			return hsv
+		})
+	}
 	saturation := image.NewRGBA(img.Bounds())
 	for x := 0; x < img.Bounds().Max.X; x++ {
 		for y := 0; y < img.Bounds().Max.Y; y++ {
@@ -598,6 +482,12 @@ func saturation(img image.Image, s float64) draw.Image {
 
 //亮度  -100到100 0不变
 func brightness(img image.Image, v float64) draw.Image {
+	if src, ok := img.(*image.RGBA); ok {
+		return hsvRGBA(src, func(hsv Hsv) Hsv {
+			hsv.Value(v)
+			return hsv
+		})
+	}
 	brightness := image.NewRGBA(img.Bounds())
 	for x := 0; x < img.Bounds().Max.X; x++ {
 		for y := 0; y < img.Bounds().Max.Y; y++ {
@@ -611,46 +501,11 @@ func brightness(img image.Image, v float64) draw.Image {
 
 //圆角 左上 右上 右下 左下
 func borderRadius(img image.Image, lt, rt, rb, lb uint) draw.Image {
-	b := img.Bounds()
-	dx := b.Dx()
-	dy := b.Dy()
-
-	lti := int(lt)
-	rti := int(rt)
-	rbi := int(rb)
-	lbi := int(lb)
-	border := image.NewRGBA(img.Bounds())
-	for x1 := 0; x1 < dx; x1++ {
-		for y1 := 0; y1 < dy; y1++ {
-			if x1 < lti && y1 < lti {
-				if math.Pow(float64(lti-x1), 2)+math.Pow(float64(lti-y1), 2) > math.Pow(float64(lti), 2) {
-					continue
-				}
-			}
-
-			if x1 > dx-rti && y1 < rti {
-				if math.Pow(float64(rti-dx+x1), 2)+math.Pow(float64(rti-y1), 2) > math.Pow(float64(rti), 2) {
-					continue
-				}
-			}
-
-			if x1 > dx-rbi && y1 > dy-rbi {
-				if math.Pow(float64(rbi-dx+x1), 2)+math.Pow(float64(rbi-dy+y1), 2) > math.Pow(float64(rbi), 2) {
-					continue
-				}
-			}
-
-			if x1 < lbi && y1 > dy-lbi {
-				if math.Pow(float64(lbi-x1), 2)+math.Pow(float64(lbi-dy+y1), 2) > math.Pow(float64(lbi), 2) {
-					continue
-				}
-			}
-
-			border.Set(x1, y1, img.At(x1, y1))
-
-		}
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
 	}
-	return border
+	return borderRadiusRGBA(src, lt, rt, rb, lb)
 }
 
 //从本地读取图片
@@ -683,8 +538,10 @@ func LoadImageFromReader(reader io.Reader) (*Image, error) {
 //创建一个图片操作对象
 func NewImage(img draw.Image) *Image {
 	return &Image{
-		img: img,
-		op:  draw.Over,
+		img:        img,
+		op:         draw.Over,
+		align:      AnchorCenter,
+		resizeType: BilinearInterpolation,
 	}
 }
 
@@ -699,6 +556,16 @@ type Image struct {
 	area image.Rectangle
 	img  draw.Image
 	op   draw.Op
+	//绘制到area时的缩放方式、对齐方式和缩放算法
+	fit        ObjectFit
+	align      Anchor
+	resizeType ResizeType
+	//是否调用过SetAlign 没有设置时FitNone按左上角对齐
+	alignSet bool
+	//混合模式 不是BlendNormal时忽略op
+	blend BlendMode
+	//缩放、变换和绘制时计算颜色的空间
+	colorSpace ColorSpace
 }
 
 //设置绘制到另外一张图片上时 在另外一张图片上的范围 x,y开始坐标 w宽度 h长度
@@ -713,10 +580,68 @@ func (i *Image) SetOp(op draw.Op) *Image {
 	return i
 }
 
-//实现FillItem接口
-func (i *Image) draw(dst draw.Image) (draw.Image, error) {
-	//resizeImage := resize(i.img, i.area.Max.X-i.area.Min.X, i.area.Max.Y-i.area.Min.Y, BilinearInterpolation)
-	draw.Draw(dst, i.area, i.img, image.Pt(0, 0), i.op)
+//设置绘制到另外一张图片上时的混合模式 默认BlendNormal 不是BlendNormal时忽略SetOp
+func (i *Image) SetBlendMode(mode BlendMode) *Image {
+	i.blend = mode
+	return i
+}
+
+func (i *Image) blendMode() BlendMode {
+	return i.blend
+}
+
+func (i *Image) drawOp() draw.Op {
+	return i.op
+}
+
+//设置缩放、变换和绘制到另外一张图片上时计算颜色的空间 默认ColorSpaceDefault 绘制时使用绘制上下文的设置 其他操作使用全局的设置
+//只影响该对象上的操作 操作返回的新对象需要重新设置
+func (i *Image) SetColorSpace(cs ColorSpace) *Image {
+	i.colorSpace = cs
+	return i
+}
+
+//设置图片与area大小不同时的缩放方式 默认FitNone不缩放
+func (i *Image) SetFit(fit ObjectFit) *Image {
+	i.fit = fit
+	return i
+}
+
+//设置图片在area中的对齐方式 设置了缩放方式时默认居中 与css的object-position默认值相同
+//FitNone时默认左上角 与之前直接绘制的位置相同
+func (i *Image) SetAlign(align Anchor) *Image {
+	i.align = align
+	i.alignSet = true
+	return i
+}
+
+//设置绘制到area时使用的缩放算法 默认双线性插值
+func (i *Image) SetResizeType(resizeType ResizeType) *Image {
+	i.resizeType = resizeType
+	return i
+}
+
+//绘制区域
+func (i *Image) drawArea(bounds image.Rectangle) image.Rectangle {
+	return i.area
+}
+
+// Draw 实现FillItem接口
+func (i *Image) Draw(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+	if err := rc.Err(); err != nil {
+		return nil, err
+	}
+	//没有单独设置颜色空间时缩放和合成都使用绘制上下文的设置
+	if cs := rc.colorSpace(i.colorSpace); cs != i.colorSpace {
+		c := *i
+		c.colorSpace = cs
+		i = &c
+	}
+	img, r := i.fitArea()
+	//超出area的部分不绘制
+	clip := r.Intersect(i.area)
+	sp := img.Bounds().Min.Add(clip.Min.Sub(r.Min))
+	composite(rc.ClipImage(dst), clip, img, sp, nil, image.Point{}, i.op, i.blend, i.colorSpace.linear())
 	return dst, nil
 }
 
@@ -735,17 +660,29 @@ func (i *Image) BorderRadius(lt, rt, rb, lb uint) *Image {
 	return NewImage(borderRadius(i.img, lt, rt, rb, lb))
 }
 
-//调整图片大小并且返回一个新的对象 w宽度 h高度
+//调整图片大小并且返回一个新的对象 w宽度 h高度 其中一边为0时按原图比例计算
 func (i Image) Resize(w, h int, resizeType ...ResizeType) *Image {
-	if len(resizeType) == 0 {
-		resizeType = append(resizeType, BilinearInterpolation)
-	}
-	return NewImage(resize(i.img, w, h, resizeType[0]))
+	w, h = keepAspectSize(i.Width(), i.Height(), w, h)
+	return NewImage(resize(i.img, w, h, pickResizeType(resizeType), i.colorSpace.linear()))
+}
+
+//使用三次卷积插值调整尺寸 a为样条参数 其中一边为0时按原图比例计算 Resize使用的是DefaultCubicA 常用值-0.5, -0.75
+func (i Image) ResizeCubic(w, h int, a float64) *Image {
+	w, h = keepAspectSize(i.Width(), i.Height(), w, h)
+	return NewImage(cubicConvolution(i.img, w, h, a, i.colorSpace.linear()))
 }
 
 //将其他元素填充进本图片
 func (i *Image) Fill(item ...FillItem) (*Image, error) {
-	img, err := fill(i.img, item...)
+	return i.FillContext(NewRenderContext(i.img), item...)
+}
+
+//使用指定的绘制上下文将其他元素填充进本图片 rc为nil时与Fill相同
+func (i *Image) FillContext(rc *RenderContext, item ...FillItem) (*Image, error) {
+	if rc == nil {
+		rc = NewRenderContext(i.img)
+	}
+	img, err := fill(i.img, rc, item...)
 	if err != nil {
 		return nil, err
 	}
@@ -763,6 +700,21 @@ func (i *Image) Ellipse(x, y, w, h int) *Image {
 	return NewImage(ellipse(i.img, x, y, w, h))
 }
 
+//按路径剪切并且返回一个新的对象 路径外的部分透明 边缘抗锯齿 路径坐标相对于图片左上角
+func (i *Image) ClipPath(p *Path) *Image {
+	return NewImage(clipPath(i.img, p))
+}
+
+//使用蒙版并且返回一个新的对象 蒙版与图片大小不同时先缩放到图片的大小
+func (i *Image) Mask(m *Image, mode MaskMode) *Image {
+	return NewImage(mask(i.img, m.img, mode))
+}
+
+//羽化透明边缘并且返回一个新的对象 radius为向内过渡的宽度 可以用于Circle、Ellipse等之后
+func (i *Image) Feather(radius float64) *Image {
+	return NewImage(feather(i.img, radius))
+}
+
 //设置不透明度 0-100 100为完全不透明 0为完全透明
 func (i *Image) Opacity(transparency uint32) *Image {
 	return NewImage(opacity(i.img, transparency))
@@ -0,0 +1,147 @@
+package imagedraw
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// MaskMode 蒙版的取值方式 取值为0的地方透明 为1的地方保留
+type MaskMode int
+
+const (
+	//使用蒙版的alpha
+	MaskAlpha MaskMode = iota
+	//使用蒙版的亮度 白色保留 黑色透明 透明的地方也是透明
+	MaskLuminance MaskMode = iota
+	//使用1减去蒙版的alpha
+	MaskInvertedAlpha MaskMode = iota
+	//使用1减去蒙版的亮度 再乘以蒙版的alpha 黑色保留 白色透明 透明的地方也是透明
+	MaskInvertedLuminance MaskMode = iota
+)
+
+//预乘alpha的像素按蒙版方式取值 0到1
+func (m MaskMode) value(p []uint8) float64 {
+	a := float64(p[3]) / 255
+	//预乘的亮度 等于亮度乘以alpha
+	lum := (0.2126*float64(p[0]) + 0.7152*float64(p[1]) + 0.0722*float64(p[2])) / 255
+	switch m {
+	case MaskLuminance:
+		return lum
+	case MaskInvertedAlpha:
+		return 1 - a
+	case MaskInvertedLuminance:
+		//(1-亮度)*alpha
+		return a - lum
+	}
+	return a
+}
+
+//使用蒙版 蒙版与图片大小不同时先缩放到图片的大小
+func mask(img image.Image, maskImg image.Image, mode MaskMode) draw.Image {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	r := src.Rect
+	w, h := r.Dx(), r.Dy()
+	var m *image.RGBA
+	if mb := maskImg.Bounds(); mb.Dx() != w || mb.Dy() != h {
+		m = resample(maskImg, w, h, kernelFilter(1, triangleKernel), false).(*image.RGBA)
+	} else if m, ok = maskImg.(*image.RGBA); !ok {
+		m = convertImage(maskImg).(*image.RGBA)
+	}
+	masked := image.NewRGBA(r)
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			for x := 0; x < w; x++ {
+				k := m.PixOffset(m.Rect.Min.X+x, m.Rect.Min.Y+y)
+				v := mode.value(m.Pix[k : k+4])
+				if v <= 0 {
+					continue
+				}
+				i := src.PixOffset(r.Min.X+x, r.Min.Y+y)
+				j := masked.PixOffset(r.Min.X+x, r.Min.Y+y)
+				copyCoverage(masked.Pix[j:j+4], src.Pix[i:i+4], v)
+			}
+		}
+	})
+	return masked
+}
+
+//羽化 将alpha边缘向内柔化 radius为过渡的宽度 图片不会变大
+func feather(img image.Image, radius float64) draw.Image {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	if radius <= 0 {
+		return convertImage(src)
+	}
+	r := src.Rect
+	w, h := r.Dx(), r.Dy()
+	//四周留出透明的边 使贴着图片边界的部分也能羽化
+	pad := int(math.Ceil(radius * 2))
+	padded := image.NewRGBA(image.Rect(0, 0, w+2*pad, h+2*pad))
+	draw.Draw(padded, image.Rect(pad, pad, pad+w, pad+h), src, r.Min, draw.Src)
+	blurred := blur(padded, radius/2)
+	feathered := image.NewRGBA(r)
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			for x := 0; x < w; x++ {
+				i := src.PixOffset(r.Min.X+x, r.Min.Y+y)
+				a := src.Pix[i+3]
+				if a == 0 {
+					continue
+				}
+				//新的alpha取原alpha和模糊后alpha中较小的
+				b := blurred.Pix[blurred.PixOffset(pad+x, pad+y)+3]
+				j := feathered.PixOffset(r.Min.X+x, r.Min.Y+y)
+				copyCoverage(feathered.Pix[j:j+4], src.Pix[i:i+4], math.Min(1, float64(b)/float64(a)))
+			}
+		}
+	})
+	return feathered
+}
+
+//生成w*h的白色蒙版 value返回每个像素中心的取值
+func gradientMask(w, h int, value func(x, y float64) float64) *Image {
+	m := image.NewRGBA(image.Rect(0, 0, w, h))
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			for x := 0; x < w; x++ {
+				v := uint8(clamp01(value(float64(x)+0.5, float64(y)+0.5))*255 + 0.5)
+				i := m.PixOffset(x, y)
+				m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3] = v, v, v, v
+			}
+		}
+	})
+	return NewImage(m)
+}
+
+// LinearGradientMask 线性渐变蒙版 从(x0,y0)处不透明渐变到(x1,y1)处透明 可以用于MaskAlpha和MaskLuminance
+func LinearGradientMask(w, h int, x0, y0, x1, y1 float64) *Image {
+	dx, dy := x1-x0, y1-y0
+	l := dx*dx + dy*dy
+	return gradientMask(w, h, func(x, y float64) float64 {
+		if l == 0 {
+			return 1
+		}
+		//投影到渐变方向上的位置
+		return 1 - ((x-x0)*dx+(y-y0)*dy)/l
+	})
+}
+
+// RadialGradientMask 径向渐变蒙版 圆心(cx,cy) 距离inner以内不透明 从inner到outer渐变为透明
+func RadialGradientMask(w, h int, cx, cy, inner, outer float64) *Image {
+	return gradientMask(w, h, func(x, y float64) float64 {
+		d := math.Hypot(x-cx, y-cy)
+		if outer <= inner {
+			if d <= inner {
+				return 1
+			}
+			return 0
+		}
+		return (outer - d) / (outer - inner)
+	})
+}
@@ -0,0 +1,93 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMaskModes(t *testing.T) {
+	src := uniformImage(4, 1, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+	//白色 黑色 半透明灰色 透明
+	m := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	m.SetRGBA(0, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	m.SetRGBA(1, 0, color.RGBA{A: 255})
+	m.SetRGBA(2, 0, color.RGBA{R: 64, G: 64, B: 64, A: 128})
+	cases := []struct {
+		mode MaskMode
+		want [4]uint8
+	}{
+		{MaskAlpha, [4]uint8{255, 255, 128, 0}},
+		{MaskLuminance, [4]uint8{255, 0, 64, 0}},
+		{MaskInvertedAlpha, [4]uint8{0, 0, 127, 255}},
+		{MaskInvertedLuminance, [4]uint8{0, 255, 64, 0}},
+	}
+	for _, c := range cases {
+		masked := src.Mask(NewImage(m), c.mode).img.(*image.RGBA)
+		for x, want := range c.want {
+			got := masked.RGBAAt(x, 0)
+			//颜色按覆盖率缩放 保持预乘
+			if got.A != want || got.R != uint8(200*float64(want)/255+0.5) {
+				t.Errorf("mode %d: pixel %d got %v, want alpha %d", c.mode, x, got, want)
+			}
+		}
+	}
+}
+
+//蒙版与图片大小不同时缩放到图片的大小 边缘不会变透明
+func TestMaskResized(t *testing.T) {
+	src := uniformImage(8, 8, color.RGBA{G: 255, A: 255})
+	m := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	m.SetRGBA(0, 0, color.RGBA{A: 255})
+	masked := src.Mask(NewImage(m), MaskAlpha).img.(*image.RGBA)
+	if masked.Rect != src.img.Bounds() {
+		t.Fatalf("masked bounds %v", masked.Rect)
+	}
+	for _, y := range []int{0, 7} {
+		if got := masked.RGBAAt(0, y); got.A != 255 {
+			t.Errorf("left (0,%d) got %v, want opaque", y, got)
+		}
+		if got := masked.RGBAAt(7, y); got.A != 0 {
+			t.Errorf("right (7,%d) got %v, want transparent", y, got)
+		}
+	}
+}
+
+func TestFeather(t *testing.T) {
+	src := uniformImage(40, 40, color.RGBA{B: 255, A: 255}).Ellipse(20, 20, 15, 15)
+	feathered := src.Feather(6).img.(*image.RGBA)
+	orig := src.img.(*image.RGBA)
+	if feathered.Rect != orig.Rect {
+		t.Fatalf("feathered bounds %v, want %v", feathered.Rect, orig.Rect)
+	}
+	if got := feathered.RGBAAt(15, 15); got.A != 255 {
+		t.Errorf("center got %v, want opaque", got)
+	}
+	//边缘向内过渡 不会超出原来的范围
+	if a, b := feathered.RGBAAt(15, 2).A, orig.RGBAAt(15, 2).A; a >= b || a == 0 {
+		t.Errorf("edge alpha %d, original %d", a, b)
+	}
+	for i := 0; i < len(orig.Pix); i += 4 {
+		if feathered.Pix[i+3] > orig.Pix[i+3] {
+			t.Fatalf("pixel %d alpha grew from %d to %d", i/4, orig.Pix[i+3], feathered.Pix[i+3])
+		}
+	}
+}
+
+func TestGradientMasks(t *testing.T) {
+	linear := LinearGradientMask(100, 1, 0, 0, 100, 0).img.(*image.RGBA)
+	for x, want := range map[int]uint8{0: 254, 49: 129, 99: 1} {
+		if got := linear.RGBAAt(x, 0); got.A != want || got.R != want {
+			t.Errorf("linear %d got %v, want %d", x, got, want)
+		}
+	}
+	radial := RadialGradientMask(41, 41, 20.5, 20.5, 5, 15).img.(*image.RGBA)
+	for _, c := range []struct {
+		x, y int
+		want uint8
+	}{{20, 20, 255}, {24, 20, 255}, {30, 20, 128}, {40, 20, 0}, {0, 0, 0}} {
+		if got := radial.RGBAAt(c.x, c.y); got.A != c.want {
+			t.Errorf("radial (%d,%d) got %v, want %d", c.x, c.y, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package imagedraw
+
+import (
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+//可以返回字形轮廓的字体 变换后绘制文字时直接填充变换后的轮廓 不需要先绘制成图片再变换
+type glyphOutliner interface {
+	//返回字符串从基线起点dot开始的轮廓 坐标单位为像素
+	outline(s string, opts FontOptions, dot fixed.Point26_6) (*Path, error)
+}
+
+//按face的字宽和字距依次将每个字的轮廓添加到路径中 与font.Drawer的排版相同
+//glyph将字符r的轮廓添加到p中 (x,y)为该字的基线起点
+func outlineString(face font.Face, s string, dot fixed.Point26_6, glyph func(p *Path, r rune, x, y float64) error) (*Path, error) {
+	p := NewPath()
+	prev := rune(-1)
+	for _, r := range s {
+		if prev >= 0 {
+			dot.X += face.Kern(prev, r)
+		}
+		advance, ok := face.GlyphAdvance(r)
+		if !ok {
+			continue
+		}
+		if err := glyph(p, r, fixedToFloat(dot.X), fixedToFloat(dot.Y)); err != nil {
+			return nil, err
+		}
+		dot.X += advance
+		prev = r
+	}
+	return p, nil
+}
+
+//26.6定点数转浮点数 Int26ToFloat的小数部分不精确 轮廓需要精确的坐标
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
+
+//字体大小对应的每em像素数 与opentype、truetype创建font.Face时相同
+func fontScale(opts FontOptions) fixed.Int26_6 {
+	return fixed.Int26_6(0.5 + opts.Size*opts.Dpi*64/72)
+}
+
+func (o *OTFDraw) outline(s string, opts FontOptions, dot fixed.Point26_6) (*Path, error) {
+	face, err := o.face(opts)
+	if err != nil {
+		return nil, err
+	}
+	scale := fontScale(opts)
+	var buf sfnt.Buffer
+	return outlineString(face, s, dot, func(p *Path, r rune, x, y float64) error {
+		index, err := o.font.GlyphIndex(&buf, r)
+		if err != nil {
+			return err
+		}
+		segments, err := o.font.LoadGlyph(&buf, index, scale, nil)
+		if err == sfnt.ErrColoredGlyph {
+			//彩色的字形没有轮廓
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		//sfnt返回的坐标y轴向下
+		at := func(pt fixed.Point26_6) (float64, float64) {
+			return x + fixedToFloat(pt.X), y + fixedToFloat(pt.Y)
+		}
+		for _, seg := range segments {
+			x0, y0 := at(seg.Args[0])
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				p.MoveTo(x0, y0)
+			case sfnt.SegmentOpLineTo:
+				p.LineTo(x0, y0)
+			case sfnt.SegmentOpQuadTo:
+				x1, y1 := at(seg.Args[1])
+				p.QuadTo(x0, y0, x1, y1)
+			case sfnt.SegmentOpCubeTo:
+				x1, y1 := at(seg.Args[1])
+				x2, y2 := at(seg.Args[2])
+				p.CubicTo(x0, y0, x1, y1, x2, y2)
+			}
+		}
+		return nil
+	})
+}
+
+func (t *TTFDraw) outline(s string, opts FontOptions, dot fixed.Point26_6) (*Path, error) {
+	//与DrawString绘制的大小相同
+	opts = ttfDrawOptions(opts)
+	face, err := t.face(opts)
+	if err != nil {
+		return nil, err
+	}
+	scale := fontScale(opts)
+	var buf truetype.GlyphBuf
+	return outlineString(face, s, dot, func(p *Path, r rune, x, y float64) error {
+		if err := buf.Load(t.font, scale, t.font.Index(r), font.HintingNone); err != nil {
+			return err
+		}
+		start := 0
+		for _, end := range buf.Ends {
+			ttfContour(p, buf.Points[start:end], x, y)
+			start = end
+		}
+		return nil
+	})
+}
+
+//truetype的一个轮廓 由在曲线上的点和二次曲线的控制点组成 两个相邻的控制点之间隐含一个在曲线上的中点
+//truetype的坐标y轴向上
+func ttfContour(p *Path, points []truetype.Point, x, y float64) {
+	if len(points) == 0 {
+		return
+	}
+	at := func(pt truetype.Point) pathPoint {
+		return pathPoint{x + fixedToFloat(pt.X), y - fixedToFloat(pt.Y)}
+	}
+	onCurve := func(pt truetype.Point) bool {
+		return pt.Flags&0x01 != 0
+	}
+	//找到一个在曲线上的起点
+	first, last := points[0], points[len(points)-1]
+	var start pathPoint
+	rest := points
+	switch {
+	case onCurve(first):
+		start, rest = at(first), points[1:]
+	case onCurve(last):
+		start, rest = at(last), points[:len(points)-1]
+	default:
+		a, b := at(last), at(first)
+		start = pathPoint{(a.x + b.x) / 2, (a.y + b.y) / 2}
+	}
+	p.MoveTo(start.x, start.y)
+	prev, prevOn := start, true
+	for _, pt := range rest {
+		q, on := at(pt), onCurve(pt)
+		switch {
+		case on && prevOn:
+			p.LineTo(q.x, q.y)
+		case on:
+			p.QuadTo(prev.x, prev.y, q.x, q.y)
+		case !prevOn:
+			p.QuadTo(prev.x, prev.y, (prev.x+q.x)/2, (prev.y+q.y)/2)
+		}
+		prev, prevOn = q, on
+	}
+	if prevOn {
+		p.LineTo(start.x, start.y)
+	} else {
+		p.QuadTo(prev.x, prev.y, start.x, start.y)
+	}
+	p.Close()
+}
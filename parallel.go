@@ -0,0 +1,93 @@
+package imagedraw
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//图片处理最多使用的goroutine数量 0为GOMAXPROCS
+var concurrency int32
+
+//像素数量少于该值时不拆分 避免goroutine的开销大于计算本身
+const parallelMinPixels = 64 * 1024
+
+// SetConcurrency 设置每个图片操作最多使用多少个goroutine 小于1时使用GOMAXPROCS 为1时单线程执行
+//
+// 并行执行只是按行拆分 结果与单线程执行逐字节相同
+func SetConcurrency(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&concurrency, int32(n))
+}
+
+// Concurrency 返回每个图片操作最多使用的goroutine数量
+func Concurrency() int {
+	if n := atomic.LoadInt32(&concurrency); n > 0 {
+		return int(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+//将[minY,maxY)的行分成多段并行执行fn 每行width个像素 fn只能写入自己负责的行
+//
+//fn中的panic会在所有goroutine结束后在调用者的goroutine中重新panic 调用者可以recover
+func parallelRows(minY, maxY, width int, fn func(y0, y1 int)) {
+	rows := maxY - minY
+	if rows <= 0 {
+		return
+	}
+	workers := Concurrency()
+	if width > 0 {
+		if n := rows * width / parallelMinPixels; n < workers {
+			workers = n
+		}
+	}
+	if workers > rows {
+		workers = rows
+	}
+	if workers <= 1 {
+		fn(minY, maxY)
+		return
+	}
+	//每个goroutine处理多段 处理快的goroutine可以多处理几段
+	bands := workers * 4
+	if bands > rows {
+		bands = rows
+	}
+	size := (rows + bands - 1) / bands
+	var next int32 = -1
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicValue interface{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() { panicValue = r })
+					//其他goroutine不再处理剩下的行
+					atomic.StoreInt32(&next, int32(bands))
+				}
+			}()
+			for {
+				band := int(atomic.AddInt32(&next, 1))
+				y0 := minY + band*size
+				if y0 >= maxY {
+					return
+				}
+				y1 := y0 + size
+				if y1 > maxY {
+					y1 = maxY
+				}
+				fn(y0, y1)
+			}
+		}()
+	}
+	wg.Wait()
+	if panicValue != nil {
+		panic(panicValue)
+	}
+}
@@ -0,0 +1,70 @@
+package imagedraw
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+//随机颜色的预乘图片
+func randomRGBA(w, h int, seed int64) *image.RGBA {
+	r := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := uint8(r.Intn(256))
+		img.Pix[i+3] = a
+		for k := 0; k < 3; k++ {
+			img.Pix[i+k] = uint8(r.Intn(int(a) + 1))
+		}
+	}
+	return img
+}
+
+//按行并行的结果与单线程执行逐字节相同
+func TestParallelMatchesSerial(t *testing.T) {
+	//超过parallelMinPixels才会拆分
+	src := randomRGBA(400, 300, 1)
+	ops := []struct {
+		name string
+		fn   func(img *Image) *Image
+	}{
+		{"hue", func(img *Image) *Image { return img.Hue(40) }},
+		{"saturation", func(img *Image) *Image { return img.Saturation(-30) }},
+		{"brightness", func(img *Image) *Image { return img.Brightness(20) }},
+		{"opacity", func(img *Image) *Image { return img.Opacity(60) }},
+		{"blur", func(img *Image) *Image { return NewImage(blur(img.img, 3)) }},
+		{"resize", func(img *Image) *Image { return img.Resize(250, 170, Lanczos3) }},
+		{"bilinear", func(img *Image) *Image { return img.Resize(500, 420, BilinearInterpolation) }},
+		{"rotate", func(img *Image) *Image { return img.Rotate(33) }},
+		{"ellipse", func(img *Image) *Image { return img.Ellipse(200, 150, 180, 140) }},
+		{"linear", func(img *Image) *Image { return img.SetColorSpace(ColorSpaceLinear).Resize(120, 90, CatmullRom) }},
+	}
+	defer SetConcurrency(0)
+	for _, op := range ops {
+		SetConcurrency(1)
+		serial := op.fn(NewImage(convertRGBA(src))).img.(*image.RGBA)
+		SetConcurrency(7)
+		parallel := op.fn(NewImage(convertRGBA(src))).img.(*image.RGBA)
+		if serial.Rect != parallel.Rect || !bytes.Equal(serial.Pix, parallel.Pix) {
+			t.Errorf("%s: parallel result differs from serial result", op.name)
+		}
+	}
+}
+
+//goroutine中的panic传到调用者 可以被recover
+func TestParallelRowsPanic(t *testing.T) {
+	defer SetConcurrency(0)
+	SetConcurrency(4)
+	defer func() {
+		if r := recover(); r != "broken row" {
+			t.Errorf("got %v, want the panic from fn", r)
+		}
+	}()
+	parallelRows(0, 1000, 1000, func(y0, y1 int) {
+		if y0 <= 500 && 500 < y1 {
+			panic("broken row")
+		}
+	})
+	t.Error("parallelRows returned without panic")
+}
@@ -0,0 +1,395 @@
+package imagedraw
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// FillRule 路径的填充规则
+type FillRule int
+
+const (
+	//非零环绕规则 svg默认
+	NonZero FillRule = iota
+	//奇偶规则 重叠的部分镂空
+	EvenOdd FillRule = iota
+)
+
+//路径中的点
+type pathPoint struct {
+	x, y float64
+}
+
+//路径命令的类型
+type pathOpKind int
+
+const (
+	pathMove pathOpKind = iota
+	pathLine
+	pathQuad
+	pathCubic
+	pathClose
+)
+
+//一个路径命令 pts中依次为控制点和终点
+type pathOp struct {
+	kind pathOpKind
+	pts  [3]pathPoint
+}
+
+// Path 矢量路径 由直线、二次和三次贝塞尔曲线、椭圆弧组成 坐标为图片上的像素坐标
+//
+// 每个子路径在填充时自动闭合
+type Path struct {
+	ops      []pathOp
+	start    pathPoint
+	cur      pathPoint
+	fillRule FillRule
+}
+
+// NewPath 创建一个空的路径
+func NewPath() *Path {
+	return &Path{}
+}
+
+// MoveTo 开始一个新的子路径
+func (p *Path) MoveTo(x, y float64) *Path {
+	pt := pathPoint{x, y}
+	p.ops = append(p.ops, pathOp{kind: pathMove, pts: [3]pathPoint{pt}})
+	p.start, p.cur = pt, pt
+	return p
+}
+
+// LineTo 直线连接到(x,y)
+func (p *Path) LineTo(x, y float64) *Path {
+	pt := pathPoint{x, y}
+	p.ops = append(p.ops, pathOp{kind: pathLine, pts: [3]pathPoint{pt}})
+	p.cur = pt
+	return p
+}
+
+// QuadTo 二次贝塞尔曲线 (cx,cy)为控制点
+func (p *Path) QuadTo(cx, cy, x, y float64) *Path {
+	pt := pathPoint{x, y}
+	p.ops = append(p.ops, pathOp{kind: pathQuad, pts: [3]pathPoint{{cx, cy}, pt}})
+	p.cur = pt
+	return p
+}
+
+// CubicTo 三次贝塞尔曲线 (c1x,c1y)、(c2x,c2y)为控制点
+func (p *Path) CubicTo(c1x, c1y, c2x, c2y, x, y float64) *Path {
+	pt := pathPoint{x, y}
+	p.ops = append(p.ops, pathOp{kind: pathCubic, pts: [3]pathPoint{{c1x, c1y}, {c2x, c2y}, pt}})
+	p.cur = pt
+	return p
+}
+
+// ArcTo 椭圆弧 与svg的A命令相同 rx、ry为半径 rotation为椭圆旋转的角度
+// largeArc为是否取大于180度的弧 sweep为是否顺时针
+func (p *Path) ArcTo(rx, ry, rotation float64, largeArc, sweep bool, x, y float64) *Path {
+	x1, y1 := p.cur.x, p.cur.y
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || (x1 == x && y1 == y) {
+		return p.LineTo(x, y)
+	}
+	//转换为圆心参数 见svg规范F.6.5
+	phi := rotation * math.Pi / 180
+	sin, cos := math.Sincos(phi)
+	dx2, dy2 := (x1-x)/2, (y1-y)/2
+	x1p := cos*dx2 + sin*dy2
+	y1p := -sin*dx2 + cos*dy2
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := coef * -ry * x1p / rx
+	cx := cos*cxp - sin*cyp + (x1+x)/2
+	cy := sin*cxp + cos*cyp + (y1+y)/2
+	theta := vectorAngle(1, 0, (x1p-cxp)/rx, (y1p-cyp)/ry)
+	delta := math.Mod(vectorAngle((x1p-cxp)/rx, (y1p-cyp)/ry, (-x1p-cxp)/rx, (-y1p-cyp)/ry), 2*math.Pi)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	//每段不超过90度 用三次贝塞尔曲线近似
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	ellipsePoint := func(ux, uy float64) (float64, float64) {
+		return cx + rx*ux*cos - ry*uy*sin, cy + rx*ux*sin + ry*uy*cos
+	}
+	for i := 0; i < n; i++ {
+		a := theta + float64(i)*step
+		b := a + step
+		sa, ca := math.Sincos(a)
+		sb, cb := math.Sincos(b)
+		c1x, c1y := ellipsePoint(ca-k*sa, sa+k*ca)
+		c2x, c2y := ellipsePoint(cb+k*sb, sb-k*cb)
+		ex, ey := ellipsePoint(cb, sb)
+		if i == n-1 {
+			ex, ey = x, y
+		}
+		p.CubicTo(c1x, c1y, c2x, c2y, ex, ey)
+	}
+	return p
+}
+
+//向量u到v的夹角
+func vectorAngle(ux, uy, vx, vy float64) float64 {
+	return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+}
+
+// Close 闭合当前子路径
+func (p *Path) Close() *Path {
+	p.ops = append(p.ops, pathOp{kind: pathClose})
+	p.cur = p.start
+	return p
+}
+
+// SetFillRule 设置填充规则 默认NonZero
+func (p *Path) SetFillRule(rule FillRule) *Path {
+	p.fillRule = rule
+	return p
+}
+
+// FillRule 返回填充规则
+func (p *Path) FillRule() FillRule {
+	return p.fillRule
+}
+
+// Append 将other的所有子路径添加到p中
+func (p *Path) Append(other *Path) *Path {
+	p.ops = append(p.ops, other.ops...)
+	p.start, p.cur = other.start, other.cur
+	return p
+}
+
+//对所有的点执行fn 返回一个新的路径
+func (p *Path) mapPoints(fn func(pt pathPoint) pathPoint) *Path {
+	newPath := &Path{
+		ops:      make([]pathOp, len(p.ops)),
+		start:    fn(p.start),
+		cur:      fn(p.cur),
+		fillRule: p.fillRule,
+	}
+	for i, op := range p.ops {
+		for j := range op.pts {
+			op.pts[j] = fn(op.pts[j])
+		}
+		newPath.ops[i] = op
+	}
+	return newPath
+}
+
+//每种命令使用的点数
+var pathOpPoints = [...]int{pathMove: 1, pathLine: 1, pathQuad: 2, pathCubic: 3, pathClose: 0}
+
+//包含所有点和控制点的最小整数矩形 曲线一定在控制点的凸包内
+func (p *Path) bounds() image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, op := range p.ops {
+		for _, pt := range op.pts[:pathOpPoints[op.kind]] {
+			minX, maxX = math.Min(minX, pt.x), math.Max(maxX, pt.x)
+			minY, maxY = math.Min(minY, pt.y), math.Max(maxY, pt.y)
+		}
+	}
+	if minX > maxX || minY > maxY {
+		return image.Rectangle{}
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+//所有点和控制点坐标绝对值的最大值
+func (p *Path) extent() float64 {
+	extent := 0.0
+	for _, op := range p.ops {
+		for _, pt := range op.pts[:pathOpPoints[op.kind]] {
+			extent = math.Max(extent, math.Max(math.Abs(pt.x), math.Abs(pt.y)))
+		}
+	}
+	return extent
+}
+
+//曲线展开为折线时允许的最大误差 单位像素
+const pathTolerance = 0.1
+
+//每段曲线最多展开的折线段数 坐标很大时误差会超过pathTolerance
+const pathMaxSegments = 4096
+
+//曲线展开的段数 dd为控制点二阶差分的大小
+func flattenSegments(dd float64) int {
+	n := math.Ceil(math.Sqrt(dd / (4 * pathTolerance)))
+	//NaN也使用上限
+	if !(n <= pathMaxSegments) {
+		return pathMaxSegments
+	}
+	return int(n)
+}
+
+//将路径展开为多个折线 每个折线是一个子路径
+func (p *Path) flatten() [][]pathPoint {
+	var contours [][]pathPoint
+	var contour []pathPoint
+	var cur pathPoint
+	flush := func() {
+		if len(contour) > 1 {
+			contours = append(contours, contour)
+		}
+		contour = nil
+	}
+	for _, op := range p.ops {
+		switch op.kind {
+		case pathMove:
+			flush()
+			cur = op.pts[0]
+			contour = []pathPoint{cur}
+			continue
+		case pathClose:
+			if len(contour) > 0 {
+				cur = contour[0]
+			}
+			flush()
+			contour = []pathPoint{cur}
+			continue
+		}
+		if len(contour) == 0 {
+			contour = []pathPoint{cur}
+		}
+		switch op.kind {
+		case pathLine:
+			contour = append(contour, op.pts[0])
+		case pathQuad:
+			c, end := op.pts[0], op.pts[1]
+			dd := math.Hypot(cur.x-2*c.x+end.x, cur.y-2*c.y+end.y)
+			n := flattenSegments(dd)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				mt := 1 - t
+				contour = append(contour, pathPoint{
+					mt*mt*cur.x + 2*mt*t*c.x + t*t*end.x,
+					mt*mt*cur.y + 2*mt*t*c.y + t*t*end.y,
+				})
+			}
+			if n == 0 {
+				contour = append(contour, end)
+			}
+		case pathCubic:
+			c1, c2, end := op.pts[0], op.pts[1], op.pts[2]
+			dd := math.Max(
+				math.Hypot(cur.x-2*c1.x+c2.x, cur.y-2*c1.y+c2.y),
+				math.Hypot(c1.x-2*c2.x+end.x, c1.y-2*c2.y+end.y),
+			)
+			n := flattenSegments(dd * 3)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				mt := 1 - t
+				contour = append(contour, pathPoint{
+					mt*mt*mt*cur.x + 3*mt*mt*t*c1.x + 3*mt*t*t*c2.x + t*t*t*end.x,
+					mt*mt*mt*cur.y + 3*mt*mt*t*c1.y + 3*mt*t*t*c2.y + t*t*t*end.y,
+				})
+			}
+			if n == 0 {
+				contour = append(contour, end)
+			}
+		}
+		cur = contour[len(contour)-1]
+	}
+	flush()
+	return contours
+}
+
+// Polygon 正多边形 (cx,cy)为中心 r为外接圆半径 n为边数 第一个顶点在正上方 rotation为顺时针旋转的角度 n小于3时panic
+func Polygon(cx, cy, r float64, n int, rotation float64) *Path {
+	if n < 3 {
+		panic(fmt.Sprintf("imagedraw: Polygon needs at least 3 sides, got %d", n))
+	}
+	p := NewPath()
+	for i := 0; i < n; i++ {
+		a := (rotation-90)*math.Pi/180 + 2*math.Pi*float64(i)/float64(n)
+		x, y := cx+r*math.Cos(a), cy+r*math.Sin(a)
+		if i == 0 {
+			p.MoveTo(x, y)
+		} else {
+			p.LineTo(x, y)
+		}
+	}
+	return p.Close()
+}
+
+// Star 星形 (cx,cy)为中心 outer、inner为外顶点和内顶点到中心的距离 n为角的数量 第一个角在正上方 n小于2时panic
+func Star(cx, cy, outer, inner float64, n int, rotation float64) *Path {
+	if n < 2 {
+		panic(fmt.Sprintf("imagedraw: Star needs at least 2 points, got %d", n))
+	}
+	p := NewPath()
+	for i := 0; i < 2*n; i++ {
+		r := outer
+		if i%2 == 1 {
+			r = inner
+		}
+		a := (rotation-90)*math.Pi/180 + math.Pi*float64(i)/float64(n)
+		x, y := cx+r*math.Cos(a), cy+r*math.Sin(a)
+		if i == 0 {
+			p.MoveTo(x, y)
+		} else {
+			p.LineTo(x, y)
+		}
+	}
+	return p.Close()
+}
+
+// Superellipse 超椭圆 |x/rx|^n + |y/ry|^n = 1 n为2时是椭圆 越大越接近矩形
+func Superellipse(cx, cy, rx, ry, n float64) *Path {
+	p := NewPath()
+	//按周长决定采样的点数 与曲线展开使用相同的上限
+	steps := math.Max(32, math.Ceil((math.Abs(rx)+math.Abs(ry))*2))
+	//NaN也使用上限
+	if !(steps <= pathMaxSegments) {
+		steps = pathMaxSegments
+	}
+	for i := 0; i < int(steps); i++ {
+		t := 2 * math.Pi * float64(i) / steps
+		s, c := math.Sincos(t)
+		x := cx + rx*math.Copysign(math.Pow(math.Abs(c), 2/n), c)
+		y := cy + ry*math.Copysign(math.Pow(math.Abs(s), 2/n), s)
+		if i == 0 {
+			p.MoveTo(x, y)
+		} else {
+			p.LineTo(x, y)
+		}
+	}
+	return p.Close()
+}
+
+// Squircle 圆角方形 n为4的超椭圆 (cx,cy)为中心 r为半边长
+func Squircle(cx, cy, r float64) *Path {
+	return Superellipse(cx, cy, r, r, 4)
+}
+
+// Heart 心形 (cx,cy)为中心 size为宽高
+func Heart(cx, cy, size float64) *Path {
+	//在[-1,1]的范围内绘制 再缩放到size
+	p := NewPath().
+		MoveTo(0, 1).
+		CubicTo(-0.55, 0.6, -1, 0.15, -1, -0.3).
+		CubicTo(-1, -0.75, -0.75, -1, -0.5, -1).
+		CubicTo(-0.2, -1, 0, -0.75, 0, -0.5).
+		CubicTo(0, -0.75, 0.2, -1, 0.5, -1).
+		CubicTo(0.75, -1, 1, -0.75, 1, -0.3).
+		CubicTo(1, 0.15, 0.55, 0.6, 0, 1).
+		Close()
+	return p.mapPoints(func(pt pathPoint) pathPoint {
+		return pathPoint{cx + pt.x*size/2, cy + pt.y*size/2}
+	})
+}
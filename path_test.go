@@ -0,0 +1,192 @@
+package imagedraw
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseSVGPath(t *testing.T) {
+	square := NewPath().MoveTo(10, 10).LineTo(30, 10).LineTo(30, 30).LineTo(10, 30).Close()
+	cases := []struct {
+		d    string
+		want *Path
+	}{
+		{"M10 10 L30 10 L30 30 L10 30 Z", square},
+		{"M10,10 H30 V30 H10 z", square},
+		{"m10 10 h20 v20 h-20 z", square},
+		//moveto之后的坐标是lineto
+		{"M10 10 30 10 30 30 10 30z", square},
+		{"M10-5.5.5.25", NewPath().MoveTo(10, -5.5).LineTo(0.5, 0.25)},
+		{"M1e1 0l1E-1 0", NewPath().MoveTo(10, 0).LineTo(10.1, 0)},
+		{"M0 0 Q10 0 10 10 T20 20", NewPath().MoveTo(0, 0).QuadTo(10, 0, 10, 10).QuadTo(10, 20, 20, 20)},
+		{"M0 0 C0 10 10 10 10 0 S20 -10 20 0", NewPath().MoveTo(0, 0).CubicTo(0, 10, 10, 10, 10, 0).CubicTo(10, -10, 20, -10, 20, 0)},
+	}
+	for _, c := range cases {
+		p, err := ParseSVGPath(c.d)
+		if err != nil {
+			t.Errorf("%q: %v", c.d, err)
+			continue
+		}
+		if !reflect.DeepEqual(p.ops, c.want.ops) {
+			t.Errorf("%q: got %v, want %v", c.d, p.ops, c.want.ops)
+		}
+	}
+}
+
+func TestParseSVGPathErrors(t *testing.T) {
+	cases := []struct {
+		d      string
+		offset int
+	}{
+		{"L10 10", 0},
+		{"10 10", 0},
+		{"M0 0 X1 1", 5},
+		{"M0 0 L10", 8},
+		{"M0 0 A1 1 0 2 0 5 5", 12},
+		{"M0 0 Q1e300 0 1e300 1e300", 6},
+		{"M0 0 L1e400 0", 6},
+		{"M0 0 l9e6 0 l9e6 0", 12},
+	}
+	for _, c := range cases {
+		_, err := ParseSVGPath(c.d)
+		var pe *PathError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: got %v, want *PathError", c.d, err)
+			continue
+		}
+		if pe.Offset != c.offset {
+			t.Errorf("%q: offset %d, want %d (%v)", c.d, pe.Offset, c.offset, err)
+		}
+	}
+}
+
+//坐标很大时每段曲线展开的段数有上限
+func TestFlattenLargeCurve(t *testing.T) {
+	p := NewPath().MoveTo(0, 0).QuadTo(1e300, 0, 1e300, 1e300).CubicTo(-1e300, 1e300, 1e300, -1e300, 0, 0)
+	contours := p.flatten()
+	if len(contours) != 1 || len(contours[0]) != 1+2*pathMaxSegments {
+		t.Errorf("got %d contours, want 1 with %d points", len(contours), 1+2*pathMaxSegments)
+	}
+}
+
+//超大或NaN的半径不会生成过多的点
+func TestSuperellipseSegments(t *testing.T) {
+	for _, r := range []float64{1e12, math.Inf(1), math.NaN()} {
+		p := Superellipse(0, 0, r, r, 4)
+		if len(p.ops) != pathMaxSegments+1 {
+			t.Errorf("radius %v: got %d ops, want %d", r, len(p.ops), pathMaxSegments+1)
+		}
+	}
+	if p := Squircle(0, 0, 5); len(p.ops) != 33 {
+		t.Errorf("small squircle got %d ops, want 33", len(p.ops))
+	}
+}
+
+func TestShapeSides(t *testing.T) {
+	tests := []struct {
+		name  string
+		shape func()
+		panic bool
+	}{
+		{"polygon 3", func() { Polygon(0, 0, 10, 3, 0) }, false},
+		{"polygon 2", func() { Polygon(0, 0, 10, 2, 0) }, true},
+		{"polygon 0", func() { Polygon(0, 0, 10, 0, 0) }, true},
+		{"polygon -1", func() { Polygon(0, 0, 10, -1, 0) }, true},
+		{"star 2", func() { Star(0, 0, 10, 5, 2, 0) }, false},
+		{"star 1", func() { Star(0, 0, 10, 5, 1, 0) }, true},
+		{"star 0", func() { Star(0, 0, 10, 5, 0, 0) }, true},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); (r != nil) != tt.panic {
+					t.Errorf("%s: got panic %v, want panic %v", tt.name, r, tt.panic)
+				}
+			}()
+			tt.shape()
+		}()
+	}
+}
+
+//覆盖率之和等于路径的面积
+func coverageSum(coverage []float64) float64 {
+	sum := 0.0
+	for _, c := range coverage {
+		sum += c
+	}
+	return sum
+}
+
+func TestRasterizePathCoverage(t *testing.T) {
+	p := MustParseSVGPath("M2.5 2 H12.5 V12 H2.5 Z")
+	coverage := rasterizePath(p, 16, 16)
+	if sum := coverageSum(coverage); math.Abs(sum-100) > 0.01 {
+		t.Errorf("area %v, want 100", sum)
+	}
+	cases := map[[2]int]float64{
+		{5, 5}:  1,
+		{2, 5}:  0.5,
+		{12, 5}: 0.5,
+		{1, 5}:  0,
+		{5, 12}: 0,
+	}
+	for pt, want := range cases {
+		if got := coverage[pt[1]*16+pt[0]]; math.Abs(got-want) > 0.01 {
+			t.Errorf("%v coverage %v, want %v", pt, got, want)
+		}
+	}
+
+	//弧线按pathTolerance转换为折线 面积最多少周长乘以pathTolerance
+	circle := MustParseSVGPath("M30 20 A10 10 0 1 1 10 20 A10 10 0 1 1 30 20 Z")
+	sum, want := coverageSum(rasterizePath(circle, 40, 40)), math.Pi*100
+	if sum > want || want-sum > 2*math.Pi*10*pathTolerance {
+		t.Errorf("circle area %v, want %v", sum, want)
+	}
+}
+
+//两个同向的正方形 非零规则填满 奇偶规则中间镂空 反向的内部正方形两种规则都镂空
+func TestFillRules(t *testing.T) {
+	const same = "M0 0 H20 V20 H0 Z M5 5 H15 V15 H5 Z"
+	const reverse = "M0 0 H20 V20 H0 Z M5 5 V15 H15 V5 Z"
+	cases := []struct {
+		d      string
+		rule   FillRule
+		center float64
+		area   float64
+	}{
+		{same, NonZero, 1, 400},
+		{same, EvenOdd, 0, 300},
+		{reverse, NonZero, 0, 300},
+		{reverse, EvenOdd, 0, 300},
+	}
+	for _, c := range cases {
+		coverage := rasterizePath(MustParseSVGPath(c.d).SetFillRule(c.rule), 20, 20)
+		if got := coverage[10*20+10]; got != c.center {
+			t.Errorf("%q rule %d: center %v, want %v", c.d, c.rule, got, c.center)
+		}
+		if got := coverage[2*20+2]; got != 1 {
+			t.Errorf("%q rule %d: ring %v, want 1", c.d, c.rule, got)
+		}
+		if sum := coverageSum(coverage); math.Abs(sum-c.area) > 0.01 {
+			t.Errorf("%q rule %d: area %v, want %v", c.d, c.rule, sum, c.area)
+		}
+	}
+}
+
+func TestClipPathFillRule(t *testing.T) {
+	src := uniformImage(20, 20, color.RGBA{R: 255, A: 255})
+	p := MustParseSVGPath("M0 0 H20 V20 H0 Z M5 5 H15 V15 H5 Z")
+	for rule, center := range map[FillRule]uint8{NonZero: 255, EvenOdd: 0} {
+		clipped := src.ClipPath(p.SetFillRule(rule)).img.(*image.RGBA)
+		if got := clipped.RGBAAt(10, 10); got.A != center || got.R != center {
+			t.Errorf("rule %d: center %v, want alpha %d", rule, got, center)
+		}
+		if got := clipped.RGBAAt(2, 2); got != (color.RGBA{R: 255, A: 255}) {
+			t.Errorf("rule %d: ring %v", rule, got)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package imagedraw
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+//3x3透视变换矩阵 按行存放 点(x,y)变换为((h0*x+h1*y+h2)/w, (h3*x+h4*y+h5)/w) w=h6*x+h7*y+h8
+type homography [9]float64
+
+func (h homography) apply(x, y float64) (float64, float64, bool) {
+	w := h[6]*x + h[7]*y + h[8]
+	if w <= 0 {
+		//在地平线之后
+		return 0, 0, false
+	}
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w, true
+}
+
+func (h homography) multiply(n homography) homography {
+	var m homography
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			m[r*3+c] = h[r*3]*n[c] + h[r*3+1]*n[3+c] + h[r*3+2]*n[6+c]
+		}
+	}
+	return m
+}
+
+//逆矩阵 使用伴随矩阵计算 不可逆时第二个返回值为false
+func (h homography) invert() (homography, bool) {
+	inv := homography{
+		h[4]*h[8] - h[5]*h[7], h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8], h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6], h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	det := h[0]*inv[0] + h[1]*inv[3] + h[2]*inv[6]
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return homography{}, false
+	}
+	//乘以同一个数不影响透视变换的结果 保持w为正
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
+
+//将w*h的矩形映射到四边形quad 顺序为左上、右上、右下、左下
+func rectToQuad(w, h float64, quad [4]image.Point) (homography, bool) {
+	x0, y0 := float64(quad[0].X), float64(quad[0].Y)
+	x1, y1 := float64(quad[1].X), float64(quad[1].Y)
+	x2, y2 := float64(quad[2].X), float64(quad[2].Y)
+	x3, y3 := float64(quad[3].X), float64(quad[3].Y)
+	//先求单位正方形到四边形的变换
+	dx1, dy1 := x1-x2, y1-y2
+	dx2, dy2 := x3-x2, y3-y2
+	dx3, dy3 := x0-x1+x2-x3, y0-y1+y2-y3
+	var g, k float64
+	if dx3 != 0 || dy3 != 0 {
+		det := dx1*dy2 - dx2*dy1
+		if det == 0 {
+			return homography{}, false
+		}
+		g = (dx3*dy2 - dx2*dy3) / det
+		k = (dx1*dy3 - dx3*dy1) / det
+	}
+	square := homography{
+		x1 - x0 + g*x1, x3 - x0 + k*x3, x0,
+		y1 - y0 + g*y1, y3 - y0 + k*y3, y0,
+		g, k, 1,
+	}
+	if w <= 0 || h <= 0 {
+		return homography{}, false
+	}
+	m := square.multiply(homography{1 / w, 0, 0, 0, 1 / h, 0, 0, 0, 1})
+	if _, ok := m.invert(); !ok {
+		return homography{}, false
+	}
+	return m, true
+}
+
+//四边形的路径
+func quadPath(quad [4]image.Point, dx, dy int) *Path {
+	p := NewPath()
+	for k, pt := range quad {
+		x, y := float64(pt.X-dx), float64(pt.Y-dy)
+		if k == 0 {
+			p.MoveTo(x, y)
+		} else {
+			p.LineTo(x, y)
+		}
+	}
+	return p.Close()
+}
+
+//四个点的外接矩形 不能用Union 空的矩形会被忽略
+func quadBounds(quad [4]image.Point) image.Rectangle {
+	bounds := image.Rectangle{Min: quad[0], Max: quad[0]}
+	for _, pt := range quad[1:] {
+		if pt.X < bounds.Min.X {
+			bounds.Min.X = pt.X
+		}
+		if pt.Y < bounds.Min.Y {
+			bounds.Min.Y = pt.Y
+		}
+		if pt.X > bounds.Max.X {
+			bounds.Max.X = pt.X
+		}
+		if pt.Y > bounds.Max.Y {
+			bounds.Max.Y = pt.Y
+		}
+	}
+	return bounds
+}
+
+//透视变换 将图片映射到quad 返回quad外接矩形大小的图片和外接矩形
+func perspectiveWarp(img image.Image, quad [4]image.Point, resizeType ResizeType, linear bool) (draw.Image, image.Rectangle) {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	bounds := quadBounds(quad)
+	w, h := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	m, ok := rectToQuad(float64(src.Rect.Dx()), float64(src.Rect.Dy()), quad)
+	if !ok {
+		return dst, bounds
+	}
+	inv, _ := m.invert()
+	//四边形的边缘按覆盖率抗锯齿 内部采样时取最近的边缘像素 避免边缘变暗
+	coverage := rasterizePath(quadPath(quad, bounds.Min.X, bounds.Min.Y), w, h)
+	parallelRows(0, h, w, func(from, to int) {
+		sampler := newRGBASampler(src, resizeType, true, linear)
+		var pixel [4]uint8
+		for y := from; y < to; y++ {
+			for x := 0; x < w; x++ {
+				c := clamp01(coverage[y*w+x])
+				if c <= 0 {
+					continue
+				}
+				fx, fy, ok := inv.apply(float64(bounds.Min.X+x)+0.5, float64(bounds.Min.Y+y)+0.5)
+				if !ok {
+					continue
+				}
+				sampler.sample(pixel[:], fx, fy)
+				j := dst.PixOffset(x, y)
+				copyCoverage(dst.Pix[j:j+4], pixel[:], c)
+			}
+		}
+	})
+	return dst, bounds
+}
+
+//矫正 将图片中的四边形quad拉伸为w*h的矩形
+func rectify(img image.Image, quad [4]image.Point, w, h int, resizeType ResizeType, linear bool) draw.Image {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	m, ok := rectToQuad(float64(w), float64(h), quad)
+	if !ok {
+		return dst
+	}
+	parallelRows(0, h, w, func(from, to int) {
+		sampler := newRGBASampler(src, resizeType, true, linear)
+		for y := from; y < to; y++ {
+			for x := 0; x < w; x++ {
+				fx, fy, ok := m.apply(float64(x)+0.5, float64(y)+0.5)
+				if !ok {
+					continue
+				}
+				j := dst.PixOffset(x, y)
+				sampler.sample(dst.Pix[j:j+4], fx, fy)
+			}
+		}
+	})
+	return dst
+}
+
+//四边形对边长度的较大值 用于矫正时未指定的宽高
+func quadSize(quad [4]image.Point) (int, int) {
+	dist := func(a, b image.Point) float64 {
+		return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+	}
+	w := math.Max(dist(quad[0], quad[1]), dist(quad[3], quad[2]))
+	h := math.Max(dist(quad[0], quad[3]), dist(quad[1], quad[2]))
+	return roundSize(w), roundSize(h)
+}
+
+// PerspectiveWarp 透视变换 将图片的四个角映射到dstQuad 顺序为左上、右上、右下、左下 四边形需要是凸的
+//
+// 返回的图片为dstQuad外接矩形的大小 并且已经SetArea到外接矩形 直接绘制到背景上即可贴合四边形 边缘抗锯齿
+func (i *Image) PerspectiveWarp(dstQuad [4]image.Point, resizeType ...ResizeType) *Image {
+	img, bounds := perspectiveWarp(i.img, dstQuad, pickResizeType(resizeType), i.colorSpace.linear())
+	return NewImage(img).SetArea(bounds.Min.X, bounds.Min.Y, bounds.Dx(), bounds.Dy())
+}
+
+// Rectify 透视矫正 将图片中的四边形srcQuad拉伸为w*h的矩形 如拍摄的文档 顺序为左上、右上、右下、左下
+//
+// w或h为0时使用四边形对边长度的较大值
+func (i *Image) Rectify(srcQuad [4]image.Point, w, h int, resizeType ...ResizeType) *Image {
+	qw, qh := quadSize(srcQuad)
+	if w <= 0 {
+		w = qw
+	}
+	if h <= 0 {
+		h = qh
+	}
+	return NewImage(rectify(i.img, srcQuad, w, h, pickResizeType(resizeType), i.colorSpace.linear()))
+}
@@ -0,0 +1,104 @@
+package imagedraw
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+//四个角映射到对应的点 逆矩阵映射回去
+func TestRectToQuad(t *testing.T) {
+	quad := [4]image.Point{{10, 5}, {90, 20}, {80, 70}, {5, 60}}
+	m, ok := rectToQuad(40, 30, quad)
+	if !ok {
+		t.Fatal("rectToQuad failed")
+	}
+	inv, ok := m.invert()
+	if !ok {
+		t.Fatal("homography not invertible")
+	}
+	corners := [4][2]float64{{0, 0}, {40, 0}, {40, 30}, {0, 30}}
+	for k, c := range corners {
+		x, y, ok := m.apply(c[0], c[1])
+		if !ok || math.Abs(x-float64(quad[k].X)) > 1e-9 || math.Abs(y-float64(quad[k].Y)) > 1e-9 {
+			t.Errorf("corner %v maps to (%v,%v), want %v", c, x, y, quad[k])
+		}
+		x, y, ok = inv.apply(x, y)
+		if !ok || math.Abs(x-c[0]) > 1e-9 || math.Abs(y-c[1]) > 1e-9 {
+			t.Errorf("corner %v round trip (%v,%v)", c, x, y)
+		}
+	}
+	//三点共线不能构成四边形
+	if _, ok := rectToQuad(40, 30, [4]image.Point{{0, 0}, {10, 0}, {20, 0}, {0, 10}}); ok {
+		t.Error("degenerate quad accepted")
+	}
+}
+
+//平滑的渐变图片 用于比较重采样后的结果
+func gradientRGBA(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i] = uint8(x * 255 / (w - 1))
+			img.Pix[i+1] = uint8(y * 255 / (h - 1))
+			img.Pix[i+2] = 128
+			img.Pix[i+3] = 255
+		}
+	}
+	return img
+}
+
+//贴到与原图相同的矩形上时与原图相同
+func TestPerspectiveWarpIdentity(t *testing.T) {
+	src := NewImage(randomRGBA(12, 8, 9))
+	warped := src.PerspectiveWarp([4]image.Point{{3, 2}, {15, 2}, {15, 10}, {3, 10}})
+	if warped.area != image.Rect(3, 2, 15, 10) {
+		t.Errorf("area %v", warped.area)
+	}
+	got, want := rgbaOf(warped), rgbaOf(src)
+	if !sameRGBA(got, want) {
+		t.Error("identity warp changed the image")
+	}
+}
+
+//贴到四边形上再矫正回来与原图接近
+func TestRectifyRoundTrip(t *testing.T) {
+	src := NewImage(gradientRGBA(60, 40))
+	quad := [4]image.Point{{20, 10}, {110, 25}, {100, 95}, {10, 80}}
+	warped := src.PerspectiveWarp(quad)
+	canvas := NewBaseImage(130, 110)
+	if _, err := canvas.Fill(warped); err != nil {
+		t.Fatal(err)
+	}
+	back := rgbaOf(canvas.Rectify(quad, 60, 40))
+	orig := rgbaOf(src)
+	//边缘抗锯齿 只比较内部
+	total, count := 0, 0
+	for y := 2; y < 38; y++ {
+		for x := 2; x < 58; x++ {
+			i := orig.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				d := absDiff(back.Pix[i+c], orig.Pix[i+c])
+				if d > 8 {
+					t.Fatalf("(%d,%d) channel %d got %d, want %d", x, y, c, back.Pix[i+c], orig.Pix[i+c])
+				}
+				total += d
+				count++
+			}
+		}
+	}
+	if mean := float64(total) / float64(count); mean > 1.5 {
+		t.Errorf("mean difference %v", mean)
+	}
+}
+
+//未指定宽高时使用对边长度的较大值
+func TestRectifyDefaultSize(t *testing.T) {
+	src := NewImage(gradientRGBA(100, 100))
+	out := src.Rectify([4]image.Point{{10, 10}, {70, 10}, {90, 50}, {0, 40}}, 0, 0)
+	//下边长90.55 右边长44.72
+	if out.Width() != 91 || out.Height() != 45 {
+		t.Errorf("size %dx%d, want 91x45", out.Width(), out.Height())
+	}
+}
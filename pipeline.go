@@ -0,0 +1,563 @@
+package imagedraw
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Pipeline 一组依次执行的图片操作 可以重复用于不同的图片
+//
+// 使用ParsePipeline从字符串中解析 操作之间用|分隔 参数之间用,分隔
+//
+//	cut:800,200,200,200|radius:20|hue:50|opacity:50|resize:192x108:cubic
+//
+// 支持的操作
+//
+//	cut:x,y,w,h              剪切方形
+//	circle:x,y,r             截取圆形
+//	ellipse:x,y,w,h          截取椭圆
+//	radius:r 或 radius:lt,rt,rb,lb 圆角
+//	resize:wxh[:type]        调整尺寸 其中一边为0时按原图比例计算
+//	fit:wxh[:type]           保持比例缩放到wxh以内
+//	thumbnail:wxh[:type]     与fit相同 但是只缩小不放大
+//	cover:wxh[:anchor]       保持比例填满wxh 超出的部分按锚点剪切
+//	contain:wxh[:#color]     保持比例缩放到wxh以内 空白处填充颜色 默认透明
+//	clip:d                   按svg路径剪切 如clip:M100 0 L200 200 H0 Z
+//	feather:r                羽化透明边缘 r为过渡的宽度
+//	rotate:deg[:type]        顺时针旋转 画布扩大以容纳整张图片 空白处透明
+//	fliph flipv transpose    水平翻转、垂直翻转、沿对角线翻转
+//	warp:x0,y0,...,x3,y3     透视变换到四边形 顺序为左上、右上、右下、左下 结果为四边形外接矩形的大小
+//	rectify:x0,y0,...,x3,y3[,w,h] 将四边形矫正为w*h的矩形 不指定时按四边形的边长计算
+//	hue:v saturation:v brightness:v 色度、饱和度、亮度 -100到100
+//	opacity:v                不透明度 0到100
+type Pipeline struct {
+	steps []pipelineStep
+}
+
+// 一个操作
+type pipelineStep struct {
+	//规范化后的字符串
+	token string
+	//在字符串中的位置
+	offset int
+	apply  func(img *Image) *Image
+	//根据输入图片的宽高计算输出图片的宽高 宽高未知时为-1 为nil时与输入相同
+	size func(w, h int) (int, int)
+	//坐标、半径等参数绝对值的最大值
+	extent float64
+}
+
+// PipelineError 解析失败时返回的错误 Token为出错的操作 Offset为其在字符串中的位置
+type PipelineError struct {
+	Token  string
+	Offset int
+	Err    error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("pipeline: %q at offset %d: %v", e.Token, e.Offset, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// 操作名称对应的解析函数 args为:之后的部分 返回的操作中只需要设置apply、size和extent
+var pipelineOps = map[string]func(args string) (pipelineStep, error){
+	"cut": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 4)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		if v[2] <= 0 || v[3] <= 0 {
+			return pipelineStep{}, fmt.Errorf("width and height must be positive")
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Cut(v[0], v[1], v[2], v[3]) },
+			size:   pipelineFixedSize(v[2], v[3]),
+			extent: pipelineExtent(v),
+		}, nil
+	},
+	"circle": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 3)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		if v[2] <= 0 {
+			return pipelineStep{}, fmt.Errorf("radius must be positive")
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Circle(v[0], v[1], v[2]) },
+			size:   pipelineFixedSize(2*v[2], 2*v[2]),
+			extent: pipelineExtent(v),
+		}, nil
+	},
+	"ellipse": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 4)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		if v[2] <= 0 || v[3] <= 0 {
+			return pipelineStep{}, fmt.Errorf("semi-axes must be positive")
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Ellipse(v[0], v[1], v[2], v[3]) },
+			size:   pipelineFixedSize(2*v[2], 2*v[3]),
+			extent: pipelineExtent(v),
+		}, nil
+	},
+	"radius": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 1, 4)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		if len(v) == 1 {
+			v = []int{v[0], v[0], v[0], v[0]}
+		}
+		for _, r := range v {
+			if r < 0 {
+				return pipelineStep{}, fmt.Errorf("radius must not be negative")
+			}
+		}
+		return pipelineStep{
+			apply: func(img *Image) *Image {
+				return img.BorderRadius(uint(v[0]), uint(v[1]), uint(v[2]), uint(v[3]))
+			},
+			extent: pipelineExtent(v),
+		}, nil
+	},
+	"resize": func(args string) (pipelineStep, error) {
+		w, h, rest, err := pipelineSize(args, true)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		resizeType, err := pipelineResizeType(rest)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		return pipelineStep{
+			apply: func(img *Image) *Image { return img.Resize(w, h, resizeType) },
+			size: func(iw, ih int) (int, int) {
+				if iw < 0 || ih < 0 {
+					return pipelineBoundSize(w, h)
+				}
+				return keepAspectSize(iw, ih, w, h)
+			},
+			extent: float64(maxInt(w, h)),
+		}, nil
+	},
+	"fit": func(args string) (pipelineStep, error) {
+		w, h, rest, err := pipelineSize(args, true)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		resizeType, err := pipelineResizeType(rest)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		return pipelineStep{
+			apply: func(img *Image) *Image { return img.Fit(w, h, resizeType) },
+			size: func(iw, ih int) (int, int) {
+				if iw < 0 || ih < 0 {
+					return pipelineBoundSize(w, h)
+				}
+				return fitSize(iw, ih, w, h)
+			},
+			extent: float64(maxInt(w, h)),
+		}, nil
+	},
+	"thumbnail": func(args string) (pipelineStep, error) {
+		w, h, rest, err := pipelineSize(args, true)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		resizeType, err := pipelineResizeType(rest)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		return pipelineStep{
+			apply: func(img *Image) *Image { return img.Thumbnail(w, h, resizeType) },
+			size: func(iw, ih int) (int, int) {
+				if iw < 0 || ih < 0 {
+					return pipelineBoundSize(w, h)
+				}
+				if (w <= 0 || iw <= w) && (h <= 0 || ih <= h) {
+					return iw, ih
+				}
+				return fitSize(iw, ih, w, h)
+			},
+			extent: float64(maxInt(w, h)),
+		}, nil
+	},
+	"cover": func(args string) (pipelineStep, error) {
+		w, h, rest, err := pipelineSize(args, false)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		anchor := AnchorCenter
+		if rest != "" {
+			if anchor, err = ParseAnchor(rest); err != nil {
+				return pipelineStep{}, err
+			}
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Cover(w, h, anchor) },
+			size:   pipelineFixedSize(w, h),
+			extent: float64(maxInt(w, h)),
+		}, nil
+	},
+	"contain": func(args string) (pipelineStep, error) {
+		w, h, rest, err := pipelineSize(args, false)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		background := color.NRGBA{}
+		if rest != "" {
+			if background, err = ParseColor(rest); err != nil {
+				return pipelineStep{}, err
+			}
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Contain(w, h, background, AnchorCenter) },
+			size:   pipelineFixedSize(w, h),
+			extent: float64(maxInt(w, h)),
+		}, nil
+	},
+	"clip": func(args string) (pipelineStep, error) {
+		path, err := ParseSVGPath(args)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.ClipPath(path) },
+			extent: path.extent(),
+		}, nil
+	},
+	"feather": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 1)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		if v[0] < 0 {
+			return pipelineStep{}, fmt.Errorf("feather radius must not be negative, got %d", v[0])
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Feather(float64(v[0])) },
+			extent: float64(v[0]),
+		}, nil
+	},
+	"rotate": func(args string) (pipelineStep, error) {
+		deg, rest := args, ""
+		if i := strings.Index(args, ":"); i >= 0 {
+			deg, rest = args[:i], args[i+1:]
+		}
+		v, err := strconv.ParseFloat(deg, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return pipelineStep{}, fmt.Errorf("invalid angle %q", deg)
+		}
+		resizeType, err := pipelineResizeType(rest)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		return pipelineStep{
+			apply: func(img *Image) *Image { return img.Rotate(v, TransformOptions{ResizeType: resizeType}) },
+			size: func(iw, ih int) (int, int) {
+				if iw < 0 || ih < 0 {
+					return -1, -1
+				}
+				return rotatedSize(iw, ih, v)
+			},
+		}, nil
+	},
+	"fliph":     pipelineNoArgs(func(img *Image) *Image { return img.FlipH() }, nil),
+	"flipv":     pipelineNoArgs(func(img *Image) *Image { return img.FlipV() }, nil),
+	"transpose": pipelineNoArgs(func(img *Image) *Image { return img.Transpose() }, func(w, h int) (int, int) { return h, w }),
+	"warp": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 8)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		quad := pipelineQuad(v)
+		bounds := quadBounds(quad)
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.PerspectiveWarp(quad) },
+			size:   pipelineFixedSize(bounds.Dx(), bounds.Dy()),
+			extent: pipelineExtent(v),
+		}, nil
+	},
+	"rectify": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 8, 10)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		quad := pipelineQuad(v)
+		w, h := 0, 0
+		if len(v) == 10 {
+			if w, h = v[8], v[9]; w <= 0 || h <= 0 {
+				return pipelineStep{}, fmt.Errorf("width and height must be positive")
+			}
+		}
+		//与Rectify相同 未指定时使用四边形的边长
+		rw, rh := quadSize(quad)
+		if w > 0 {
+			rw, rh = w, h
+		}
+		return pipelineStep{
+			apply:  func(img *Image) *Image { return img.Rectify(quad, w, h) },
+			size:   pipelineFixedSize(rw, rh),
+			extent: pipelineExtent(v),
+		}, nil
+	},
+	"hue":        pipelineColorOp(func(img *Image, v float64) *Image { return img.Hue(v) }),
+	"saturation": pipelineColorOp(func(img *Image, v float64) *Image { return img.Saturation(v) }),
+	"brightness": pipelineColorOp(func(img *Image, v float64) *Image { return img.Brightness(v) }),
+	"opacity": func(args string) (pipelineStep, error) {
+		v, err := pipelineInts(args, 1)
+		if err != nil {
+			return pipelineStep{}, err
+		}
+		if v[0] < 0 || v[0] > 100 {
+			return pipelineStep{}, fmt.Errorf("opacity must be between 0 and 100, got %d", v[0])
+		}
+		return pipelineStep{apply: func(img *Image) *Image { return img.Opacity(uint32(v[0])) }}, nil
+	},
+}
+
+// 输出固定大小的操作
+func pipelineFixedSize(w, h int) func(int, int) (int, int) {
+	return func(int, int) (int, int) { return w, h }
+}
+
+// 输入大小未知时resize、fit、thumbnail的输出大小 为0的一边按比例计算 结果未知
+func pipelineBoundSize(w, h int) (int, int) {
+	if w == 0 {
+		w = -1
+	}
+	if h == 0 {
+		h = -1
+	}
+	return w, h
+}
+
+// 参数绝对值的最大值
+func pipelineExtent(v []int) float64 {
+	extent := 0
+	for _, n := range v {
+		if n < 0 {
+			n = -n
+		}
+		extent = maxInt(extent, n)
+	}
+	return float64(extent)
+}
+
+// 解析WxH 返回:之后剩余的部分 allowZero为true时其中一边可以为0
+func pipelineSize(args string, allowZero bool) (int, int, string, error) {
+	size, rest := args, ""
+	if i := strings.Index(args, ":"); i >= 0 {
+		size, rest = args[:i], args[i+1:]
+	}
+	wh := strings.Split(size, "x")
+	if len(wh) != 2 {
+		return 0, 0, "", fmt.Errorf("size must be WIDTHxHEIGHT, got %q", size)
+	}
+	w, err := strconv.Atoi(wh[0])
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("invalid width %q", wh[0])
+	}
+	h, err := strconv.Atoi(wh[1])
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("invalid height %q", wh[1])
+	}
+	if allowZero {
+		if w < 0 || h < 0 || (w == 0 && h == 0) {
+			return 0, 0, "", fmt.Errorf("width and height must not be negative and at most one may be 0")
+		}
+	} else if w <= 0 || h <= 0 {
+		return 0, 0, "", fmt.Errorf("width and height must be positive")
+	}
+	return w, h, rest, nil
+}
+
+// 缩放方式 为空时使用双线性插值
+func pipelineResizeType(name string) (ResizeType, error) {
+	if name == "" {
+		return BilinearInterpolation, nil
+	}
+	return ParseResizeType(name)
+}
+
+// 色度、饱和度、亮度
+func pipelineColorOp(fn func(img *Image, v float64) *Image) func(args string) (pipelineStep, error) {
+	return func(args string) (pipelineStep, error) {
+		v, err := strconv.ParseFloat(args, 64)
+		if err != nil {
+			return pipelineStep{}, fmt.Errorf("invalid number %q", args)
+		}
+		if v < -100 || v > 100 {
+			return pipelineStep{}, fmt.Errorf("must be between -100 and 100, got %v", v)
+		}
+		return pipelineStep{apply: func(img *Image) *Image { return fn(img, v) }}, nil
+	}
+}
+
+// 四边形的四个点
+func pipelineQuad(v []int) [4]image.Point {
+	return [4]image.Point{{v[0], v[1]}, {v[2], v[3]}, {v[4], v[5]}, {v[6], v[7]}}
+}
+
+// 没有参数的操作 size为nil时输出大小不变
+func pipelineNoArgs(fn func(img *Image) *Image, size func(w, h int) (int, int)) func(args string) (pipelineStep, error) {
+	return func(args string) (pipelineStep, error) {
+		if args != "" {
+			return pipelineStep{}, fmt.Errorf("expected no arguments, got %q", args)
+		}
+		return pipelineStep{apply: fn, size: size}, nil
+	}
+}
+
+// 解析逗号分隔的整数 counts为允许的参数个数
+func pipelineInts(args string, counts ...int) ([]int, error) {
+	list := strings.Split(args, ",")
+	ok := false
+	for _, n := range counts {
+		ok = ok || len(list) == n
+	}
+	if !ok || args == "" {
+		return nil, fmt.Errorf("expected %s arguments, got %q", joinInts(counts, " or "), args)
+	}
+	v := make([]int, len(list))
+	for i, s := range list {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func joinInts(list []int, sep string) string {
+	s := make([]string, len(list))
+	for i, n := range list {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, sep)
+}
+
+// ParsePipeline 解析操作字符串 任何一个操作不合法都会返回*PipelineError
+func ParsePipeline(s string) (*Pipeline, error) {
+	p := &Pipeline{}
+	offset := 0
+	for _, raw := range strings.Split(s, "|") {
+		tokenOffset := offset + len(raw) - len(strings.TrimLeft(raw, " \t"))
+		offset += len(raw) + 1
+		token := strings.TrimSpace(raw)
+		if token == "" {
+			if strings.TrimSpace(s) == "" {
+				break
+			}
+			return nil, &PipelineError{Token: token, Offset: tokenOffset, Err: fmt.Errorf("empty operation")}
+		}
+		name, args := token, ""
+		if i := strings.Index(token, ":"); i >= 0 {
+			name, args = token[:i], token[i+1:]
+		}
+		parse, ok := pipelineOps[name]
+		if !ok {
+			return nil, &PipelineError{Token: token, Offset: tokenOffset, Err: fmt.Errorf("unknown operation %q", name)}
+		}
+		step, err := parse(args)
+		if err != nil {
+			return nil, &PipelineError{Token: token, Offset: tokenOffset, Err: err}
+		}
+		step.token, step.offset = token, tokenOffset
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// MustParsePipeline 解析操作字符串 失败时panic
+func MustParsePipeline(s string) *Pipeline {
+	p, err := ParsePipeline(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Apply 对图片依次执行所有操作并返回一个新的对象
+func (p *Pipeline) Apply(img *Image) *Image {
+	for _, step := range p.steps {
+		img = step.apply(img)
+	}
+	return img
+}
+
+// CheckSize 依次计算每个改变图片大小的操作输出图片的宽高并交给check检查 可以在执行之前限制内存的使用
+//
+// w、h为输入图片的宽高 未知时传-1 此时只能计算出不依赖输入大小的操作 无法计算的宽高为-1
+// check返回错误时停止 返回*PipelineError
+func (p *Pipeline) CheckSize(w, h int, check func(w, h int) error) error {
+	for _, step := range p.steps {
+		if step.size == nil {
+			continue
+		}
+		w, h = step.size(w, h)
+		if err := check(w, h); err != nil {
+			return &PipelineError{Token: step.token, Offset: step.offset, Err: err}
+		}
+	}
+	return nil
+}
+
+//坐标、半径等参数绝对值的最大值
+func (p *Pipeline) extent() float64 {
+	extent := 0.0
+	for _, step := range p.steps {
+		extent = math.Max(extent, step.extent)
+	}
+	return extent
+}
+
+// Len 返回操作的数量
+func (p *Pipeline) Len() int {
+	return len(p.steps)
+}
+
+// String 返回操作字符串
+func (p *Pipeline) String() string {
+	tokens := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		tokens[i] = step.token
+	}
+	return strings.Join(tokens, "|")
+}
+
+// MarshalText 实现encoding.TextMarshaler 可以直接保存在json等配置中
+func (p *Pipeline) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText 实现encoding.TextUnmarshaler
+func (p *Pipeline) UnmarshalText(text []byte) error {
+	parsed, err := ParsePipeline(string(text))
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
+
+// Pipe 对图片执行操作字符串并返回一个新的对象
+func (i *Image) Pipe(s string) (*Image, error) {
+	p, err := ParsePipeline(s)
+	if err != nil {
+		return nil, err
+	}
+	return p.Apply(i), nil
+}
@@ -0,0 +1,118 @@
+package imagedraw
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPipelineCheckSizeMatchesApply(t *testing.T) {
+	tests := []string{
+		"cut:10,10,30,20",
+		"circle:20,20,15",
+		"ellipse:20,20,15,7",
+		"resize:0x13",
+		"resize:17x0",
+		"fit:25x25",
+		"fit:0x9",
+		"thumbnail:100x100",
+		"thumbnail:20x0",
+		"cover:33x11",
+		"contain:33x11",
+		"rotate:30",
+		"rotate:-90",
+		"transpose",
+		"warp:5,5,60,0,50,40,0,30",
+		"rectify:0,0,30,2,28,20,1,18",
+		"rectify:0,0,30,2,28,20,1,18,12,8",
+		"clip:M0 0 L10 0 L10 10 Z|feather:2|rotate:100|cover:9x9|rotate:1",
+	}
+	for _, s := range tests {
+		p := MustParsePipeline(s)
+		src := NewBaseImage(64, 48)
+		var w, h int
+		if err := p.CheckSize(src.Width(), src.Height(), func(cw, ch int) error {
+			w, h = cw, ch
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		out := p.Apply(src)
+		if w == 0 && h == 0 {
+			w, h = src.Width(), src.Height()
+		}
+		if out.Width() != w || out.Height() != h {
+			t.Errorf("%s: CheckSize got %dx%d, Apply got %dx%d", s, w, h, out.Width(), out.Height())
+		}
+	}
+}
+
+func TestPipelineCheckSizeUnknownInput(t *testing.T) {
+	var sizes [][2]int
+	p := MustParsePipeline("resize:0x100|rotate:10|cover:30x20|fliph|fit:0x10")
+	if err := p.CheckSize(-1, -1, func(w, h int) error {
+		sizes = append(sizes, [2]int{w, h})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int{{-1, 100}, {-1, -1}, {30, 20}, {15, 10}}
+	if len(sizes) != len(want) {
+		t.Fatalf("got %v, want %v", sizes, want)
+	}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Errorf("got %v, want %v", sizes, want)
+			break
+		}
+	}
+}
+
+func TestParsePipelineError(t *testing.T) {
+	tests := []struct {
+		s      string
+		token  string
+		offset int
+	}{
+		{"blur:3", "blur:3", 0},
+		{"cut:1,2", "cut:1,2", 0},
+		{"cut:0,0,0,10", "cut:0,0,0,10", 0},
+		{"circle:a,1,2", "circle:a,1,2", 0},
+		{"radius:1,2", "radius:1,2", 0},
+		{"radius:-1", "radius:-1", 0},
+		{"resize:10", "resize:10", 0},
+		{"resize:0x0", "resize:0x0", 0},
+		{"resize:10x10:sinc", "resize:10x10:sinc", 0},
+		{"fit:-1x10", "fit:-1x10", 0},
+		{"cover:10x0", "cover:10x0", 0},
+		{"rotate:NaN", "rotate:NaN", 0},
+		{"fliph:1", "fliph:1", 0},
+		{"hue:101", "hue:101", 0},
+		{"opacity:-1", "opacity:-1", 0},
+		{"circle:5,5,5|", "", 13},
+		{"fliph||flipv", "", 6},
+		{"fliph|cut:1,2,3", "cut:1,2,3", 6},
+		{"fliph| \tflipv:x", "flipv:x", 8},
+		{"  resize:10x10 |  rotate:abc ", "rotate:abc", 18},
+	}
+	for _, tt := range tests {
+		_, err := ParsePipeline(tt.s)
+		var pe *PipelineError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: got %v, want *PipelineError", tt.s, err)
+			continue
+		}
+		if pe.Token != tt.token || pe.Offset != tt.offset {
+			t.Errorf("%q: got token %q at %d, want %q at %d", tt.s, pe.Token, pe.Offset, tt.token, tt.offset)
+		}
+		if pe.Err == nil || errors.Unwrap(pe) != pe.Err {
+			t.Errorf("%q: got no underlying error", tt.s)
+		}
+	}
+
+	//空字符串和只有空白的字符串是不含操作的管道
+	for _, s := range []string{"", "  "} {
+		if p, err := ParsePipeline(s); err != nil || len(p.steps) != 0 {
+			t.Errorf("%q: got %v, want an empty pipeline", s, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+//每个像素纵向的采样数 横向按交点的精确位置计算覆盖率
+const rasterSubsamples = 16
+
+//折线的一条边 dir为1表示向下 -1表示向上
+type rasterEdge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+//交点
+type rasterCrossing struct {
+	x   float64
+	dir int
+}
+
+//计算路径对w*h范围内每个像素的覆盖率 0到1
+func rasterizePath(p *Path, w, h int) []float64 {
+	coverage := make([]float64, w*h)
+	var edges []rasterEdge
+	for _, contour := range p.flatten() {
+		for i := range contour {
+			a, b := contour[i], contour[(i+1)%len(contour)]
+			if a.y == b.y {
+				continue
+			}
+			if a.y < b.y {
+				edges = append(edges, rasterEdge{a.x, a.y, b.x, b.y, 1})
+			} else {
+				edges = append(edges, rasterEdge{b.x, b.y, a.x, a.y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return coverage
+	}
+	rule := p.fillRule
+	parallelRows(0, h, w, func(from, to int) {
+		//只保留与这些行相交的边
+		var bandEdges []rasterEdge
+		for _, e := range edges {
+			if e.y1 > float64(from) && e.y0 < float64(to) {
+				bandEdges = append(bandEdges, e)
+			}
+		}
+		var rowEdges []rasterEdge
+		var crossings []rasterCrossing
+		for y := from; y < to; y++ {
+			rowEdges = rowEdges[:0]
+			for _, e := range bandEdges {
+				if e.y1 > float64(y) && e.y0 < float64(y+1) {
+					rowEdges = append(rowEdges, e)
+				}
+			}
+			if len(rowEdges) == 0 {
+				continue
+			}
+			row := coverage[y*w : (y+1)*w]
+			for k := 0; k < rasterSubsamples; k++ {
+				sy := float64(y) + (float64(k)+0.5)/rasterSubsamples
+				crossings = crossings[:0]
+				for _, e := range rowEdges {
+					if sy >= e.y0 && sy < e.y1 {
+						x := e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+						crossings = append(crossings, rasterCrossing{x, e.dir})
+					}
+				}
+				sort.Slice(crossings, func(i, j int) bool {
+					return crossings[i].x < crossings[j].x
+				})
+				winding := 0
+				for i, c := range crossings {
+					winding += c.dir
+					inside := winding != 0
+					if rule == EvenOdd {
+						inside = (i+1)%2 == 1
+					}
+					if inside && i+1 < len(crossings) {
+						addSpan(row, c.x, crossings[i+1].x, 1.0/rasterSubsamples)
+					}
+				}
+			}
+		}
+	})
+	return coverage
+}
+
+//将[x0,x1)的覆盖率加到row上 边缘的像素按被覆盖的长度计算
+func addSpan(row []float64, x0, x1, weight float64) {
+	w := float64(len(row))
+	x0 = math.Max(0, x0)
+	x1 = math.Min(w, x1)
+	if x1 <= x0 {
+		return
+	}
+	i0, i1 := int(x0), int(x1)
+	if i0 == i1 {
+		row[i0] += (x1 - x0) * weight
+		return
+	}
+	row[i0] += (float64(i0+1) - x0) * weight
+	for i := i0 + 1; i < i1; i++ {
+		row[i] += weight
+	}
+	if i1 < len(row) {
+		row[i1] += (x1 - float64(i1)) * weight
+	}
+}
+
+//按路径剪切 路径外的部分透明 边缘抗锯齿
+func clipPath(img image.Image, p *Path) draw.Image {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	r := src.Rect
+	w, h := r.Dx(), r.Dy()
+	//路径的坐标相对于图片左上角
+	coverage := rasterizePath(p, w, h)
+	clipped := image.NewRGBA(r)
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			for x := 0; x < w; x++ {
+				c := clamp01(coverage[y*w+x])
+				if c <= 0 {
+					continue
+				}
+				i := src.PixOffset(r.Min.X+x, r.Min.Y+y)
+				j := clipped.PixOffset(r.Min.X+x, r.Min.Y+y)
+				copyCoverage(clipped.Pix[j:j+4], src.Pix[i:i+4], c)
+			}
+		}
+	})
+	return clipped
+}
+
+//使用color按混合模式填充路径 路径坐标为dst上的坐标 只绘制在rc的裁剪区域内
+func fillPath(dst draw.Image, rc *RenderContext, p *Path, c color.Color, mode BlendMode) {
+	clipDst := rc.ClipImage(dst)
+	r := p.bounds().Intersect(clipDst.Bounds())
+	if r.Empty() {
+		return
+	}
+	coverage := rasterizePath(p.Transform(TranslateMatrix(-float64(r.Min.X), -float64(r.Min.Y))), r.Dx(), r.Dy())
+	mask := image.NewAlpha(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for i, v := range coverage {
+		mask.Pix[i] = uint8(clamp01(v)*255 + 0.5)
+	}
+	composite(clipDst, r, image.NewUniform(c), image.Point{}, mask, image.Point{}, draw.Over, mode, rc.linear())
+}
@@ -0,0 +1,267 @@
+package imagedraw
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// DefaultCubicA 三次卷积插值默认的样条参数a
+const DefaultCubicA = -1.0
+
+//一个目标像素由哪些源像素按什么权重组成
+type resampleTap struct {
+	index  []int
+	weight []float64
+}
+
+//三次卷积核 a取不同值时可以用来逼近不同的样条函数（常用值-0.5, -0.75）
+func cubicKernel(a float64) func(x float64) float64 {
+	return func(x float64) float64 {
+		x = math.Abs(x)
+		switch {
+		case x >= 2:
+			return 0
+		case x >= 1:
+			return a * (((x-5)*x+8)*x - 4)
+		default:
+			return ((a+2)*x-(a+3))*x*x + 1
+		}
+	}
+}
+
+//Mitchell-Netravali的BC样条 B=0 C=0.5时为Catmull-Rom
+func bcSplineKernel(b, c float64) func(x float64) float64 {
+	return func(x float64) float64 {
+		x = math.Abs(x)
+		switch {
+		case x >= 2:
+			return 0
+		case x >= 1:
+			return ((((-b-6*c)*x+(6*b+30*c))*x+(-12*b-48*c))*x + (8*b + 24*c)) / 6
+		default:
+			return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+		}
+	}
+}
+
+//三角形(线性插值)卷积核 边缘取最近的像素 不会像bilinearInterpolation一样在右边和下边变透明
+func triangleKernel(x float64) float64 {
+	x = math.Abs(x)
+	if x >= 1 {
+		return 0
+	}
+	return 1 - x
+}
+
+//Lanczos窗口函数 a为窗口大小
+func lanczosKernel(a float64) func(x float64) float64 {
+	return func(x float64) float64 {
+		x = math.Abs(x)
+		if x >= a {
+			return 0
+		}
+		return sinc(x) * sinc(x/a)
+	}
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+//根据源和目标的长度计算每个目标坐标的权重
+type resampleFilter func(srcLen, dstLen int) []resampleTap
+
+//超出边界的源坐标取最近的边缘像素
+func clampIndex(index, length int) int {
+	if index < 0 {
+		return 0
+	}
+	if index >= length {
+		return length - 1
+	}
+	return index
+}
+
+//卷积核 缩小时按比例放大支撑范围 起到低通滤波的作用 权重之和为1
+func kernelFilter(support float64, kernel func(x float64) float64) resampleFilter {
+	return func(srcLen, dstLen int) []resampleTap {
+		taps := make([]resampleTap, dstLen)
+		scale := float64(srcLen) / float64(dstLen)
+		filterScale := math.Max(scale, 1)
+		radius := support * filterScale
+		for i := range taps {
+			//以像素中心对齐
+			center := (float64(i)+0.5)*scale - 0.5
+			start := int(math.Floor(center-radius)) + 1
+			end := int(math.Floor(center + radius))
+			tap := resampleTap{
+				index:  make([]int, 0, end-start+1),
+				weight: make([]float64, 0, end-start+1),
+			}
+			sum := 0.0
+			for j := start; j <= end; j++ {
+				w := kernel((center - float64(j)) / filterScale)
+				if w == 0 {
+					continue
+				}
+				tap.index = append(tap.index, clampIndex(j, srcLen))
+				tap.weight = append(tap.weight, w)
+				sum += w
+			}
+			if sum != 0 {
+				for k := range tap.weight {
+					tap.weight[k] /= sum
+				}
+			}
+			taps[i] = tap
+		}
+		return taps
+	}
+}
+
+//最近邻 每个目标像素取中心所在的源像素
+func nearestFilter(srcLen, dstLen int) []resampleTap {
+	taps := make([]resampleTap, dstLen)
+	scale := float64(srcLen) / float64(dstLen)
+	for i := range taps {
+		index := clampIndex(int((float64(i)+0.5)*scale), srcLen)
+		taps[i] = resampleTap{index: []int{index}, weight: []float64{1}}
+	}
+	return taps
+}
+
+//区域平均 每个源像素的权重为其与目标像素覆盖范围重叠的长度
+func areaFilter(srcLen, dstLen int) []resampleTap {
+	taps := make([]resampleTap, dstLen)
+	scale := float64(srcLen) / float64(dstLen)
+	for i := range taps {
+		lo := float64(i) * scale
+		hi := float64(i+1) * scale
+		var tap resampleTap
+		for j := int(math.Floor(lo)); float64(j) < hi; j++ {
+			overlap := math.Min(hi, float64(j+1)) - math.Max(lo, float64(j))
+			if overlap <= 0 {
+				continue
+			}
+			tap.index = append(tap.index, clampIndex(j, srcLen))
+			tap.weight = append(tap.weight, overlap/scale)
+		}
+		taps[i] = tap
+	}
+	return taps
+}
+
+//可分离的两次卷积 filter决定每个目标像素由哪些源像素组成 先横向缩放到w 再纵向缩放到h 颜色在预乘alpha的空间中计算
+//linear为true时先将每行转换为线性光 计算后再转换回sRGB
+func resample(img image.Image, w, h int, filter resampleFilter, linear bool) draw.Image {
+	if w <= 0 || h <= 0 {
+		return image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	newImage := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if sw <= 0 || sh <= 0 {
+		return newImage
+	}
+	xTaps := filter(sw, w)
+	yTaps := filter(sh, h)
+
+	//横向 每一行源像素缩放为w个
+	tmp := make([]float64, w*sh*4)
+	parallelRows(0, sh, w, func(from, to int) {
+		line := make([]float64, sw*4)
+		for y := from; y < to; y++ {
+			row := src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y):]
+			decodeRow(line, row[:sw*4], linear)
+			out := tmp[y*w*4 : (y+1)*w*4]
+			for x, tap := range xTaps {
+				var r, g, b, a float64
+				for k, index := range tap.index {
+					p := line[index*4 : index*4+4 : index*4+4]
+					weight := tap.weight[k]
+					r += p[0] * weight
+					g += p[1] * weight
+					b += p[2] * weight
+					a += p[3] * weight
+				}
+				out[x*4+0], out[x*4+1], out[x*4+2], out[x*4+3] = r, g, b, a
+			}
+		}
+	})
+
+	//纵向
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			tap := yTaps[y]
+			dst := newImage.Pix[newImage.PixOffset(0, y):]
+			for x := 0; x < w; x++ {
+				var r, g, b, a float64
+				for k, index := range tap.index {
+					p := tmp[(index*w+x)*4 : (index*w+x)*4+4 : (index*w+x)*4+4]
+					weight := tap.weight[k]
+					r += p[0] * weight
+					g += p[1] * weight
+					b += p[2] * weight
+					a += p[3] * weight
+				}
+				q := dst[x*4 : x*4+4 : x*4+4]
+				if linear {
+					setSRGBPixel(q, [4]float64{r / 255, g / 255, b / 255, a / 255})
+					continue
+				}
+				//超调的部分截断 预乘alpha的颜色不能大于alpha
+				a8 := clampUint8(a)
+				q[0] = minUint8(clampUint8(r), a8)
+				q[1] = minUint8(clampUint8(g), a8)
+				q[2] = minUint8(clampUint8(b), a8)
+				q[3] = a8
+			}
+		}
+	})
+	return newImage
+}
+
+//将一行像素转换为0-255的浮点数 linear为true时转换为线性光
+func decodeRow(dst []float64, row []uint8, linear bool) {
+	if !linear {
+		for k, v := range row {
+			dst[k] = float64(v)
+		}
+		return
+	}
+	for k := 0; k < len(row); k += 4 {
+		c := linearPixel(row[k : k+4 : k+4])
+		dst[k], dst[k+1], dst[k+2], dst[k+3] = c[0]*255, c[1]*255, c[2]*255, c[3]*255
+	}
+}
+
+//四舍五入并限制在0-255
+func clampUint8(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+//三次卷积插值
+func cubicConvolution(img image.Image, w, h int, a float64, linear bool) draw.Image {
+	return resample(img, w, h, kernelFilter(2, cubicKernel(a)), linear)
+}
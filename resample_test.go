@@ -0,0 +1,134 @@
+package imagedraw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+var resampleFilters = map[string]resampleFilter{
+	"lanczos3":   kernelFilter(3, lanczosKernel(3)),
+	"mitchell":   kernelFilter(2, bcSplineKernel(1.0/3, 1.0/3)),
+	"catmullrom": kernelFilter(2, bcSplineKernel(0, 0.5)),
+	"triangle":   kernelFilter(1, triangleKernel),
+	"nearest":    nearestFilter,
+	"area":       areaFilter,
+}
+
+//每个目标像素的权重之和为1 源坐标都在范围内
+func TestResampleFilterWeights(t *testing.T) {
+	for name, filter := range resampleFilters {
+		for _, size := range [][2]int{{10, 3}, {3, 10}, {7, 7}, {1, 5}, {100, 1}} {
+			for i, tap := range filter(size[0], size[1]) {
+				sum := 0.0
+				for k, index := range tap.index {
+					if index < 0 || index >= size[0] {
+						t.Fatalf("%s %v: tap %d index %d out of range", name, size, i, index)
+					}
+					sum += tap.weight[k]
+				}
+				if math.Abs(sum-1) > 1e-9 {
+					t.Errorf("%s %v: tap %d weights sum to %v", name, size, i, sum)
+				}
+			}
+		}
+	}
+}
+
+//纯色图片缩放后颜色不变 双线性插值在右边和下边与图片外的透明像素插值 不在此列
+func TestResizeKeepsUniformColor(t *testing.T) {
+	c := color.RGBA{R: 120, G: 60, B: 30, A: 200}
+	src := image.NewRGBA(image.Rect(0, 0, 13, 9))
+	draw.Draw(src, src.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	for name, resizeType := range resizeTypeNames {
+		if resizeType == BilinearInterpolation {
+			continue
+		}
+		for _, size := range [][2]int{{5, 4}, {31, 20}} {
+			for _, linear := range []bool{false, true} {
+				dst := resize(src, size[0], size[1], resizeType, linear).(*image.RGBA)
+				if dst.Rect.Dx() != size[0] || dst.Rect.Dy() != size[1] {
+					t.Fatalf("%s: size %v, want %v", name, dst.Rect.Size(), size)
+				}
+				for y := 0; y < size[1]; y++ {
+					for x := 0; x < size[0]; x++ {
+						got := dst.RGBAAt(x, y)
+						if absDiff(got.R, c.R) > 1 || absDiff(got.G, c.G) > 1 || absDiff(got.B, c.B) > 1 || absDiff(got.A, c.A) > 1 {
+							t.Fatalf("%s %v linear %v: (%d,%d) got %v, want %v", name, size, linear, x, y, got, c)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+//灰度图片 v为每个像素的值
+func grayRGBA(w, h int, v ...uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, g := range v {
+		img.SetRGBA(i%w, i/w, color.RGBA{R: g, G: g, B: g, A: 255})
+	}
+	return img
+}
+
+func TestResizeAreaAverage(t *testing.T) {
+	src := grayRGBA(4, 2,
+		0, 40, 100, 100,
+		80, 120, 200, 0,
+	)
+	dst := resample(src, 2, 1, areaFilter, false).(*image.RGBA)
+	for x, want := range []uint8{60, 100} {
+		if got := dst.RGBAAt(x, 0); got.R != want || got.A != 255 {
+			t.Errorf("(%d,0) got %v, want %d", x, got, want)
+		}
+	}
+}
+
+func TestResizeNearest(t *testing.T) {
+	src := grayRGBA(2, 2, 10, 20, 30, 40)
+	up := resample(src, 4, 4, nearestFilter, false).(*image.RGBA)
+	want := grayRGBA(4, 4,
+		10, 10, 20, 20,
+		10, 10, 20, 20,
+		30, 30, 40, 40,
+		30, 30, 40, 40,
+	)
+	if !bytes.Equal(up.Pix, want.Pix) {
+		t.Errorf("nearest 2x got %v", up.Pix)
+	}
+	//缩小回原尺寸得到原图
+	if down := resample(up, 2, 2, nearestFilter, false).(*image.RGBA); !bytes.Equal(down.Pix, src.Pix) {
+		t.Errorf("nearest 0.5x got %v", down.Pix)
+	}
+}
+
+//插值的卷积核在整数位置为0 尺寸不变时结果与原图相同
+func TestResizeSameSizeInterpolating(t *testing.T) {
+	src := randomRGBA(17, 11, 5)
+	for _, resizeType := range []ResizeType{Lanczos3, CatmullRom, NearestNeighbor, AreaAverage} {
+		dst := resize(src, 17, 11, resizeType, false).(*image.RGBA)
+		if !bytes.Equal(dst.Pix, src.Pix) {
+			t.Errorf("resize type %d: same-size resize changed the image", resizeType)
+		}
+	}
+}
+
+func TestParseResizeType(t *testing.T) {
+	if r, err := ParseResizeType("Lanczos"); err != nil || r != Lanczos3 {
+		t.Errorf("got %v, %v", r, err)
+	}
+	if _, err := ParseResizeType("sinc"); err == nil {
+		t.Error("unknown resize type accepted")
+	}
+}
@@ -0,0 +1,270 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+//以下函数直接读写*image.RGBA的Pix 避免每个像素的At、Set调用和颜色模型转换
+//结果与image.go中的通用实现逐字节相同 包括超出图片范围的像素按透明处理
+
+//像素在Pix中的下标 超出范围时返回-1
+func rgbaOffset(img *image.RGBA, x, y int) int {
+	if !(image.Point{X: x, Y: y}.In(img.Rect)) {
+		return -1
+	}
+	return img.PixOffset(x, y)
+}
+
+//通用实现中从0开始遍历到Bounds().Max 只有坐标不为负数的部分会被处理
+func positiveRect(r image.Rectangle) image.Rectangle {
+	if r.Max.X <= 0 || r.Max.Y <= 0 {
+		return image.Rectangle{}
+	}
+	return r.Intersect(image.Rect(0, 0, r.Max.X, r.Max.Y))
+}
+
+//复制*image.RGBA
+func convertRGBA(img *image.RGBA) *image.RGBA {
+	newImg := image.NewRGBA(img.Rect)
+	r := positiveRect(img.Rect)
+	n := 4 * r.Dx()
+	parallelRows(r.Min.Y, r.Max.Y, r.Dx(), func(from, to int) {
+		for y := from; y < to; y++ {
+			i := img.PixOffset(r.Min.X, y)
+			j := newImg.PixOffset(r.Min.X, y)
+			copy(newImg.Pix[j:j+n], img.Pix[i:i+n])
+		}
+	})
+	return newImg
+}
+
+//*image.NRGBA转换为*image.RGBA 与color.RGBAModel的计算方式相同
+func convertNRGBA(img *image.NRGBA) *image.RGBA {
+	newImg := image.NewRGBA(img.Rect)
+	r := positiveRect(img.Rect)
+	parallelRows(r.Min.Y, r.Max.Y, r.Dx(), func(from, to int) {
+		for y := from; y < to; y++ {
+			i := img.PixOffset(r.Min.X, y)
+			j := newImg.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x, i, j = x+1, i+4, j+4 {
+				a := uint32(img.Pix[i+3])
+				a16 := a * 0x101
+				newImg.Pix[j+0] = uint8(uint32(img.Pix[i+0]) * a16 / 0xff >> 8)
+				newImg.Pix[j+1] = uint8(uint32(img.Pix[i+1]) * a16 / 0xff >> 8)
+				newImg.Pix[j+2] = uint8(uint32(img.Pix[i+2]) * a16 / 0xff >> 8)
+				newImg.Pix[j+3] = uint8(a)
+			}
+		}
+	})
+	return newImg
+}
+
+//截取方形
+func cutRGBA(img *image.RGBA, x, y, x1, y1 int) *image.RGBA {
+	cutImage := image.NewRGBA(image.Rect(0, 0, x1-x, y1-y))
+	if x1 <= x || y1 <= y {
+		return cutImage
+	}
+	r := image.Rect(x, y, x1, y1).Intersect(img.Rect)
+	n := 4 * r.Dx()
+	parallelRows(r.Min.Y, r.Max.Y, r.Dx(), func(from, to int) {
+		for dy := from; dy < to; dy++ {
+			i := img.PixOffset(r.Min.X, dy)
+			j := cutImage.PixOffset(r.Min.X-x, dy-y)
+			copy(cutImage.Pix[j:j+n], img.Pix[i:i+n])
+		}
+	})
+	return cutImage
+}
+
+//截取椭圆 边缘按像素被覆盖的比例抗锯齿
+func ellipseRGBA(img *image.RGBA, x, y, w, h int) *image.RGBA {
+	ellipse := image.NewRGBA(image.Rect(0, 0, 2*w, 2*h))
+	if w <= 0 || h <= 0 {
+		return ellipse
+	}
+	a, b := float64(w), float64(h)
+	parallelRows(0, 2*h, 2*w, func(from, to int) {
+		for y1 := from; y1 < to; y1++ {
+			for x1 := 0; x1 < 2*w; x1++ {
+				//像素中心到圆心的距离
+				coverage := ellipseCoverage(float64(x1)+0.5-a, float64(y1)+0.5-b, a, b)
+				if coverage <= 0 {
+					continue
+				}
+				if i := rgbaOffset(img, x-w+x1, y-h+y1); i >= 0 {
+					j := ellipse.PixOffset(x1, y1)
+					copyCoverage(ellipse.Pix[j:j+4], img.Pix[i:i+4], coverage)
+				}
+			}
+		}
+	})
+	return ellipse
+}
+
+//圆角 左上 右上 右下 左下 边缘按像素被覆盖的比例抗锯齿
+func borderRadiusRGBA(img *image.RGBA, lt, rt, rb, lb uint) *image.RGBA {
+	dx := img.Rect.Dx()
+	dy := img.Rect.Dy()
+	w, h := float64(dx), float64(dy)
+	//每个角的半径、圆心和角所在的方向
+	corners := []struct {
+		r, cx, cy, sx, sy float64
+	}{
+		{float64(lt), float64(lt), float64(lt), -1, -1},
+		{float64(rt), w - float64(rt), float64(rt), 1, -1},
+		{float64(rb), w - float64(rb), h - float64(rb), 1, 1},
+		{float64(lb), float64(lb), h - float64(lb), -1, 1},
+	}
+	border := image.NewRGBA(img.Rect)
+	parallelRows(0, dy, dx, func(from, to int) {
+		for y1 := from; y1 < to; y1++ {
+			for x1 := 0; x1 < dx; x1++ {
+				px, py := float64(x1)+0.5, float64(y1)+0.5
+				coverage := 1.0
+				for _, c := range corners {
+					//只有位于角的方形区域中的像素受圆角影响
+					if c.r > 0 && (px-c.cx)*c.sx > 0 && (py-c.cy)*c.sy > 0 {
+						coverage = math.Min(coverage, circleCoverage(math.Hypot(px-c.cx, py-c.cy), c.r))
+					}
+				}
+				if coverage <= 0 {
+					continue
+				}
+				if i := rgbaOffset(img, img.Rect.Min.X+x1, img.Rect.Min.Y+y1); i >= 0 {
+					j := border.PixOffset(img.Rect.Min.X+x1, img.Rect.Min.Y+y1)
+					copyCoverage(border.Pix[j:j+4], img.Pix[i:i+4], coverage)
+				}
+			}
+		}
+	})
+	return border
+}
+
+//到圆心距离为d的像素被半径为r的圆覆盖的比例 边缘1个像素内线性过渡
+func circleCoverage(d, r float64) float64 {
+	return clamp01(r - d + 0.5)
+}
+
+//(dx,dy)为像素中心到圆心的距离 a、b为半轴长 用隐函数除以梯度近似到边缘的距离
+func ellipseCoverage(dx, dy, a, b float64) float64 {
+	nx, ny := dx/a, dy/b
+	f := nx*nx + ny*ny - 1
+	gx, gy := 2*nx/a, 2*ny/b
+	g := math.Sqrt(gx*gx + gy*gy)
+	if g == 0 {
+		return 1
+	}
+	return clamp01(0.5 - f/g)
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+//复制预乘alpha的像素 coverage为保留的比例
+func copyCoverage(dst, src []uint8, coverage float64) {
+	if coverage >= 1 {
+		copy(dst[:4], src[:4])
+		return
+	}
+	for c := 0; c < 4; c++ {
+		dst[c] = uint8(float64(src[c])*coverage + 0.5)
+	}
+}
+
+//对每个像素的hsv执行fn 用于色度、饱和度、亮度
+func hsvRGBA(img *image.RGBA, fn func(hsv Hsv) Hsv) *image.RGBA {
+	newImg := image.NewRGBA(img.Rect)
+	r := positiveRect(img.Rect)
+	parallelRows(r.Min.Y, r.Max.Y, r.Dx(), func(from, to int) {
+		for y := from; y < to; y++ {
+			i := img.PixOffset(r.Min.X, y)
+			j := newImg.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x, i, j = x+1, i+4, j+4 {
+				p := img.Pix[i : i+4 : i+4]
+				c := fn(rgba2HSV(color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]})).ToRGBA()
+				q := newImg.Pix[j : j+4 : j+4]
+				q[0], q[1], q[2], q[3] = c.R, c.G, c.B, c.A
+			}
+		}
+	})
+	return newImg
+}
+
+//设置不透明度 transparency已经限制在0-100
+func opacityRGBA(img *image.RGBA, transparency uint32) *image.RGBA {
+	opacity := image.NewRGBA(img.Rect)
+	r := img.Rect
+	parallelRows(r.Min.Y, r.Max.Y, r.Dx(), func(from, to int) {
+		for y := from; y < to; y++ {
+			i := img.PixOffset(r.Min.X, y)
+			j := opacity.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x, i, j = x+1, i+4, j+4 {
+				p := img.Pix[i : i+4 : i+4]
+				//与color.NRGBA64经过color.RGBAModel转换的结果相同
+				a := uint32(p[3]) * 0x101 * transparency / 100
+				q := opacity.Pix[j : j+4 : j+4]
+				q[0] = uint8(uint32(p[0]) * 0x101 * a / 0xffff >> 8)
+				q[1] = uint8(uint32(p[1]) * 0x101 * a / 0xffff >> 8)
+				q[2] = uint8(uint32(p[2]) * 0x101 * a / 0xffff >> 8)
+				q[3] = uint8(a >> 8)
+			}
+		}
+	})
+	return opacity
+}
+
+//双线性插值
+func bilinearInterpolationRGBA(img *image.RGBA, w, h int, linear bool) *image.RGBA {
+	newImage := image.NewRGBA(image.Rect(0, 0, w, h))
+	max := img.Rect.Max
+	//读取16位的颜色值 超出范围时为0
+	at := func(x, y int) (r, g, b, a float64) {
+		i := rgbaOffset(img, x, y)
+		if i < 0 {
+			return 0, 0, 0, 0
+		}
+		p := img.Pix[i : i+4 : i+4]
+		if linear {
+			c := linearPixel(p)
+			return c[0] * 0xffff, c[1] * 0xffff, c[2] * 0xffff, c[3] * 0xffff
+		}
+		return float64(uint32(p[0]) * 0x101), float64(uint32(p[1]) * 0x101), float64(uint32(p[2]) * 0x101), float64(uint32(p[3]) * 0x101)
+	}
+	parallelRows(0, h, w, func(from, to int) {
+		for y := from; y < to; y++ {
+			yf := float64(y*max.Y) / float64(h)
+			j := math.Floor(yf)
+			v := yf - j
+			for x := 0; x < w; x++ {
+				xf := float64(x*max.X) / float64(w)
+				i := math.Floor(xf)
+				u := xf - i
+				r1, g1, b1, a1 := at(int(i), int(j))
+				r2, g2, b2, a2 := at(int(i), int(j)+1)
+				r3, g3, b3, a3 := at(int(i)+1, int(j))
+				r4, g4, b4, a4 := at(int(i)+1, int(j)+1)
+				r := (1-u)*(1-v)*r1 + (1-u)*v*r2 + u*(1-v)*r3 + u*v*r4
+				g := (1-u)*(1-v)*g1 + (1-u)*v*g2 + u*(1-v)*g3 + u*v*g4
+				b := (1-u)*(1-v)*b1 + (1-u)*v*b2 + u*(1-v)*b3 + u*v*b4
+				a := (1-u)*(1-v)*a1 + (1-u)*v*a2 + u*(1-v)*a3 + u*v*a4
+				q := newImage.Pix[newImage.PixOffset(x, y):]
+				if linear {
+					setSRGBPixel(q, [4]float64{r / 0xffff, g / 0xffff, b / 0xffff, a / 0xffff})
+					continue
+				}
+				q[0], q[1], q[2], q[3] = uint8(uint16(r)>>8), uint8(uint16(g)>>8), uint8(uint16(b)>>8), uint8(uint16(a)>>8)
+			}
+		}
+	})
+	return newImage
+}
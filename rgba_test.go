@@ -0,0 +1,176 @@
+package imagedraw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+//隐藏具体类型 使图片操作走At/Set的通用逻辑
+type modelImage struct {
+	draw.Image
+}
+
+func randomImages(w, h int) map[string]draw.Image {
+	r := rand.New(rand.NewSource(2))
+	nrgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+	r.Read(nrgba.Pix)
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	r.Read(gray.Pix)
+	return map[string]draw.Image{
+		"nrgba": nrgba,
+		"rgba":  randomRGBA(w, h, 3),
+		"gray":  gray,
+	}
+}
+
+func TestConvertImageFastPath(t *testing.T) {
+	for name, img := range randomImages(37, 23) {
+		fast := convertImage(img).(*image.RGBA)
+		generic := convertImage(modelImage{img}).(*image.RGBA)
+		if !bytes.Equal(fast.Pix, generic.Pix) {
+			t.Errorf("%s: convertImage fast path differs from color.Model", name)
+		}
+		//与color.RGBAModel逐个像素比较
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if want := color.RGBAModel.Convert(img.At(x, y)); fast.At(x, y) != want {
+					t.Fatalf("%s: (%d,%d) got %v, want %v", name, x, y, fast.At(x, y), want)
+				}
+			}
+		}
+	}
+}
+
+//*image.RGBA的快速路径与At/Set的通用逻辑逐字节相同
+func TestRGBAFastPaths(t *testing.T) {
+	ops := []struct {
+		name string
+		fn   func(img image.Image) draw.Image
+	}{
+		{"cut", func(img image.Image) draw.Image { return cut(img, 3, 2, 30, 20) }},
+		{"cut outside", func(img image.Image) draw.Image { return cut(img, -5, -3, 45, 30) }},
+		{"bilinear down", func(img image.Image) draw.Image { return bilinearInterpolation(img, 20, 11, false) }},
+		{"bilinear up", func(img image.Image) draw.Image { return bilinearInterpolation(img, 80, 50, false) }},
+		{"opacity", func(img image.Image) draw.Image { return opacity(img, 37) }},
+		{"hue", func(img image.Image) draw.Image { return hue(img, 45) }},
+		{"saturation", func(img image.Image) draw.Image { return saturation(img, -60) }},
+		{"brightness", func(img image.Image) draw.Image { return brightness(img, 25) }},
+	}
+	for name, img := range randomImages(37, 23) {
+		src := convertImage(img).(*image.RGBA)
+		for _, op := range ops {
+			fast, ok := op.fn(src).(*image.RGBA)
+			if !ok {
+				t.Fatalf("%s %s: fast path did not return *image.RGBA", name, op.name)
+			}
+			generic := convertImage(op.fn(modelImage{src})).(*image.RGBA)
+			if fast.Rect != generic.Rect || !bytes.Equal(fast.Pix, generic.Pix) {
+				t.Errorf("%s %s: fast path differs from generic path", name, op.name)
+			}
+		}
+	}
+}
+
+//不透明的白色图片
+func whiteRGBA(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+//圆形边缘的alpha为像素被覆盖的比例 总和接近圆的面积
+func TestEllipseEdgeAlpha(t *testing.T) {
+	const r = 10
+	img := ellipseRGBA(whiteRGBA(40, 40), 20, 20, r, r)
+	if img.Rect != image.Rect(0, 0, 2*r, 2*r) {
+		t.Fatalf("got bounds %v", img.Rect)
+	}
+	sum, partial := 0.0, 0
+	for y := 0; y < 2*r; y++ {
+		for x := 0; x < 2*r; x++ {
+			c := img.RGBAAt(x, y)
+			if c.R != c.A || c.G != c.A || c.B != c.A {
+				t.Fatalf("pixel (%d, %d) got %v, want premultiplied white", x, y, c)
+			}
+			d := math.Hypot(float64(x)+0.5-r, float64(y)+0.5-r)
+			want := 255 * clamp01(r-d+0.5)
+			//ellipseCoverage用梯度近似到边缘的距离 与圆的精确值相差很小
+			if math.Abs(float64(c.A)-want) > 6 {
+				t.Errorf("pixel (%d, %d) at distance %.2f got alpha %d, want about %.0f", x, y, d, c.A, want)
+			}
+			if c.A > 0 && c.A < 255 {
+				partial++
+			}
+			sum += float64(c.A) / 255
+		}
+	}
+	if area := math.Pi * r * r; math.Abs(sum-area) > area*0.01 {
+		t.Errorf("alpha sum %.2f, want about %.2f", sum, area)
+	}
+	if partial == 0 {
+		t.Error("no anti-aliased edge pixels")
+	}
+	for _, p := range []image.Point{{0, 0}, {2*r - 1, 0}, {0, 2*r - 1}, {2*r - 1, 2*r - 1}} {
+		if a := img.RGBAAt(p.X, p.Y).A; a != 0 {
+			t.Errorf("corner %v alpha %d, want 0", p, a)
+		}
+	}
+	if a := img.RGBAAt(r, r).A; a != 255 {
+		t.Errorf("center alpha %d, want 255", a)
+	}
+
+	//椭圆的alpha总和接近椭圆的面积
+	img = ellipseRGBA(whiteRGBA(40, 40), 20, 20, 16, 6)
+	sum, partial = 0, 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 && img.Pix[i] < 255 {
+			partial++
+		}
+		sum += float64(img.Pix[i]) / 255
+	}
+	if area := math.Pi * 16 * 6; math.Abs(sum-area) > area*0.01 || partial == 0 {
+		t.Errorf("ellipse alpha sum %.2f with %d edge pixels, want about %.2f", sum, partial, area)
+	}
+}
+
+//圆角只影响四个角 边缘的alpha与像素中心到圆心的距离对应
+func TestBorderRadiusEdgeAlpha(t *testing.T) {
+	const r = 10
+	img := borderRadiusRGBA(whiteRGBA(40, 30), r, r, r, 0)
+	centers := []image.Point{{r, r}, {40 - r, r}, {40 - r, 30 - r}}
+	missing := 0.0
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			want := 255.0
+			for k, c := range centers {
+				cx, cy := float64(c.X), float64(c.Y)
+				inCorner := (k == 0 && px < cx && py < cy) || (k == 1 && px > cx && py < cy) || (k == 2 && px > cx && py > cy)
+				if inCorner {
+					want = math.Min(want, 255*clamp01(r-math.Hypot(px-cx, py-cy)+0.5))
+				}
+			}
+			a := img.RGBAAt(x, y).A
+			if math.Abs(float64(a)-want) > 0.5 {
+				t.Errorf("pixel (%d, %d) got alpha %d, want %.0f", x, y, a, want)
+			}
+			missing += 1 - float64(a)/255
+		}
+	}
+	//三个圆角各去掉r*r*(1-π/4)
+	if want := 3 * r * r * (1 - math.Pi/4); math.Abs(missing-want) > want*0.02 {
+		t.Errorf("removed %.2f pixels, want about %.2f", missing, want)
+	}
+	//左下角为0 保持直角
+	if a := img.RGBAAt(0, 29).A; a != 255 {
+		t.Errorf("square corner alpha %d, want 255", a)
+	}
+}
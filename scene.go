@@ -0,0 +1,233 @@
+package imagedraw
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Scene 由多个图层组成的可编辑文档 每次渲染都会生成一张新的图片 不会修改图层本身
+type Scene struct {
+	width      int
+	height     int
+	background color.Color
+	colorSpace ColorSpace
+	layers     []*Layer
+}
+
+// Layer 场景中的一个图层
+type Layer struct {
+	name    string
+	item    FillItem
+	x       int
+	y       int
+	zIndex  int
+	visible bool
+	opacity uint32
+}
+
+// NewScene 创建一个宽w 高h的场景 默认透明背景
+func NewScene(w, h int) *Scene {
+	return &Scene{
+		width:      w,
+		height:     h,
+		background: color.Transparent,
+	}
+}
+
+// SetBackground 设置背景颜色
+func (s *Scene) SetBackground(c color.Color) *Scene {
+	s.background = c
+	return s
+}
+
+// SetColorSpace 设置合成图层时计算颜色的空间 图层中的元素没有单独设置时使用 默认使用全局的设置
+func (s *Scene) SetColorSpace(cs ColorSpace) *Scene {
+	s.colorSpace = cs
+	return s
+}
+
+// SetSize 设置场景尺寸
+func (s *Scene) SetSize(w, h int) *Scene {
+	s.width = w
+	s.height = h
+	return s
+}
+
+// Width 返回场景宽度
+func (s *Scene) Width() int {
+	return s.width
+}
+
+// Height 返回场景高度
+func (s *Scene) Height() int {
+	return s.height
+}
+
+// AddLayer 添加一个图层 同名图层已存在时替换其中的元素并返回该图层
+func (s *Scene) AddLayer(name string, item FillItem) *Layer {
+	if l := s.Layer(name); l != nil {
+		l.item = item
+		return l
+	}
+	l := &Layer{
+		name:    name,
+		item:    item,
+		visible: true,
+		opacity: 100,
+	}
+	s.layers = append(s.layers, l)
+	return l
+}
+
+// Layer 根据名称返回图层 不存在时返回nil
+func (s *Scene) Layer(name string) *Layer {
+	for _, l := range s.layers {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Layers 按添加顺序返回所有图层
+func (s *Scene) Layers() []*Layer {
+	layers := make([]*Layer, len(s.layers))
+	copy(layers, s.layers)
+	return layers
+}
+
+// RemoveLayer 删除图层 图层不存在时返回false
+func (s *Scene) RemoveLayer(name string) bool {
+	for i, l := range s.layers {
+		if l.name == name {
+			s.layers = append(s.layers[:i], s.layers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Render 渲染场景并返回一张新的图片
+func (s *Scene) Render() (*Image, error) {
+	return s.RenderContext(context.Background())
+}
+
+// RenderContext 渲染场景并返回一张新的图片 ctx可用于取消渲染
+func (s *Scene) RenderContext(ctx context.Context) (*Image, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(s.background), image.Point{}, draw.Src)
+
+	var img draw.Image = dst
+	rc := NewRenderContext(dst)
+	rc.Context = ctx
+	rc.ColorSpace = s.colorSpace
+	for _, l := range s.sortedLayers() {
+		if !l.visible || l.opacity == 0 || l.item == nil {
+			continue
+		}
+		if err := rc.Err(); err != nil {
+			return nil, err
+		}
+		var err error
+		img, err = l.render(img, rc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewImage(img), nil
+}
+
+//按z-index从小到大排序 z-index相同时按添加顺序
+func (s *Scene) sortedLayers() []*Layer {
+	layers := s.Layers()
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].zIndex < layers[j].zIndex
+	})
+	return layers
+}
+
+// Name 返回图层名称
+func (l *Layer) Name() string {
+	return l.name
+}
+
+// Item 返回图层中的元素
+func (l *Layer) Item() FillItem {
+	return l.item
+}
+
+// SetItem 替换图层中的元素
+func (l *Layer) SetItem(item FillItem) *Layer {
+	l.item = item
+	return l
+}
+
+// SetPosition 设置图层的偏移位置 元素自身的绘制区域会整体偏移(x,y)
+func (l *Layer) SetPosition(x, y int) *Layer {
+	l.x = x
+	l.y = y
+	return l
+}
+
+// SetZIndex 设置图层层级 层级大的绘制在上面
+func (l *Layer) SetZIndex(z int) *Layer {
+	l.zIndex = z
+	return l
+}
+
+// SetVisible 设置图层是否可见
+func (l *Layer) SetVisible(visible bool) *Layer {
+	l.visible = visible
+	return l
+}
+
+// SetOpacity 设置图层不透明度 0-100 100为完全不透明 0为完全透明
+func (l *Layer) SetOpacity(opacity uint32) *Layer {
+	if opacity > 100 {
+		opacity = 100
+	}
+	l.opacity = opacity
+	return l
+}
+
+//可以设置绘制方式的元素
+type opItem interface {
+	drawOp() draw.Op
+}
+
+//将图层绘制到dst上
+func (l *Layer) render(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+	offset := image.Pt(l.x, l.y)
+	if l.opacity == 100 && offset == (image.Point{}) {
+		return l.item.Draw(dst, rc)
+	}
+
+	//先绘制到透明图层上 再偏移并按不透明度合成
+	//图层的范围是偏移前的画布 偏移前在画布外偏移后移入画布的内容不会被裁掉
+	bounds := dst.Bounds().Sub(offset)
+	layerContext := *rc
+	layerContext.Bounds = bounds
+	if !rc.Clip.Empty() {
+		layerContext.Clip = rc.Clip.Sub(offset)
+	}
+	layer, err := l.item.Draw(image.NewRGBA(bounds), &layerContext)
+	if err != nil {
+		return nil, err
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(l.opacity * 255 / 100)})
+	//透明图层上的混合模式和绘制方式没有效果 合成时再使用元素的设置
+	mode := BlendNormal
+	if b, ok := l.item.(blendItem); ok {
+		mode = b.blendMode()
+	}
+	op, r := draw.Over, layer.Bounds()
+	if o, ok := l.item.(opItem); ok && o.drawOp() == draw.Src {
+		//只替换元素的绘制区域
+		op, r = draw.Src, itemArea(l.item, bounds).Intersect(r)
+	}
+	composite(rc.ClipImage(dst), r.Add(offset), layer, r.Min, mask, image.Point{}, op, mode, rc.linear())
+	return dst, nil
+}
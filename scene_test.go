@@ -0,0 +1,209 @@
+package imagedraw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+//纯色图片
+func uniformImage(w, h int, c color.Color) *Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return NewImage(img)
+}
+
+//偏移前在画布外的内容偏移后可以移入画布
+func TestLayerPositionMovesOffCanvasContent(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	for _, opacity := range []uint32{100, 50} {
+		s := NewScene(20, 20)
+		s.AddLayer("red", uniformImage(10, 10, red).SetArea(-10, -5, 10, 10)).SetPosition(15, 5).SetOpacity(opacity)
+		out, err := s.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := out.Image().(*image.RGBA)
+		want := uint8(opacity * 255 / 100)
+		for _, p := range []image.Point{{5, 0}, {14, 9}} {
+			if got := dst.RGBAAt(p.X, p.Y); got.R != want || got.A != want {
+				t.Errorf("opacity %d: %v got %v, want red", opacity, p, got)
+			}
+		}
+		for _, p := range []image.Point{{4, 0}, {15, 0}, {5, 10}} {
+			if got := dst.RGBAAt(p.X, p.Y); got.A != 0 {
+				t.Errorf("opacity %d: %v got %v, want transparent", opacity, p, got)
+			}
+		}
+	}
+}
+
+//合成偏移后的图层时使用元素的绘制方式
+func TestLayerPositionKeepsOp(t *testing.T) {
+	clear := color.RGBA{G: 64, A: 128}
+	s := NewScene(20, 20).SetBackground(color.RGBA{B: 255, A: 255})
+	s.AddLayer("src", uniformImage(10, 10, clear).SetArea(0, 0, 10, 10).SetOp(draw.Src)).SetPosition(5, 5)
+	out, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := out.Image().(*image.RGBA)
+	if got := dst.RGBAAt(10, 10); got != clear {
+		t.Errorf("inside got %v, want %v", got, clear)
+	}
+	//元素的绘制区域以外不被替换
+	for _, p := range []image.Point{{2, 2}, {16, 16}} {
+		if got := dst.RGBAAt(p.X, p.Y); got != (color.RGBA{B: 255, A: 255}) {
+			t.Errorf("%v got %v, want background", p, got)
+		}
+	}
+}
+
+//变换后合成时使用元素的绘制方式
+func TestTransformedKeepsOp(t *testing.T) {
+	clear := color.RGBA{G: 64, A: 128}
+	background := color.RGBA{B: 255, A: 255}
+	for _, deg := range []float64{90, 30} {
+		dst := uniformImage(20, 20, background).img.(*image.RGBA)
+		item := uniformImage(10, 10, clear).SetArea(5, 5, 10, 10).SetOp(draw.Src)
+		if _, err := NewTransformed(item).Rotate(deg, AnchorCenter).Draw(dst, NewRenderContext(dst)); err != nil {
+			t.Fatal(err)
+		}
+		if got := dst.RGBAAt(10, 10); got != clear {
+			t.Errorf("rotate %v: inside got %v, want %v", deg, got, clear)
+		}
+		//变换后的绘制区域以外不被替换
+		for _, p := range []image.Point{{1, 1}, {18, 18}} {
+			if got := dst.RGBAAt(p.X, p.Y); got != background {
+				t.Errorf("rotate %v: %v got %v, want background", deg, p, got)
+			}
+		}
+	}
+}
+
+//层级大的在上面 层级相同时后添加的在上面
+func TestSceneZIndex(t *testing.T) {
+	red, green, blue := color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	s := NewScene(10, 10)
+	s.AddLayer("red", uniformImage(10, 10, red).SetArea(0, 0, 10, 10)).SetZIndex(2)
+	s.AddLayer("green", uniformImage(10, 10, green).SetArea(0, 0, 10, 10)).SetZIndex(1)
+	s.AddLayer("blue", uniformImage(5, 10, blue).SetArea(0, 0, 5, 10)).SetZIndex(1)
+	out, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := out.Image().At(2, 2); c != red {
+		t.Errorf("got %v, want the highest layer %v", c, red)
+	}
+
+	s.Layer("red").SetZIndex(-1)
+	out, err = s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := out.Image().At(2, 2); c != blue {
+		t.Errorf("got %v, want the later layer of the same z-index %v", c, blue)
+	}
+	if c := out.Image().At(7, 2); c != green {
+		t.Errorf("got %v, want %v", c, green)
+	}
+}
+
+func TestSceneVisibilityAndOpacity(t *testing.T) {
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	s := NewScene(4, 4).SetBackground(blue)
+	layer := s.AddLayer("red", uniformImage(4, 4, red).SetArea(0, 0, 4, 4))
+
+	tests := []struct {
+		visible bool
+		opacity uint32
+		want    color.RGBA
+	}{
+		{true, 100, red},
+		{false, 100, blue},
+		{true, 0, blue},
+		{true, 50, color.RGBA{R: 128, B: 127, A: 255}},
+		//超过100时按100处理
+		{true, 200, red},
+	}
+	for _, tt := range tests {
+		layer.SetVisible(tt.visible).SetOpacity(tt.opacity)
+		out, err := s.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := out.Image().(*image.RGBA).RGBAAt(1, 1)
+		if absDiff(c.R, tt.want.R) > 1 || absDiff(c.B, tt.want.B) > 1 || c.A != tt.want.A {
+			t.Errorf("visible %v opacity %d: got %v, want %v", tt.visible, tt.opacity, c, tt.want)
+		}
+	}
+}
+
+func TestSceneLayers(t *testing.T) {
+	red, green := color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}
+	s := NewScene(4, 4)
+	s.AddLayer("a", uniformImage(4, 4, red).SetArea(0, 0, 4, 4))
+	s.AddLayer("b", uniformImage(2, 2, green).SetArea(0, 0, 2, 2))
+	//同名图层替换元素 位置不变
+	s.AddLayer("a", uniformImage(4, 4, green).SetArea(0, 0, 4, 4))
+	if layers := s.Layers(); len(layers) != 2 || layers[0].Name() != "a" || layers[1].Name() != "b" {
+		t.Fatalf("got layers %v", layers)
+	}
+
+	if !s.RemoveLayer("a") {
+		t.Error("RemoveLayer(a) got false")
+	}
+	if s.RemoveLayer("a") || s.RemoveLayer("missing") {
+		t.Error("removing a missing layer got true")
+	}
+	if s.Layer("a") != nil || len(s.Layers()) != 1 {
+		t.Errorf("got layers %v after remove", s.Layers())
+	}
+	out, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := out.Image().At(3, 3); c != (color.RGBA{}) {
+		t.Errorf("got %v, want the removed layer not drawn", c)
+	}
+	if c := out.Image().At(1, 1); c != green {
+		t.Errorf("got %v, want %v", c, green)
+	}
+}
+
+//每次渲染得到相同的结果 不修改图层和元素
+func TestSceneRenderTwice(t *testing.T) {
+	photo := NewImage(randomRGBA(8, 8, 3)).SetArea(2, 2, 8, 8)
+	pixels := append([]byte(nil), photo.Image().(*image.RGBA).Pix...)
+	s := NewScene(12, 12).SetBackground(color.RGBA{R: 20, G: 40, B: 60, A: 255})
+	s.AddLayer("photo", photo).SetPosition(1, -1).SetOpacity(70).SetZIndex(1)
+	s.AddLayer("text", NewText("Hi").SetFont(testTTF(t)).SetFontSize(10).SetArea(0, 0, 12, 12))
+	s.AddLayer("rotated", NewTransformed(uniformImage(4, 4, color.RGBA{G: 255, A: 255}).SetArea(6, 6, 4, 4)).Rotate(30, AnchorCenter))
+	before := s.Layers()
+
+	first, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Image().(*image.RGBA).Pix, second.Image().(*image.RGBA).Pix) {
+		t.Error("second render differs from the first")
+	}
+	if first.Image() == second.Image() {
+		t.Error("renders share the same image")
+	}
+	if !bytes.Equal(photo.Image().(*image.RGBA).Pix, pixels) {
+		t.Error("render changed the layer image")
+	}
+	after := s.Layers()
+	for i := range before {
+		if before[i] != after[i] || *before[i] != *after[i] {
+			t.Errorf("layer %d changed by render", i)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryCache 内存中的LRU缓存 超出容量时淘汰最久未使用的结果
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+// NewMemoryCache 创建一个最多缓存maxBytes字节的内存缓存
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 实现Cache接口
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*cacheEntry).data, true
+	}
+	return nil, false
+}
+
+// Set 实现Cache接口
+func (c *MemoryCache) Set(key string, data []byte) {
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.size += int64(len(data) - len(e.Value.(*cacheEntry).data))
+		e.Value.(*cacheEntry).data = data
+		c.ll.MoveToFront(e)
+	} else {
+		c.items[key] = c.ll.PushFront(&cacheEntry{key: key, data: data})
+		c.size += int64(len(data))
+	}
+	for c.size > c.maxBytes {
+		e := c.ll.Back()
+		entry := e.Value.(*cacheEntry)
+		c.ll.Remove(e)
+		delete(c.items, entry.key)
+		c.size -= int64(len(entry.data))
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yeyudekuangxiang/imagedraw"
+)
+
+// ErrNotAllowed 请求的文件不在允许的目录中
+var ErrNotAllowed = errors.New("path is not under an allowed root")
+
+// RootLoader 只从指定目录中加载图片和字体 不会访问网络
+type RootLoader struct {
+	//允许读取图片的目录
+	ImageRoots []string
+	//允许读取字体的目录 内置字体总是可用
+	FontRoots []string
+	//图片的最大像素数 解码之前读取图片头检查 为0时不限制
+	MaxPixels int
+}
+
+// NewRootLoader 创建一个只从指定目录中加载资源的TemplateLoader
+func NewRootLoader(imageRoots, fontRoots []string) *RootLoader {
+	return &RootLoader{
+		ImageRoots: imageRoots,
+		FontRoots:  fontRoots,
+	}
+}
+
+// LoadImage 实现imagedraw.TemplateLoader接口
+func (l *RootLoader) LoadImage(src string) (*imagedraw.Image, error) {
+	path, err := resolvePath(l.ImageRoots, src)
+	if err != nil {
+		return nil, err
+	}
+	if l.MaxPixels <= 0 {
+		return imagedraw.LoadImage(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > int64(l.MaxPixels) {
+		return nil, fmt.Errorf("image %dx%d is larger than %d pixels", config.Width, config.Height, l.MaxPixels)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return imagedraw.LoadImageFromReader(file)
+}
+
+// LoadFont 实现imagedraw.TemplateLoader接口
+func (l *RootLoader) LoadFont(name string) (imagedraw.IDrawString, error) {
+	if d, ok := imagedraw.BuiltinFont(name); ok {
+		return d, nil
+	}
+	path, err := resolvePath(l.FontRoots, name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".otf") {
+		font, err := imagedraw.LoadOTF(path)
+		if err != nil {
+			return nil, err
+		}
+		return imagedraw.NewOTFDraw(font), nil
+	}
+	font, err := imagedraw.LoadTTF(path)
+	if err != nil {
+		return nil, err
+	}
+	return imagedraw.NewTTFDraw(font), nil
+}
+
+//在roots中查找name 相对路径依次在每个目录中查找 绝对路径必须在某个目录中
+func resolvePath(roots []string, name string) (string, error) {
+	if name == "" || strings.Contains(name, "://") {
+		return "", fmt.Errorf("%q: %w", name, ErrNotAllowed)
+	}
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		var path string
+		if filepath.IsAbs(name) {
+			path = filepath.Clean(name)
+		} else {
+			//以/开头再Clean 保证../不会跳出root
+			path = filepath.Join(absRoot, filepath.Clean(string(filepath.Separator)+name))
+		}
+		if !within(absRoot, path) {
+			continue
+		}
+		//符号链接指向的真实文件也必须在root中
+		realRoot, err := filepath.EvalSymlinks(absRoot)
+		if err != nil {
+			continue
+		}
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		if within(realRoot, realPath) {
+			return realPath, nil
+		}
+	}
+	return "", fmt.Errorf("%q: %w", name, ErrNotAllowed)
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
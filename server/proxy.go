@@ -0,0 +1,593 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yeyudekuangxiang/imagedraw"
+)
+
+// ProxyOptions 图片代理服务的配置
+type ProxyOptions struct {
+	//签名密钥 不能为空
+	Key []byte
+	//允许访问的图片域名 如example.com、cdn.example.com:8080
+	AllowedHosts []string
+	//允许访问的本地图片目录
+	AllowedDirs []string
+	//渲染结果的缓存 为nil时不缓存
+	Cache Cache
+	//下载网络图片使用的client 默认http.DefaultClient 重定向时每一跳都需要是允许的域名
+	Client *http.Client
+	//网络图片最大字节数 默认20MB
+	MaxSourceBytes int64
+	//源图片和每个操作输出图片的最大像素数 默认40000000
+	MaxPixels int
+	//每个请求的超时时间 默认10秒
+	Timeout time.Duration
+	//同时处理的最大数量 默认CPU核数
+	MaxConcurrent int
+}
+
+// Cache 渲染结果的缓存 需要并发安全
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, data []byte)
+}
+
+type proxyHandler struct {
+	opts ProxyOptions
+	sem  chan struct{}
+}
+
+// NewProxyHandler 创建图片代理服务 操作通过请求路径指定
+//
+//	/<签名>/rs:200:200/circle/q:80/<base64url编码的图片地址>.jpg
+//
+// 支持的操作
+//
+//	rs:w:h[:bilinear|cubic|lanczos3|mitchell|catmullrom|nearest|area] 调整尺寸 其中一边为0时按原图比例计算
+//	cut:x:y:w:h             剪切方形
+//	circle[:x:y:r]          截取圆形 默认以图片中心为圆心 短边一半为半径
+//	ellipse[:x:y:w:h]       截取椭圆 默认以图片中心为中心 宽高一半为半轴
+//	radius:r 或 radius:lt:rt:rb:lb 圆角
+//	hue:v sat:v bri:v       色度、饱和度、亮度 -100到100
+//	op:v                    不透明度 0到100
+//	q:v                     jpg质量 1到100
+//	f:png|jpg               输出格式 也可以使用图片地址的扩展名指定 默认png
+//	exp:unix                过期时间 unix秒数 过期后签名无效
+//
+// 签名为HMAC-SHA256(Key, 签名之后的路径)的base64url编码 可以使用SignPath生成
+// 挂载在子路径下时需要配合http.StripPrefix使用
+func NewProxyHandler(opts ProxyOptions) http.Handler {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.MaxSourceBytes <= 0 {
+		opts.MaxSourceBytes = 20 << 20
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = 40000000
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = runtime.GOMAXPROCS(0)
+	}
+	h := &proxyHandler{
+		opts: opts,
+		sem:  make(chan struct{}, opts.MaxConcurrent),
+	}
+	//复制一份client 重定向到不允许的域名时停止 避免通过允许的域名跳转到内网地址
+	client := *opts.Client
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !h.hostAllowed(req.URL) {
+			return newProxyError(http.StatusForbidden, "source_not_allowed", "redirect to host %q is not allowed", req.URL.Host)
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	h.opts.Client = &client
+	return h
+}
+
+// SignPath 为路径生成签名 path为不含签名的路径 如/rs:200:200/<source> 返回带签名的完整路径
+func SignPath(key []byte, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "/" + signature(key, path) + path
+}
+
+// EncodeSource 将图片地址编码为路径中使用的格式
+func EncodeSource(source, ext string) string {
+	s := base64.RawURLEncoding.EncodeToString([]byte(source))
+	if ext != "" {
+		s += "." + strings.TrimPrefix(ext, ".")
+	}
+	return s
+}
+
+func signature(key []byte, path string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//代理请求的错误 包含返回的状态码
+type proxyError struct {
+	status int
+	code   string
+	err    error
+}
+
+func (e *proxyError) Error() string {
+	return e.err.Error()
+}
+
+func newProxyError(status int, code string, format string, args ...interface{}) error {
+	return &proxyError{status: status, code: code, err: fmt.Errorf(format, args...)}
+}
+
+//解析后的代理请求
+type proxyRequest struct {
+	ops     []proxyOp
+	source  string
+	format  string
+	quality int
+	//过期时间 unix秒数 为0时不过期
+	expires int64
+}
+
+//返回错误时停止处理 如输出的图片过大
+type proxyOp func(img *imagedraw.Image) (*imagedraw.Image, error)
+
+func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only GET and HEAD are allowed")
+		return
+	}
+	data, contentType, err := h.serve(r)
+	if err != nil {
+		var pe *proxyError
+		switch {
+		case errors.As(err, &pe):
+			writeError(w, pe.status, pe.code, pe.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			writeError(w, http.StatusGatewayTimeout, "timeout", "request timed out")
+		default:
+			writeError(w, http.StatusInternalServerError, "render_failed", err.Error())
+		}
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+//返回编码后的图片和Content-Type panic时返回错误 保证客户端总是收到json格式的错误
+func (h *proxyHandler) serve(r *http.Request) (data []byte, contentType string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			data, contentType, err = nil, "", fmt.Errorf("panic: %v", v)
+		}
+	}()
+	p := r.URL.EscapedPath()
+	p = strings.TrimPrefix(p, "/")
+	i := strings.Index(p, "/")
+	if i < 0 {
+		return nil, "", newProxyError(http.StatusNotFound, "not_found", "missing signature or source")
+	}
+	sig, signed := p[:i], p[i:]
+	if len(h.opts.Key) == 0 || !hmac.Equal([]byte(sig), []byte(signature(h.opts.Key, signed))) {
+		return nil, "", newProxyError(http.StatusForbidden, "invalid_signature", "invalid signature")
+	}
+
+	req, err := parseProxyPath(signed, h.opts.MaxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.expires != 0 && time.Now().Unix() > req.expires {
+		return nil, "", newProxyError(http.StatusForbidden, "invalid_signature", "signature expired")
+	}
+	ext, contentType, _ := format(req.format)
+
+	if h.opts.Cache != nil {
+		if data, ok := h.opts.Cache.Get(signed); ok {
+			return data, contentType, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.opts.Timeout)
+	defer cancel()
+	select {
+	case h.sem <- struct{}{}:
+		defer func() { <-h.sem }()
+	case <-ctx.Done():
+		return nil, "", newProxyError(http.StatusServiceUnavailable, "busy", "too many concurrent requests")
+	}
+
+	img, err := h.load(ctx, req.source)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, op := range req.ops {
+		if img, err = op(img); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	if ext == "jpg" && req.quality > 0 {
+		err = jpeg.Encode(&buf, img.Image(), &jpeg.Options{Quality: req.quality})
+	} else {
+		err = img.Encode(&buf, ext)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	data = buf.Bytes()
+	if h.opts.Cache != nil {
+		h.opts.Cache.Set(signed, data)
+	}
+	return data, contentType, nil
+}
+
+//解析签名之后的路径 maxPixels为每个操作输出图片的最大像素数
+func parseProxyPath(p string, maxPixels int) (*proxyRequest, error) {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) == 0 || segments[len(segments)-1] == "" {
+		return nil, newProxyError(http.StatusBadRequest, "invalid_path", "missing source")
+	}
+	req := &proxyRequest{}
+
+	source := segments[len(segments)-1]
+	if ext := path.Ext(source); ext != "" {
+		req.format = ext[1:]
+		source = strings.TrimSuffix(source, ext)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(source, "="))
+	if err != nil {
+		return nil, newProxyError(http.StatusBadRequest, "invalid_path", "source must be base64url encoded: %v", err)
+	}
+	req.source = string(decoded)
+
+	for _, segment := range segments[:len(segments)-1] {
+		seg, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, newProxyError(http.StatusBadRequest, "invalid_path", "%q: %v", segment, err)
+		}
+		if err := req.parseOp(seg, maxPixels); err != nil {
+			return nil, newProxyError(http.StatusBadRequest, "invalid_option", "%q: %v", seg, err)
+		}
+	}
+	if _, _, ok := format(req.format); !ok {
+		return nil, newProxyError(http.StatusBadRequest, "invalid_option", "unsupported format %q", req.format)
+	}
+	return req, nil
+}
+
+//解析一个操作 输出图片超过maxPixels个像素时返回错误
+func (req *proxyRequest) parseOp(seg string, maxPixels int) error {
+	parts := strings.Split(seg, ":")
+	name, args := parts[0], parts[1:]
+	ints := func(n ...int) ([]int, error) {
+		ok := false
+		for _, c := range n {
+			ok = ok || len(args) == c
+		}
+		if !ok {
+			return nil, fmt.Errorf("wrong number of arguments")
+		}
+		list := make([]int, len(args))
+		for i, arg := range args {
+			v, err := strconv.Atoi(arg)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %v", i+1, err)
+			}
+			//坐标和尺寸都不会超过最大像素数 同时避免相加时溢出
+			if v > maxPixels || v < -maxPixels {
+				return nil, fmt.Errorf("argument %d is out of range", i+1)
+			}
+			list[i] = v
+		}
+		return list, nil
+	}
+	percent := func(minValue float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("wrong number of arguments")
+		}
+		v, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return 0, err
+		}
+		if v < minValue || v > 100 {
+			return 0, fmt.Errorf("must be between %v and 100", minValue)
+		}
+		return v, nil
+	}
+	//检查输出图片的大小
+	checkSize := func(w, h float64) error {
+		if w*h > float64(maxPixels) {
+			return fmt.Errorf("output %.0fx%.0f is larger than %d pixels", w, h, maxPixels)
+		}
+		return nil
+	}
+	add := func(op func(img *imagedraw.Image) *imagedraw.Image) {
+		req.ops = append(req.ops, func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			return op(img), nil
+		})
+	}
+
+	switch name {
+	case "rs", "resize":
+		resizeType := imagedraw.BilinearInterpolation
+		if len(args) == 3 {
+			t, err := imagedraw.ParseResizeType(args[2])
+			if err != nil {
+				return err
+			}
+			resizeType = t
+			args = args[:2]
+		}
+		v, err := ints(2)
+		if err != nil {
+			return err
+		}
+		if v[0] < 0 || v[1] < 0 || (v[0] == 0 && v[1] == 0) {
+			return fmt.Errorf("size must not be negative and at most one side may be 0")
+		}
+		if err := checkSize(float64(v[0]), float64(v[1])); err != nil {
+			return err
+		}
+		req.ops = append(req.ops, func(img *imagedraw.Image) (*imagedraw.Image, error) {
+			//其中一边为0时按原图比例计算出的大小也不能超过限制
+			w, h := float64(v[0]), float64(v[1])
+			if iw, ih := float64(img.Width()), float64(img.Height()); iw > 0 && ih > 0 {
+				if w == 0 {
+					w = math.Round(h * iw / ih)
+				}
+				if h == 0 {
+					h = math.Round(w * ih / iw)
+				}
+			}
+			if err := checkSize(w, h); err != nil {
+				return nil, newProxyError(http.StatusBadRequest, "image_too_large", "%q: %v", seg, err)
+			}
+			return img.Resize(v[0], v[1], resizeType), nil
+		})
+	case "cut":
+		v, err := ints(4)
+		if err != nil {
+			return err
+		}
+		if v[2] <= 0 || v[3] <= 0 {
+			return fmt.Errorf("size must be positive")
+		}
+		if err := checkSize(float64(v[2]), float64(v[3])); err != nil {
+			return err
+		}
+		add(func(img *imagedraw.Image) *imagedraw.Image {
+			return img.Cut(v[0], v[1], v[2], v[3])
+		})
+	case "circle":
+		v, err := ints(0, 3)
+		if err != nil {
+			return err
+		}
+		if len(v) == 3 {
+			if v[2] <= 0 {
+				return fmt.Errorf("radius must be positive")
+			}
+			if err := checkSize(float64(2*v[2]), float64(2*v[2])); err != nil {
+				return err
+			}
+		}
+		add(func(img *imagedraw.Image) *imagedraw.Image {
+			if len(v) == 3 {
+				return img.Circle(v[0], v[1], v[2])
+			}
+			r := img.Width() / 2
+			if img.Height() < img.Width() {
+				r = img.Height() / 2
+			}
+			return img.Circle(img.Width()/2, img.Height()/2, r)
+		})
+	case "ellipse":
+		v, err := ints(0, 4)
+		if err != nil {
+			return err
+		}
+		if len(v) == 4 {
+			if v[2] <= 0 || v[3] <= 0 {
+				return fmt.Errorf("size must be positive")
+			}
+			if err := checkSize(float64(2*v[2]), float64(2*v[3])); err != nil {
+				return err
+			}
+		}
+		add(func(img *imagedraw.Image) *imagedraw.Image {
+			if len(v) == 4 {
+				return img.Ellipse(v[0], v[1], v[2], v[3])
+			}
+			return img.Ellipse(img.Width()/2, img.Height()/2, img.Width()/2, img.Height()/2)
+		})
+	case "radius":
+		v, err := ints(1, 4)
+		if err != nil {
+			return err
+		}
+		if len(v) == 1 {
+			v = []int{v[0], v[0], v[0], v[0]}
+		}
+		for _, r := range v {
+			if r < 0 {
+				return fmt.Errorf("radius must not be negative")
+			}
+		}
+		add(func(img *imagedraw.Image) *imagedraw.Image {
+			return img.BorderRadius(uint(v[0]), uint(v[1]), uint(v[2]), uint(v[3]))
+		})
+	case "hue", "sat", "bri":
+		v, err := percent(-100)
+		if err != nil {
+			return err
+		}
+		add(func(img *imagedraw.Image) *imagedraw.Image {
+			switch name {
+			case "hue":
+				return img.Hue(v)
+			case "sat":
+				return img.Saturation(v)
+			}
+			return img.Brightness(v)
+		})
+	case "op":
+		v, err := percent(0)
+		if err != nil {
+			return err
+		}
+		add(func(img *imagedraw.Image) *imagedraw.Image {
+			return img.Opacity(uint32(v))
+		})
+	case "q":
+		v, err := ints(1)
+		if err != nil {
+			return err
+		}
+		if v[0] < 1 || v[0] > 100 {
+			return fmt.Errorf("quality must be between 1 and 100")
+		}
+		req.quality = v[0]
+	case "f":
+		if len(args) != 1 {
+			return fmt.Errorf("wrong number of arguments")
+		}
+		req.format = args[0]
+	case "exp":
+		if len(args) != 1 {
+			return fmt.Errorf("wrong number of arguments")
+		}
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		if v <= 0 {
+			return fmt.Errorf("expiration must be positive")
+		}
+		req.expires = v
+	default:
+		return fmt.Errorf("unknown option %q", name)
+	}
+	return nil
+}
+
+//加载图片 只能访问允许的域名和目录
+func (h *proxyHandler) load(ctx context.Context, source string) (*imagedraw.Image, error) {
+	if !strings.Contains(source, "://") {
+		p, err := resolvePath(h.opts.AllowedDirs, source)
+		if err != nil {
+			return nil, newProxyError(http.StatusForbidden, "source_not_allowed", "%v", err)
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return nil, newProxyError(http.StatusNotFound, "source_not_found", "%v", err)
+		}
+		defer file.Close()
+		return h.decode(file)
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, newProxyError(http.StatusBadRequest, "invalid_source", "invalid source url %q", source)
+	}
+	if !h.hostAllowed(u) {
+		return nil, newProxyError(http.StatusForbidden, "source_not_allowed", "host %q is not allowed", u.Host)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.opts.Client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		//重定向到不允许的域名
+		var pe *proxyError
+		if errors.As(err, &pe) {
+			return nil, pe
+		}
+		return nil, newProxyError(http.StatusBadGateway, "source_unavailable", "%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newProxyError(http.StatusBadGateway, "source_unavailable", "source returned %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, h.opts.MaxSourceBytes+1))
+	if err != nil {
+		return nil, newProxyError(http.StatusBadGateway, "source_unavailable", "%v", err)
+	}
+	if int64(len(data)) > h.opts.MaxSourceBytes {
+		return nil, newProxyError(http.StatusBadGateway, "source_too_large", "source is larger than %d bytes", h.opts.MaxSourceBytes)
+	}
+	return h.decode(bytes.NewReader(data))
+}
+
+//先读取图片头检查像素数再解码 避免解码超大的图片
+func (h *proxyHandler) decode(r io.ReadSeeker) (*imagedraw.Image, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, newProxyError(http.StatusUnprocessableEntity, "invalid_source", "%v", err)
+	}
+	if int64(config.Width)*int64(config.Height) > int64(h.opts.MaxPixels) {
+		return nil, newProxyError(http.StatusUnprocessableEntity, "source_too_large", "source %dx%d is larger than %d pixels", config.Width, config.Height, h.opts.MaxPixels)
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, err := imagedraw.LoadImageFromReader(r)
+	if err != nil {
+		return nil, newProxyError(http.StatusUnprocessableEntity, "invalid_source", "%v", err)
+	}
+	return img, nil
+}
+
+func (h *proxyHandler) hostAllowed(u *url.URL) bool {
+	for _, host := range h.opts.AllowedHosts {
+		if strings.EqualFold(host, u.Host) || (u.Port() == "" && strings.EqualFold(host, u.Hostname())) {
+			return true
+		}
+	}
+	return false
+}
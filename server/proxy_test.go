@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+//请求签名后的路径 返回状态码和错误码
+func proxyGet(t *testing.T, h http.Handler, key []byte, path string) (int, string) {
+	t.Helper()
+	return proxyGetRaw(t, h, SignPath(key, path))
+}
+
+//请求完整的路径 不重新签名
+func proxyGetRaw(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code == http.StatusOK {
+		return rec.Code, ""
+	}
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid error body %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body.Error.Code
+}
+
+func TestProxyRedirectToDisallowedHost(t *testing.T) {
+	internalHit := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHit = true
+		_, _ = w.Write(pngBytes(t, 1, 1))
+	}))
+	defer internal.Close()
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok.png" {
+			_, _ = w.Write(pngBytes(t, 2, 2))
+			return
+		}
+		http.Redirect(w, r, internal.URL+"/secret.png", http.StatusFound)
+	}))
+	defer allowed.Close()
+
+	u, _ := url.Parse(allowed.URL)
+	key := []byte("key")
+	h := NewProxyHandler(ProxyOptions{Key: key, AllowedHosts: []string{u.Host}})
+
+	if status, code := proxyGet(t, h, key, "/"+EncodeSource(allowed.URL+"/redirect.png", "png")); status != http.StatusForbidden || code != "source_not_allowed" {
+		t.Errorf("redirect got %d %q, want 403 source_not_allowed", status, code)
+	}
+	if internalHit {
+		t.Error("redirect reached the disallowed host")
+	}
+	if status, code := proxyGet(t, h, key, "/"+EncodeSource(allowed.URL+"/ok.png", "png")); status != http.StatusOK {
+		t.Errorf("allowed host got %d %q", status, code)
+	}
+}
+
+func TestProxyLimits(t *testing.T) {
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big.png" {
+			_, _ = w.Write(pngBytes(t, 200, 200))
+			return
+		}
+		_, _ = w.Write(pngBytes(t, 100, 2))
+	}))
+	defer src.Close()
+	u, _ := url.Parse(src.URL)
+	key := []byte("key")
+	h := NewProxyHandler(ProxyOptions{Key: key, AllowedHosts: []string{u.Host}, MaxPixels: 10000})
+	small := EncodeSource(src.URL+"/small.png", "png")
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/" + EncodeSource(src.URL+"/big.png", "png"), http.StatusUnprocessableEntity, "source_too_large"},
+		{"/rs:50:50/" + small, http.StatusOK, ""},
+		{"/rs:200:200/" + small, http.StatusBadRequest, "invalid_option"},
+		//按比例计算出的高度为200 输出10000x200
+		{"/rs:10000:0/" + small, http.StatusBadRequest, "image_too_large"},
+		{"/cut:0:0:1000:1000/" + small, http.StatusBadRequest, "invalid_option"},
+		{"/cut:9223372036854775807:0:10:10/" + small, http.StatusBadRequest, "invalid_option"},
+		{"/circle:0:0:0/" + small, http.StatusBadRequest, "invalid_option"},
+		{"/circle:0:0:-5/" + small, http.StatusBadRequest, "invalid_option"},
+		{"/circle:0:0:100/" + small, http.StatusBadRequest, "invalid_option"},
+		{"/ellipse:0:0:0:5/" + small, http.StatusBadRequest, "invalid_option"},
+		{"/circle/" + small, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		status, code := proxyGet(t, h, key, tt.path)
+		if status != tt.status || code != tt.code {
+			t.Errorf("%s got %d %q, want %d %q", tt.path, status, code, tt.status, tt.code)
+		}
+	}
+}
+
+func TestProxySignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.png"), pngBytes(t, 4, 4), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("key")
+	h := NewProxyHandler(ProxyOptions{Key: key, AllowedDirs: []string{dir}})
+	source := EncodeSource("a.png", "png")
+	signed := SignPath(key, "/rs:2:2/"+source)
+	sig := signed[1 : strings.Index(signed[1:], "/")+1]
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		code   string
+	}{
+		{"valid", signed, http.StatusOK, ""},
+		{"missing signature", "/rs:2:2/" + source, http.StatusForbidden, "invalid_signature"},
+		{"empty signature", "//rs:2:2/" + source, http.StatusForbidden, "invalid_signature"},
+		{"tampered path", "/" + sig + "/rs:3:3/" + source, http.StatusForbidden, "invalid_signature"},
+		{"tampered signature", "/x" + sig[1:] + "/rs:2:2/" + source, http.StatusForbidden, "invalid_signature"},
+		{"other key", SignPath([]byte("other"), "/rs:2:2/"+source), http.StatusForbidden, "invalid_signature"},
+		{"expired", SignPath(key, fmt.Sprintf("/exp:%d/%s", time.Now().Add(-time.Minute).Unix(), source)), http.StatusForbidden, "invalid_signature"},
+		{"not expired", SignPath(key, fmt.Sprintf("/exp:%d/%s", time.Now().Add(time.Hour).Unix(), source)), http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		status, code := proxyGetRaw(t, h, tt.path)
+		if status != tt.status || code != tt.code {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, status, code, tt.status, tt.code)
+		}
+	}
+
+	//没有密钥时所有请求都无效
+	h = NewProxyHandler(ProxyOptions{AllowedDirs: []string{dir}})
+	if status, code := proxyGet(t, h, nil, "/"+source); status != http.StatusForbidden || code != "invalid_signature" {
+		t.Errorf("empty key got %d %q, want 403 invalid_signature", status, code)
+	}
+}
+
+func TestProxyAllowedDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	allowed := filepath.Join(root, "allowed")
+	if err := os.Mkdir(allowed, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(allowed, "a.png"), filepath.Join(root, "secret.png")} {
+		if err := ioutil.WriteFile(name, pngBytes(t, 4, 4), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := []byte("key")
+	h := NewProxyHandler(ProxyOptions{Key: key, AllowedDirs: []string{allowed}})
+
+	tests := []struct {
+		source string
+		status int
+		code   string
+	}{
+		{"a.png", http.StatusOK, ""},
+		{filepath.Join(allowed, "a.png"), http.StatusOK, ""},
+		{"../secret.png", http.StatusForbidden, "source_not_allowed"},
+		{"../../" + filepath.Base(root) + "/secret.png", http.StatusForbidden, "source_not_allowed"},
+		{filepath.Join(allowed, "../secret.png"), http.StatusForbidden, "source_not_allowed"},
+		{filepath.Join(root, "secret.png"), http.StatusForbidden, "source_not_allowed"},
+	}
+	for _, tt := range tests {
+		status, code := proxyGet(t, h, key, "/"+EncodeSource(tt.source, "png"))
+		if status != tt.status || code != tt.code {
+			t.Errorf("%s: got %d %q, want %d %q", tt.source, status, code, tt.status, tt.code)
+		}
+	}
+}
+
+//记录读写次数的缓存
+type countingCache struct {
+	mu         sync.Mutex
+	data       map[string][]byte
+	hits, sets int
+}
+
+func (c *countingCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.data[key]
+	if ok {
+		c.hits++
+	}
+	return data, ok
+}
+
+func (c *countingCache) Set(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	c.data[key] = data
+}
+
+func TestProxyCache(t *testing.T) {
+	loads := 0
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loads++
+		_, _ = w.Write(pngBytes(t, 8, 8))
+	}))
+	defer src.Close()
+	u, _ := url.Parse(src.URL)
+	key := []byte("key")
+	cache := &countingCache{data: map[string][]byte{}}
+	h := NewProxyHandler(ProxyOptions{Key: key, AllowedHosts: []string{u.Host}, Cache: cache})
+	source := EncodeSource(src.URL+"/a.png", "png")
+
+	var bodies [][]byte
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, SignPath(key, "/rs:4:4/"+source), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d got %d %s", i, rec.Code, rec.Body.String())
+		}
+		bodies = append(bodies, rec.Body.Bytes())
+	}
+	if loads != 1 || cache.sets != 1 || cache.hits != 1 {
+		t.Errorf("got %d loads, %d sets, %d hits, want the second request to be served from the cache", loads, cache.sets, cache.hits)
+	}
+	if !bytes.Equal(bodies[0], bodies[1]) {
+		t.Error("cached response differs from the rendered one")
+	}
+	//不同的操作不使用同一个缓存
+	if status, code := proxyGet(t, h, key, "/rs:2:2/"+source); status != http.StatusOK {
+		t.Fatalf("got %d %q", status, code)
+	}
+	if loads != 2 {
+		t.Errorf("got %d loads, want a different path to render again", loads)
+	}
+}
+
+//panic的缓存
+type panicCache struct{}
+
+func (panicCache) Get(key string) ([]byte, bool) { panic("cache is broken") }
+func (panicCache) Set(key string, data []byte)   {}
+
+func TestProxyRecoversPanic(t *testing.T) {
+	key := []byte("key")
+	h := NewProxyHandler(ProxyOptions{Key: key, Cache: panicCache{}})
+	if status, code := proxyGet(t, h, key, "/"+EncodeSource("a.png", "png")); status != http.StatusInternalServerError || code != "render_failed" {
+		t.Errorf("got %d %q, want 500 render_failed", status, code)
+	}
+}
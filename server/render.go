@@ -0,0 +1,187 @@
+// Package server 提供基于imagedraw的http服务
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yeyudekuangxiang/imagedraw"
+)
+
+// RenderOptions 模板渲染服务的配置
+type RenderOptions struct {
+	//加载模板中的图片和字体 为nil时不允许加载任何本地文件 只能使用内置字体
+	Loader imagedraw.TemplateLoader
+	//请求体最大字节数 默认1MB
+	MaxBodyBytes int64
+	//每个请求的超时时间 默认10秒
+	//只在加载每个图层之前和绘制每个图层之前检查 正在执行的图片操作不会被中断 需要配合MaxPixels限制单次操作的耗时
+	Timeout time.Duration
+	//同时渲染的最大数量 默认CPU核数
+	MaxConcurrent int
+	//画布、加载的图片和每个图片操作输出图片的最大像素数 默认40000000 不能超过imagedraw.TemplateMaxPixels
+	MaxPixels int
+}
+
+// RenderRequest 渲染请求
+type RenderRequest struct {
+	Template *imagedraw.Template `json:"template"`
+	Vars     map[string]string   `json:"vars"`
+	//输出格式 png jpg 默认png
+	Format string `json:"format"`
+}
+
+type renderHandler struct {
+	opts RenderOptions
+	sem  chan struct{}
+}
+
+// NewRenderHandler 创建模板渲染服务 POST json格式的RenderRequest 返回渲染后的图片
+func NewRenderHandler(opts RenderOptions) http.Handler {
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = 40000000
+	}
+	if opts.Loader == nil {
+		loader := NewRootLoader(nil, nil)
+		loader.MaxPixels = opts.MaxPixels
+		opts.Loader = loader
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = runtime.GOMAXPROCS(0)
+	}
+	return &renderHandler{
+		opts: opts,
+		sem:  make(chan struct{}, opts.MaxConcurrent),
+	}
+}
+
+func (h *renderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only POST is allowed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.opts.Timeout)
+	defer cancel()
+
+	var req RenderRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.opts.MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		var templateErr *imagedraw.TemplateError
+		switch {
+		case errors.As(err, &templateErr):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_template", err.Error())
+		case strings.Contains(err.Error(), "request body too large"):
+			writeError(w, http.StatusRequestEntityTooLarge, "body_too_large", err.Error())
+		default:
+			writeError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		}
+		return
+	}
+	if req.Template == nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "template is required")
+		return
+	}
+	ext, contentType, ok := format(req.Format)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_request", "format must be png or jpg")
+		return
+	}
+	if int64(req.Template.Width)*int64(req.Template.Height) > int64(h.opts.MaxPixels) {
+		writeError(w, http.StatusUnprocessableEntity, "canvas_too_large", fmt.Sprintf("canvas %dx%d is larger than %d pixels", req.Template.Width, req.Template.Height, h.opts.MaxPixels))
+		return
+	}
+	//图片和每个图片操作在分配内存之前检查
+	req.Template.MaxPixels = h.opts.MaxPixels
+
+	select {
+	case h.sem <- struct{}{}:
+		defer func() { <-h.sem }()
+	case <-ctx.Done():
+		writeError(w, http.StatusServiceUnavailable, "busy", "too many concurrent renders")
+		return
+	}
+
+	data, err := h.render(ctx, &req, ext)
+	if err != nil {
+		var templateErr *imagedraw.TemplateError
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			writeError(w, http.StatusGatewayTimeout, "timeout", "render timed out")
+		case errors.Is(err, context.Canceled):
+			//客户端已断开
+		case errors.As(err, &templateErr):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_template", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "render_failed", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+//渲染并编码 panic时返回错误 保证客户端总是收到json格式的错误 并行处理时goroutine中的panic也会传到这里
+func (h *renderHandler) render(ctx context.Context, req *RenderRequest, ext string) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	img, err := req.Template.RenderContext(ctx, h.opts.Loader, req.Vars)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = img.Encode(&buf, ext); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//返回图片格式和Content-Type
+func format(name string) (string, string, bool) {
+	switch strings.ToLower(name) {
+	case "", "png":
+		return "png", "image/png", true
+	case "jpg", "jpeg":
+		return "jpg", "image/jpeg", true
+	}
+	return "", "", false
+}
+
+// ErrorResponse 出错时返回的json
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody 错误信息 Code为固定的错误类型 Message为具体的错误原因
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{Error: ErrorBody{Code: code, Message: message}})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yeyudekuangxiang/imagedraw"
+)
+
+//加载图片时panic的loader
+type panicLoader struct{}
+
+func (panicLoader) LoadImage(src string) (*imagedraw.Image, error) {
+	panic("broken loader")
+}
+
+func (panicLoader) LoadFont(name string) (imagedraw.IDrawString, error) {
+	return nil, errors.New("no fonts")
+}
+
+//记录是否被调用的loader
+type countLoader struct {
+	calls int
+}
+
+func (l *countLoader) LoadImage(src string) (*imagedraw.Image, error) {
+	l.calls++
+	return imagedraw.NewBaseImage(1, 1), nil
+}
+
+func (l *countLoader) LoadFont(name string) (imagedraw.IDrawString, error) {
+	return nil, errors.New("no fonts")
+}
+
+func renderPost(h http.Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	return rec
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid error body %q: %v", rec.Body.String(), err)
+	}
+	return resp.Error.Code
+}
+
+func TestRenderMaxPixels(t *testing.T) {
+	h := NewRenderHandler(RenderOptions{MaxPixels: 100})
+	rec := renderPost(h, `{"template": {"width": 20, "height": 20}}`)
+	if rec.Code != http.StatusUnprocessableEntity || errorCode(t, rec) != "canvas_too_large" {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = renderPost(h, `{"template": {"width": 10, "height": 10}}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+//图片操作输出的大小也不能超过MaxPixels
+func TestRenderMaxPixelsOps(t *testing.T) {
+	h := NewRenderHandler(RenderOptions{MaxPixels: 100, Loader: &countLoader{}})
+	tests := []string{
+		`{"template": {"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "ops": [{"op": "resize", "args": [20, 20]}]}]}}`,
+		`{"template": {"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "pipeline": "warp:0,0,20,0,20,20,0,20"}]}}`,
+		`{"template": {"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "pipeline": "resize:0x20"}]}}`,
+	}
+	for _, body := range tests {
+		rec := renderPost(h, body)
+		if rec.Code != http.StatusUnprocessableEntity || errorCode(t, rec) != "invalid_template" {
+			t.Errorf("%s: got %d %s", body, rec.Code, rec.Body.String())
+		}
+	}
+	rec := renderPost(h, `{"template": {"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "pipeline": "resize:0x10"}]}}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRenderRecoversPanic(t *testing.T) {
+	h := NewRenderHandler(RenderOptions{Loader: panicLoader{}})
+	rec := renderPost(h, `{"template": {"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png"}]}}`)
+	if rec.Code != http.StatusInternalServerError || errorCode(t, rec) != "render_failed" {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSceneContextCanceled(t *testing.T) {
+	tpl, err := imagedraw.ParseTemplate([]byte(`{"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png"}, {"type": "image", "src": "b.png"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	loader := &countLoader{}
+	if _, err := tpl.SceneContext(ctx, loader, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if loader.calls != 0 {
+		t.Errorf("loaded %d images after cancel", loader.calls)
+	}
+}
@@ -0,0 +1,297 @@
+package imagedraw
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+//svg路径中坐标和半径绝对值的上限 远大于图片的尺寸
+const svgPathMaxCoord = 1e7
+
+// PathError 解析svg路径失败时返回的错误 Offset为出错的位置
+type PathError struct {
+	Offset int
+	Err    error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("svg path: at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+//svg路径字符串的扫描器
+type svgPathScanner struct {
+	s   string
+	pos int
+}
+
+func (sc *svgPathScanner) skipSeparators() {
+	for sc.pos < len(sc.s) {
+		switch sc.s[sc.pos] {
+		case ' ', '\t', '\n', '\r', '\f', ',':
+			sc.pos++
+		default:
+			return
+		}
+	}
+}
+
+//下一个字符是否是数字的开始
+func (sc *svgPathScanner) hasNumber() bool {
+	sc.skipSeparators()
+	if sc.pos >= len(sc.s) {
+		return false
+	}
+	c := sc.s[sc.pos]
+	return c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9')
+}
+
+func (sc *svgPathScanner) number() (float64, error) {
+	sc.skipSeparators()
+	start := sc.pos
+	i := sc.pos
+	if i < len(sc.s) && (sc.s[i] == '-' || sc.s[i] == '+') {
+		i++
+	}
+	digits := 0
+	for i < len(sc.s) && sc.s[i] >= '0' && sc.s[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(sc.s) && sc.s[i] == '.' {
+		i++
+		for i < len(sc.s) && sc.s[i] >= '0' && sc.s[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, &PathError{Offset: start, Err: fmt.Errorf("expected number")}
+	}
+	if i < len(sc.s) && (sc.s[i] == 'e' || sc.s[i] == 'E') {
+		j := i + 1
+		if j < len(sc.s) && (sc.s[j] == '-' || sc.s[j] == '+') {
+			j++
+		}
+		if j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+			for j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+	v, err := strconv.ParseFloat(sc.s[start:i], 64)
+	if err != nil {
+		return 0, &PathError{Offset: start, Err: fmt.Errorf("invalid number %q", sc.s[start:i])}
+	}
+	if math.Abs(v) > svgPathMaxCoord {
+		return 0, &PathError{Offset: start, Err: fmt.Errorf("number %q must be between %g and %g", sc.s[start:i], -svgPathMaxCoord, svgPathMaxCoord)}
+	}
+	sc.pos = i
+	return v, nil
+}
+
+//椭圆弧的标志位 只有一个字符0或1 可以不加分隔符
+func (sc *svgPathScanner) flag() (bool, error) {
+	sc.skipSeparators()
+	if sc.pos < len(sc.s) {
+		switch sc.s[sc.pos] {
+		case '0':
+			sc.pos++
+			return false, nil
+		case '1':
+			sc.pos++
+			return true, nil
+		}
+	}
+	return false, &PathError{Offset: sc.pos, Err: fmt.Errorf("expected flag 0 or 1")}
+}
+
+func (sc *svgPathScanner) numbers(n int) ([]float64, error) {
+	v := make([]float64, n)
+	for i := range v {
+		f, err := sc.number()
+		if err != nil {
+			return nil, err
+		}
+		v[i] = f
+	}
+	return v, nil
+}
+
+// ParseSVGPath 解析svg路径的d属性 支持M L H V C S Q T A Z及其小写的相对坐标形式
+//
+//	M10 10 h80 v80 h-80 Z
+func ParseSVGPath(d string) (*Path, error) {
+	p := NewPath()
+	sc := &svgPathScanner{s: d}
+	var cmd byte
+	//上一个三次、二次曲线的控制点 用于S和T命令
+	var lastCubic, lastQuad *pathPoint
+	for {
+		sc.skipSeparators()
+		if sc.pos >= len(sc.s) {
+			break
+		}
+		offset := sc.pos
+		c := sc.s[sc.pos]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			cmd = c
+			sc.pos++
+		} else if cmd == 0 {
+			return nil, &PathError{Offset: offset, Err: fmt.Errorf("path must start with a command")}
+		} else if cmd == 'Z' || cmd == 'z' {
+			return nil, &PathError{Offset: offset, Err: fmt.Errorf("unexpected %q after close", c)}
+		}
+		rel := cmd >= 'a' && cmd <= 'z'
+		cx, cy := p.cur.x, p.cur.y
+		abs := func(x, y float64) (float64, float64) {
+			if rel {
+				return cx + x, cy + y
+			}
+			return x, y
+		}
+		if cmd != 'M' && cmd != 'm' && len(p.ops) == 0 {
+			return nil, &PathError{Offset: offset, Err: fmt.Errorf("path must start with a moveto")}
+		}
+		var nextCubic, nextQuad *pathPoint
+		switch cmd {
+		case 'M', 'm':
+			v, err := sc.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			x, y := abs(v[0], v[1])
+			if len(p.ops) == 0 {
+				//第一个m总是绝对坐标
+				x, y = v[0], v[1]
+			}
+			p.MoveTo(x, y)
+			//之后的坐标对视为lineto
+			if cmd == 'M' {
+				cmd = 'L'
+			} else {
+				cmd = 'l'
+			}
+		case 'L', 'l':
+			v, err := sc.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			p.LineTo(abs(v[0], v[1]))
+		case 'H', 'h':
+			v, err := sc.numbers(1)
+			if err != nil {
+				return nil, err
+			}
+			x := v[0]
+			if rel {
+				x += cx
+			}
+			p.LineTo(x, cy)
+		case 'V', 'v':
+			v, err := sc.numbers(1)
+			if err != nil {
+				return nil, err
+			}
+			y := v[0]
+			if rel {
+				y += cy
+			}
+			p.LineTo(cx, y)
+		case 'C', 'c':
+			v, err := sc.numbers(6)
+			if err != nil {
+				return nil, err
+			}
+			x1, y1 := abs(v[0], v[1])
+			x2, y2 := abs(v[2], v[3])
+			x, y := abs(v[4], v[5])
+			p.CubicTo(x1, y1, x2, y2, x, y)
+			nextCubic = &pathPoint{x2, y2}
+		case 'S', 's':
+			v, err := sc.numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			x1, y1 := cx, cy
+			if lastCubic != nil {
+				x1, y1 = 2*cx-lastCubic.x, 2*cy-lastCubic.y
+			}
+			x2, y2 := abs(v[0], v[1])
+			x, y := abs(v[2], v[3])
+			p.CubicTo(x1, y1, x2, y2, x, y)
+			nextCubic = &pathPoint{x2, y2}
+		case 'Q', 'q':
+			v, err := sc.numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			x1, y1 := abs(v[0], v[1])
+			x, y := abs(v[2], v[3])
+			p.QuadTo(x1, y1, x, y)
+			nextQuad = &pathPoint{x1, y1}
+		case 'T', 't':
+			v, err := sc.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			x1, y1 := cx, cy
+			if lastQuad != nil {
+				x1, y1 = 2*cx-lastQuad.x, 2*cy-lastQuad.y
+			}
+			x, y := abs(v[0], v[1])
+			p.QuadTo(x1, y1, x, y)
+			nextQuad = &pathPoint{x1, y1}
+		case 'A', 'a':
+			v, err := sc.numbers(3)
+			if err != nil {
+				return nil, err
+			}
+			largeArc, err := sc.flag()
+			if err != nil {
+				return nil, err
+			}
+			sweep, err := sc.flag()
+			if err != nil {
+				return nil, err
+			}
+			end, err := sc.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			x, y := abs(end[0], end[1])
+			p.ArcTo(v[0], v[1], v[2], largeArc, sweep, x, y)
+		case 'Z', 'z':
+			p.Close()
+		default:
+			return nil, &PathError{Offset: offset, Err: fmt.Errorf("unknown command %q", cmd)}
+		}
+		//相对坐标累加后也不能超过上限
+		if math.Abs(p.cur.x) > svgPathMaxCoord || math.Abs(p.cur.y) > svgPathMaxCoord {
+			return nil, &PathError{Offset: offset, Err: fmt.Errorf("point (%g,%g) must be between %g and %g", p.cur.x, p.cur.y, -svgPathMaxCoord, svgPathMaxCoord)}
+		}
+		lastCubic, lastQuad = nextCubic, nextQuad
+		//命令之后没有数字时必须是下一个命令
+		if cmd != 'Z' && cmd != 'z' && !sc.hasNumber() && sc.pos < len(sc.s) {
+			c := sc.s[sc.pos]
+			if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+				return nil, &PathError{Offset: sc.pos, Err: fmt.Errorf("unexpected %q", c)}
+			}
+		}
+	}
+	return p, nil
+}
+
+// MustParseSVGPath 解析svg路径 失败时panic
+func MustParseSVGPath(d string) *Path {
+	p, err := ParseSVGPath(d)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -0,0 +1,850 @@
+package imagedraw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Template 以数据描述的海报模板 可以使用不同的变量反复渲染
+//
+//	{
+//	  "width": 750, "height": 1000, "background": "#ffffff",
+//	  "layers": [
+//	    {"type": "image", "name": "avatar", "src": "{{avatar}}", "area": [0, 0, 200, 200],
+//	     "ops": [{"op": "resize", "args": [200, 200], "resizeType": "cubic"}, {"op": "radius", "args": [20, 20, 20, 20]}]},
+//	    {"type": "text", "name": "title", "text": "你好 {{name}}", "area": [0, 220, 750, 100], "fontSize": 48, "color": "#333333"}
+//	  ]
+//	}
+type Template struct {
+	Width      int             `json:"width"`
+	Height     int             `json:"height"`
+	Background string          `json:"background"`
+	Layers     []TemplateLayer `json:"-"`
+	//画布、绘制区域、加载的图片和每个图片操作输出图片的最大像素数 不从json中读取
+	//为0或大于TemplateMaxPixels时使用TemplateMaxPixels 用于服务端进一步限制内存的使用
+	MaxPixels int `json:"-"`
+}
+
+// TemplateLayer 模板中的一个图层 Image和Text有且只有一个不为nil
+type TemplateLayer struct {
+	Image *TemplateImage
+	Text  *TemplateText
+}
+
+// TemplateLayerBase 图层的公共属性
+type TemplateLayerBase struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	//绘制区域 [x, y, w, h]
+	Area []int `json:"area,omitempty"`
+	//层级 层级大的绘制在上面
+	ZIndex int `json:"zIndex,omitempty"`
+	//是否可见 默认true
+	Visible *bool `json:"visible,omitempty"`
+	//不透明度 0-100 默认100
+	Opacity *uint32 `json:"opacity,omitempty"`
+	//以绘制区域的中心顺时针旋转的角度
+	Rotate float64 `json:"rotate,omitempty"`
+	//混合模式 与css的mix-blend-mode相同 如multiply soft-light
+	Blend string `json:"blend,omitempty"`
+}
+
+// TemplateImage 图片图层
+type TemplateImage struct {
+	TemplateLayerBase
+	//图片来源 本地路径或者http链接 支持变量
+	Src string `json:"src,omitempty"`
+	//依次对图片执行的操作
+	Ops []TemplateImageOp `json:"ops,omitempty"`
+	//在Ops之后执行的操作字符串 格式见Pipeline 如cut:0,0,200,200|radius:20
+	Pipeline string `json:"pipeline,omitempty"`
+	//图片与area大小不同时的缩放方式 none fill contain cover scale-down 默认none
+	Fit string `json:"fit,omitempty"`
+	//图片在area中的对齐方式 center top-left top top-right left right bottom-left bottom bottom-right fit为none时默认top-left 否则默认center
+	Align string `json:"align,omitempty"`
+	//缩放到area时使用的缩放算法 默认bilinear
+	ResizeType string `json:"resizeType,omitempty"`
+}
+
+// TemplateImageOp 图片操作
+//
+//	cut [x, y, w, h] 起点需要在图片内
+//	circle [x, y, r]
+//	ellipse [x, y, w, h]
+//	radius [lt, rt, rb, lb]
+//	resize [w, h] resizeType可选cubic bilinear lanczos3 mitchell catmullrom nearest area 其中一边为0时按原图比例计算
+//	hue、saturation、brightness [-100到100]
+//	opacity [0到100]
+type TemplateImageOp struct {
+	Op         string    `json:"op,omitempty"`
+	Args       []float64 `json:"args,omitempty"`
+	ResizeType string    `json:"resizeType,omitempty"`
+}
+
+// TemplateText 文本图层 字段与Text的设置方法一一对应
+type TemplateText struct {
+	TemplateLayerBase
+	//字符串 支持变量
+	Text string `json:"text,omitempty"`
+	//自定义多行字符串 支持变量
+	Lines []string `json:"lines,omitempty"`
+	//字体 siyuanheiti、siyuanheiti-bold、本地路径或者http链接 默认siyuanheiti
+	Font           string `json:"font,omitempty"`
+	FontSize       int    `json:"fontSize,omitempty"`
+	Dpi            int    `json:"dpi,omitempty"`
+	TextAlign      string `json:"textAlign,omitempty"`
+	MaxLineNum     int    `json:"maxLineNum,omitempty"`
+	OutStr         string `json:"outStr,omitempty"`
+	OutStrPosition string `json:"outStrPosition,omitempty"`
+	Color          string `json:"color,omitempty"`
+	LineHeight     int    `json:"lineHeight,omitempty"`
+	OverHidden     *bool  `json:"overHidden,omitempty"`
+	AutoLine       *bool  `json:"autoLine,omitempty"`
+}
+
+// 模板中数值的上限 超过时Validate返回错误 避免超大的画布或图片耗尽内存
+const (
+	//画布、绘制区域和图片操作的宽高、坐标的最大值 也是文字像素大小的最大值
+	TemplateMaxSize = 1 << 14
+	//画布、绘制区域和图片操作输出图片的最大像素数
+	TemplateMaxPixels = 1 << 26
+)
+
+// TemplateLoader 加载模板中用到的图片和字体
+type TemplateLoader interface {
+	LoadImage(src string) (*Image, error)
+	LoadFont(name string) (IDrawString, error)
+}
+
+// TemplateError 模板错误 Path为出错的字段 如layers[1].fontSize
+type TemplateError struct {
+	Path string
+	Err  error
+}
+
+func (e *TemplateError) Error() string {
+	if e.Path == "" {
+		return "template: " + e.Err.Error()
+	}
+	return "template: " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+func templateErrorf(path, format string, args ...interface{}) error {
+	return &TemplateError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+// 模板json的结构 图层需要根据type再解析
+type templateJSON struct {
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	Background string            `json:"background"`
+	Layers     []json.RawMessage `json:"layers"`
+}
+
+// ParseTemplate 解析json格式的模板
+func ParseTemplate(data []byte) (*Template, error) {
+	var tj templateJSON
+	if err := decodeStrict(data, &tj); err != nil {
+		return nil, &TemplateError{Err: err}
+	}
+	t := &Template{
+		Width:      tj.Width,
+		Height:     tj.Height,
+		Background: tj.Background,
+	}
+	for i, raw := range tj.Layers {
+		path := fmt.Sprintf("layers[%d]", i)
+		var base struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &base); err != nil {
+			return nil, &TemplateError{Path: path, Err: err}
+		}
+		switch base.Type {
+		case "image":
+			layer := &TemplateImage{}
+			if err := decodeStrict(raw, layer); err != nil {
+				return nil, &TemplateError{Path: path, Err: err}
+			}
+			t.Layers = append(t.Layers, TemplateLayer{Image: layer})
+		case "text":
+			layer := &TemplateText{}
+			if err := decodeStrict(raw, layer); err != nil {
+				return nil, &TemplateError{Path: path, Err: err}
+			}
+			t.Layers = append(t.Layers, TemplateLayer{Text: layer})
+		default:
+			return nil, templateErrorf(path+".type", "unknown layer type %q, must be image or text", base.Type)
+		}
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ReadTemplate 从reader中读取json格式的模板
+func ReadTemplate(reader io.Reader) (*Template, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplate(data)
+}
+
+// LoadTemplate 从本地读取json格式的模板
+func LoadTemplate(path string) (*Template, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplate(data)
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after json value")
+	}
+	return nil
+}
+
+// MarshalJSON 将模板转化为json
+func (t *Template) MarshalJSON() ([]byte, error) {
+	layers := make([]interface{}, 0, len(t.Layers))
+	for _, l := range t.Layers {
+		if l.Image != nil {
+			layer := *l.Image
+			layer.Type = "image"
+			layers = append(layers, layer)
+		} else if l.Text != nil {
+			layer := *l.Text
+			layer.Type = "text"
+			layers = append(layers, layer)
+		}
+	}
+	return json.Marshal(struct {
+		Width      int           `json:"width"`
+		Height     int           `json:"height"`
+		Background string        `json:"background,omitempty"`
+		Layers     []interface{} `json:"layers"`
+	}{t.Width, t.Height, t.Background, layers})
+}
+
+// UnmarshalJSON 解析json格式的模板
+func (t *Template) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseTemplate(data)
+	if err != nil {
+		return err
+	}
+	*t = *parsed
+	return nil
+}
+
+// Validate 检查模板中的值是否合法
+func (t *Template) Validate() error {
+	if t.Width <= 0 || t.Height <= 0 {
+		return templateErrorf("width", "canvas size must be positive, got %dx%d", t.Width, t.Height)
+	}
+	maxPixels := t.maxPixels()
+	if err := checkTemplateSize(float64(t.Width), float64(t.Height), maxPixels); err != nil {
+		return &TemplateError{Path: "width", Err: err}
+	}
+	if t.Background != "" {
+		if _, err := ParseColor(t.Background); err != nil {
+			return &TemplateError{Path: "background", Err: err}
+		}
+	}
+	for i, l := range t.Layers {
+		path := fmt.Sprintf("layers[%d]", i)
+		switch {
+		case l.Image != nil:
+			if err := l.Image.validate(path, maxPixels); err != nil {
+				return err
+			}
+		case l.Text != nil:
+			if err := l.Text.validate(path, maxPixels); err != nil {
+				return err
+			}
+		default:
+			return templateErrorf(path, "empty layer")
+		}
+	}
+	return nil
+}
+
+func (b *TemplateLayerBase) validate(path string, maxPixels int) error {
+	if b.Area != nil {
+		if len(b.Area) != 4 {
+			return templateErrorf(path+".area", "must be [x, y, w, h], got %d values", len(b.Area))
+		}
+		if b.Area[2] < 0 || b.Area[3] < 0 {
+			return templateErrorf(path+".area", "width and height must not be negative")
+		}
+		for k, v := range b.Area {
+			if v > TemplateMaxSize || v < -TemplateMaxSize {
+				return templateErrorf(fmt.Sprintf("%s.area[%d]", path, k), "must be between %d and %d, got %d", -TemplateMaxSize, TemplateMaxSize, v)
+			}
+		}
+		if err := checkTemplateSize(float64(b.Area[2]), float64(b.Area[3]), maxPixels); err != nil {
+			return &TemplateError{Path: path + ".area", Err: err}
+		}
+	}
+	if math.IsNaN(b.Rotate) || math.IsInf(b.Rotate, 0) {
+		return templateErrorf(path+".rotate", "must be a finite number")
+	}
+	if b.Opacity != nil && *b.Opacity > 100 {
+		return templateErrorf(path+".opacity", "must be between 0 and 100, got %d", *b.Opacity)
+	}
+	if b.Blend != "" {
+		if _, err := ParseBlendMode(b.Blend); err != nil {
+			return &TemplateError{Path: path + ".blend", Err: err}
+		}
+	}
+	return nil
+}
+
+func (b *TemplateLayerBase) area() (x, y, w, h int) {
+	if len(b.Area) != 4 {
+		return 0, 0, 0, 0
+	}
+	return b.Area[0], b.Area[1], b.Area[2], b.Area[3]
+}
+
+// 图片操作的参数个数
+var templateImageOpArgs = map[string]int{
+	"cut":        4,
+	"circle":     3,
+	"ellipse":    4,
+	"radius":     4,
+	"resize":     2,
+	"hue":        1,
+	"saturation": 1,
+	"brightness": 1,
+	"opacity":    1,
+}
+
+func (l *TemplateImage) validate(path string, maxPixels int) error {
+	if err := l.TemplateLayerBase.validate(path, maxPixels); err != nil {
+		return err
+	}
+	if l.Src == "" {
+		return templateErrorf(path+".src", "image source is required")
+	}
+	p, err := ParsePipeline(l.Pipeline)
+	if err != nil {
+		return &TemplateError{Path: path + ".pipeline", Err: err}
+	}
+	if extent := p.extent(); extent > TemplateMaxSize {
+		return templateErrorf(path+".pipeline", "coordinates must be between %d and %d, got %v", -TemplateMaxSize, TemplateMaxSize, extent)
+	}
+	//图片的大小在加载之后才知道 这里只检查不依赖图片大小的操作
+	if err := p.CheckSize(-1, -1, checkTemplatePixels(maxPixels)); err != nil {
+		return &TemplateError{Path: path + ".pipeline", Err: err}
+	}
+	if l.Fit != "" {
+		if _, err := ParseObjectFit(l.Fit); err != nil {
+			return &TemplateError{Path: path + ".fit", Err: err}
+		}
+	}
+	if l.Align != "" {
+		if _, err := ParseAnchor(l.Align); err != nil {
+			return &TemplateError{Path: path + ".align", Err: err}
+		}
+	}
+	if l.ResizeType != "" {
+		if _, err := ParseResizeType(l.ResizeType); err != nil {
+			return &TemplateError{Path: path + ".resizeType", Err: err}
+		}
+	}
+	for i, op := range l.Ops {
+		opPath := fmt.Sprintf("%s.ops[%d]", path, i)
+		n, ok := templateImageOpArgs[op.Op]
+		if !ok {
+			return templateErrorf(opPath+".op", "unknown image operation %q", op.Op)
+		}
+		if len(op.Args) != n {
+			return templateErrorf(opPath+".args", "%s needs %d arguments, got %d", op.Op, n, len(op.Args))
+		}
+		if op.ResizeType != "" {
+			if op.Op != "resize" {
+				return templateErrorf(opPath+".resizeType", "only allowed for resize")
+			}
+			if _, err := ParseResizeType(op.ResizeType); err != nil {
+				return &TemplateError{Path: opPath + ".resizeType", Err: err}
+			}
+		}
+		for j, arg := range op.Args {
+			argPath := fmt.Sprintf("%s.args[%d]", opPath, j)
+			if math.IsNaN(arg) || math.IsInf(arg, 0) {
+				return templateErrorf(argPath, "must be a finite number")
+			}
+			if arg != math.Trunc(arg) && op.Op != "hue" && op.Op != "saturation" && op.Op != "brightness" {
+				return templateErrorf(argPath, "must be an integer, got %v", arg)
+			}
+			if arg > TemplateMaxSize || arg < -TemplateMaxSize {
+				return templateErrorf(argPath, "must be between %d and %d, got %v", -TemplateMaxSize, TemplateMaxSize, arg)
+			}
+		}
+		var err error
+		switch op.Op {
+		case "cut":
+			if op.Args[0] < 0 || op.Args[1] < 0 {
+				return templateErrorf(opPath+".args", "cut position must not be negative")
+			}
+			if op.Args[2] <= 0 || op.Args[3] <= 0 {
+				return templateErrorf(opPath+".args", "%s size must be positive", op.Op)
+			}
+			err = checkTemplateSize(op.Args[2], op.Args[3], maxPixels)
+		case "ellipse":
+			if op.Args[2] <= 0 || op.Args[3] <= 0 {
+				return templateErrorf(opPath+".args", "%s size must be positive", op.Op)
+			}
+			err = checkTemplateSize(2*op.Args[2], 2*op.Args[3], maxPixels)
+		case "resize":
+			if op.Args[0] < 0 || op.Args[1] < 0 || (op.Args[0] == 0 && op.Args[1] == 0) {
+				return templateErrorf(opPath+".args", "resize size must not be negative and at most one side may be 0")
+			}
+			err = checkTemplateSize(op.Args[0], op.Args[1], maxPixels)
+		case "circle":
+			if op.Args[2] <= 0 {
+				return templateErrorf(opPath+".args", "circle radius must be positive")
+			}
+			err = checkTemplateSize(2*op.Args[2], 2*op.Args[2], maxPixels)
+		case "radius":
+			for j, arg := range op.Args {
+				if arg < 0 {
+					return templateErrorf(fmt.Sprintf("%s.args[%d]", opPath, j), "radius must not be negative")
+				}
+			}
+		case "hue", "saturation", "brightness":
+			if op.Args[0] < -100 || op.Args[0] > 100 {
+				return templateErrorf(opPath+".args", "%s must be between -100 and 100, got %v", op.Op, op.Args[0])
+			}
+		case "opacity":
+			if op.Args[0] < 0 || op.Args[0] > 100 {
+				return templateErrorf(opPath+".args", "opacity must be between 0 and 100, got %v", op.Args[0])
+			}
+		}
+		if err != nil {
+			return &TemplateError{Path: opPath + ".args", Err: err}
+		}
+	}
+	return nil
+}
+
+//检查输出图片的大小 w、h为负数时表示未知 不检查该边
+func checkTemplateSize(w, h float64, maxPixels int) error {
+	if w > TemplateMaxSize || h > TemplateMaxSize {
+		return fmt.Errorf("width and height must not be larger than %d, got %vx%v", TemplateMaxSize, w, h)
+	}
+	if w*h > float64(maxPixels) {
+		return fmt.Errorf("size %vx%v is larger than %d pixels", w, h, maxPixels)
+	}
+	return nil
+}
+
+//用于Pipeline.CheckSize
+func checkTemplatePixels(maxPixels int) func(w, h int) error {
+	return func(w, h int) error {
+		return checkTemplateSize(float64(w), float64(h), maxPixels)
+	}
+}
+
+//图片的最大像素数
+func (t *Template) maxPixels() int {
+	if t.MaxPixels <= 0 || t.MaxPixels > TemplateMaxPixels {
+		return TemplateMaxPixels
+	}
+	return t.MaxPixels
+}
+
+func (l *TemplateText) validate(path string, maxPixels int) error {
+	if err := l.TemplateLayerBase.validate(path, maxPixels); err != nil {
+		return err
+	}
+	if l.Text != "" && l.Lines != nil {
+		return templateErrorf(path, "text and lines can not be used together")
+	}
+	if l.FontSize < 0 {
+		return templateErrorf(path+".fontSize", "must be positive, got %d", l.FontSize)
+	}
+	if l.Dpi < 0 {
+		return templateErrorf(path+".dpi", "must be positive, got %d", l.Dpi)
+	}
+	//按dpi换算后的像素大小
+	dpi := l.Dpi
+	if dpi == 0 {
+		dpi = 72
+	}
+	if float64(l.FontSize)*float64(dpi)/72 > TemplateMaxSize {
+		return templateErrorf(path+".fontSize", "font size must not be larger than %d pixels", TemplateMaxSize)
+	}
+	if l.LineHeight < 0 || l.LineHeight > TemplateMaxSize {
+		return templateErrorf(path+".lineHeight", "must be between 0 and %d, got %d", TemplateMaxSize, l.LineHeight)
+	}
+	if l.MaxLineNum < 0 {
+		return templateErrorf(path+".maxLineNum", "must not be negative, got %d", l.MaxLineNum)
+	}
+	switch l.TextAlign {
+	case "", "left", "right", "center":
+	default:
+		return templateErrorf(path+".textAlign", "must be left, right or center, got %q", l.TextAlign)
+	}
+	switch l.OutStrPosition {
+	case "", "left", "right":
+	default:
+		return templateErrorf(path+".outStrPosition", "must be left or right, got %q", l.OutStrPosition)
+	}
+	if l.Color != "" {
+		if _, err := ParseColor(l.Color); err != nil {
+			return &TemplateError{Path: path + ".color", Err: err}
+		}
+	}
+	return nil
+}
+
+// Render 使用变量渲染模板 图片和字体从本地路径或者http链接加载
+func (t *Template) Render(vars map[string]string) (*Image, error) {
+	return t.RenderContext(context.Background(), FileLoader{}, vars)
+}
+
+// RenderContext 使用指定的加载方式和变量渲染模板 ctx可用于取消渲染
+//
+// ctx只在加载和绘制每个图层之前检查 正在执行的图片操作不会被中断
+func (t *Template) RenderContext(ctx context.Context, loader TemplateLoader, vars map[string]string) (*Image, error) {
+	scene, err := t.SceneContext(ctx, loader, vars)
+	if err != nil {
+		return nil, err
+	}
+	return scene.RenderContext(ctx)
+}
+
+// Scene 使用变量将模板转化为场景 可以继续修改图层后再渲染
+func (t *Template) Scene(loader TemplateLoader, vars map[string]string) (*Scene, error) {
+	return t.SceneContext(context.Background(), loader, vars)
+}
+
+// SceneContext 与Scene相同 ctx取消后不再加载之后图层的图片和字体 返回ctx的错误
+func (t *Template) SceneContext(ctx context.Context, loader TemplateLoader, vars map[string]string) (*Scene, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	scene := NewScene(t.Width, t.Height)
+	if t.Background != "" {
+		c, _ := ParseColor(t.Background)
+		scene.SetBackground(c)
+	}
+	for i, l := range t.Layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path := fmt.Sprintf("layers[%d]", i)
+		var item FillItem
+		var base *TemplateLayerBase
+		var err error
+		if l.Image != nil {
+			base = &l.Image.TemplateLayerBase
+			item, err = l.Image.build(path, loader, vars, t.maxPixels())
+		} else {
+			base = &l.Text.TemplateLayerBase
+			item, err = l.Text.build(path, loader, vars)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if base.Blend != "" {
+			mode, _ := ParseBlendMode(base.Blend)
+			switch it := item.(type) {
+			case *Image:
+				it.SetBlendMode(mode)
+			case *Text:
+				it.SetBlendMode(mode)
+			}
+		}
+		if base.Rotate != 0 {
+			item = NewTransformed(item).Rotate(base.Rotate, AnchorCenter)
+		}
+		name := base.Name
+		if name == "" {
+			name = path
+		}
+		layer := scene.AddLayer(name, item).SetZIndex(base.ZIndex)
+		if base.Visible != nil {
+			layer.SetVisible(*base.Visible)
+		}
+		if base.Opacity != nil {
+			layer.SetOpacity(*base.Opacity)
+		}
+	}
+	return scene, nil
+}
+
+func (l *TemplateImage) build(path string, loader TemplateLoader, vars map[string]string, maxPixels int) (FillItem, error) {
+	src, err := expandVars(l.Src, vars)
+	if err != nil {
+		return nil, &TemplateError{Path: path + ".src", Err: err}
+	}
+	img, err := loader.LoadImage(src)
+	if err != nil {
+		return nil, &TemplateError{Path: path + ".src", Err: err}
+	}
+	if err := checkTemplateSize(float64(img.Width()), float64(img.Height()), maxPixels); err != nil {
+		return nil, &TemplateError{Path: path + ".src", Err: err}
+	}
+	for i, op := range l.Ops {
+		a := op.Args
+		switch op.Op {
+		case "cut":
+			//图片的大小在加载之后才知道 剪切的起点必须在图片内
+			if int(a[0]) >= img.Width() || int(a[1]) >= img.Height() {
+				return nil, templateErrorf(fmt.Sprintf("%s.ops[%d].args", path, i), "cut position (%v, %v) is outside the %dx%d image", a[0], a[1], img.Width(), img.Height())
+			}
+			img = img.Cut(int(a[0]), int(a[1]), int(a[2]), int(a[3]))
+		case "circle":
+			img = img.Circle(int(a[0]), int(a[1]), int(a[2]))
+		case "ellipse":
+			img = img.Ellipse(int(a[0]), int(a[1]), int(a[2]), int(a[3]))
+		case "radius":
+			img = img.BorderRadius(uint(a[0]), uint(a[1]), uint(a[2]), uint(a[3]))
+		case "resize":
+			resizeType := BilinearInterpolation
+			if op.ResizeType != "" {
+				resizeType, _ = ParseResizeType(op.ResizeType)
+			}
+			//其中一边为0时按原图比例计算 计算后的大小也不能超过限制
+			w, h := keepAspectSize(img.Width(), img.Height(), int(a[0]), int(a[1]))
+			if err := checkTemplateSize(float64(w), float64(h), maxPixels); err != nil {
+				return nil, &TemplateError{Path: fmt.Sprintf("%s.ops[%d].args", path, i), Err: err}
+			}
+			img = img.Resize(w, h, resizeType)
+		case "hue":
+			img = img.Hue(a[0])
+		case "saturation":
+			img = img.Saturation(a[0])
+		case "brightness":
+			img = img.Brightness(a[0])
+		case "opacity":
+			img = img.Opacity(uint32(a[0]))
+		}
+	}
+	if l.Pipeline != "" {
+		p, err := ParsePipeline(l.Pipeline)
+		if err != nil {
+			return nil, &TemplateError{Path: path + ".pipeline", Err: err}
+		}
+		if err := p.CheckSize(img.Width(), img.Height(), checkTemplatePixels(maxPixels)); err != nil {
+			return nil, &TemplateError{Path: path + ".pipeline", Err: err}
+		}
+		img = p.Apply(img)
+	}
+	if l.Area != nil {
+		img.SetArea(l.area())
+	} else {
+		img.SetArea(0, 0, img.Width(), img.Height())
+	}
+	if l.Fit != "" {
+		fit, _ := ParseObjectFit(l.Fit)
+		img.SetFit(fit)
+	}
+	if l.Align != "" {
+		align, _ := ParseAnchor(l.Align)
+		img.SetAlign(align)
+	}
+	if l.ResizeType != "" {
+		resizeType, _ := ParseResizeType(l.ResizeType)
+		img.SetResizeType(resizeType)
+	}
+	return img, nil
+}
+
+func (l *TemplateText) build(path string, loader TemplateLoader, vars map[string]string) (FillItem, error) {
+	var text *Text
+	if l.Lines != nil {
+		lines := make([]string, len(l.Lines))
+		for i, line := range l.Lines {
+			s, err := expandVars(line, vars)
+			if err != nil {
+				return nil, &TemplateError{Path: fmt.Sprintf("%s.lines[%d]", path, i), Err: err}
+			}
+			lines[i] = s
+		}
+		text = NewLineText(lines)
+	} else {
+		s, err := expandVars(l.Text, vars)
+		if err != nil {
+			return nil, &TemplateError{Path: path + ".text", Err: err}
+		}
+		text = NewText(s)
+	}
+
+	d, err := loader.LoadFont(l.Font)
+	if err != nil {
+		return nil, &TemplateError{Path: path + ".font", Err: err}
+	}
+	text.SetFont(d)
+	if l.Area != nil {
+		text.SetArea(l.area())
+	}
+	if l.FontSize > 0 {
+		text.SetFontSize(l.FontSize)
+	}
+	if l.Dpi > 0 {
+		text.SetDpi(l.Dpi)
+	}
+	if l.TextAlign != "" {
+		text.SetTextAlign(l.TextAlign)
+	}
+	if l.MaxLineNum > 0 {
+		text.SetMaxLineNum(l.MaxLineNum)
+	}
+	if l.OutStr != "" {
+		outStr, err := expandVars(l.OutStr, vars)
+		if err != nil {
+			return nil, &TemplateError{Path: path + ".outStr", Err: err}
+		}
+		text.SetOutStr(outStr)
+	}
+	if l.OutStrPosition != "" {
+		text.SetOutStrPosition(l.OutStrPosition)
+	}
+	if l.Color != "" {
+		c, _ := ParseColor(l.Color)
+		text.SetColor(color.RGBAModel.Convert(c).(color.RGBA))
+	}
+	if l.LineHeight > 0 {
+		text.SetLineHeight(l.LineHeight)
+	}
+	if l.OverHidden != nil {
+		text.SetOverHidden(*l.OverHidden)
+	}
+	if l.AutoLine != nil {
+		text.SetAutoLine(*l.AutoLine)
+	}
+	return text, nil
+}
+
+var templateVarRegexp = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// 将字符串中的{{name}}替换为变量的值 变量不存在时返回错误
+func expandVars(s string, vars map[string]string) (string, error) {
+	var err error
+	result := templateVarRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		name := templateVarRegexp.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %q", name)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// ParseColor 解析颜色 支持#rgb #rrggbb #rrggbbaa
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 || !strings.HasPrefix(s, "#") {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, must be #rgb, #rrggbb or #rrggbbaa", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, must be #rgb, #rrggbb or #rrggbbaa", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// FileLoader 从本地路径或者http链接加载图片和字体 相对路径基于Dir
+type FileLoader struct {
+	Dir string
+}
+
+// LoadImage 实现TemplateLoader接口
+func (f FileLoader) LoadImage(src string) (*Image, error) {
+	if isHttpUrl(src) {
+		return LoadImageFromUrl(src)
+	}
+	return LoadImage(f.path(src))
+}
+
+// LoadFont 实现TemplateLoader接口
+func (f FileLoader) LoadFont(name string) (IDrawString, error) {
+	if d, ok := BuiltinFont(name); ok {
+		return d, nil
+	}
+	isOTF := strings.EqualFold(filepath.Ext(name), ".otf")
+	if isHttpUrl(name) {
+		if isOTF {
+			font, err := LoadOTFHttp(name)
+			if err != nil {
+				return nil, err
+			}
+			return NewOTFDraw(font), nil
+		}
+		font, err := LoadTTFHttp(name)
+		if err != nil {
+			return nil, err
+		}
+		return NewTTFDraw(font), nil
+	}
+	if isOTF {
+		font, err := LoadOTF(f.path(name))
+		if err != nil {
+			return nil, err
+		}
+		return NewOTFDraw(font), nil
+	}
+	font, err := LoadTTF(f.path(name))
+	if err != nil {
+		return nil, err
+	}
+	return NewTTFDraw(font), nil
+}
+
+func (f FileLoader) path(name string) string {
+	if f.Dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(f.Dir, name)
+}
+
+// BuiltinFont 根据名称返回内置字体 siyuanheiti(默认)、siyuanheiti-bold
+func BuiltinFont(name string) (IDrawString, bool) {
+	switch name {
+	case "", "siyuanheiti":
+		return SiYuanHeiYi(), true
+	case "siyuanheiti-bold":
+		return SiYuanHeiYiBold(), true
+	}
+	return nil, false
+}
+
+func isHttpUrl(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
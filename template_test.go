@@ -0,0 +1,282 @@
+package imagedraw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//返回固定大小图片的loader
+type sizeLoader struct {
+	w, h int
+}
+
+func (l sizeLoader) LoadImage(src string) (*Image, error) {
+	return NewBaseImage(l.w, l.h), nil
+}
+
+func (l sizeLoader) LoadFont(name string) (IDrawString, error) {
+	return nil, errors.New("no fonts")
+}
+
+func TestTemplateValidateLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		path string
+	}{
+		{"huge canvas", `{"width": 3000000000, "height": 10}`, "width"},
+		{"too many pixels", `{"width": 16384, "height": 16384}`, "width"},
+		{"huge area", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "area": [0, 0, 2000000000, 10], "fit": "fill"}]}`, "layers[0].area[2]"},
+		{"huge area offset", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "area": [-2000000000, 0, 10, 10]}]}`, "layers[0].area[0]"},
+		{"huge cut", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "ops": [{"op": "cut", "args": [0, 0, 1e300, 1]}]}]}`, "layers[0].ops[0].args[2]"},
+		{"huge resize", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "ops": [{"op": "resize", "args": [1e12, 0]}]}]}`, "layers[0].ops[0].args[0]"},
+		{"resize pixels", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "ops": [{"op": "resize", "args": [16384, 16384]}]}]}`, "layers[0].ops[0].args"},
+		{"huge circle", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "ops": [{"op": "circle", "args": [0, 0, 10000]}]}]}`, "layers[0].ops[0].args"},
+		{"pipeline resize", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "resize:200000x200000"}]}`, "layers[0].pipeline"},
+		{"pipeline resize pixels", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "resize:0x100|fit:16384x16384"}]}`, "layers[0].pipeline"},
+		{"pipeline warp", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "warp:0,0,900000,0,900000,900000,0,900000"}]}`, "layers[0].pipeline"},
+		{"pipeline rectify", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "rectify:0,0,10000,0,10000,10000,0,10000"}]}`, "layers[0].pipeline"},
+		{"pipeline cover", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "cover:16384x16384"}]}`, "layers[0].pipeline"},
+		{"pipeline clip", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "clip:M0 0 L1e9 0 L0 10 Z"}]}`, "layers[0].pipeline"},
+		{"pipeline feather", `{"width": 100, "height": 100, "layers": [{"type": "image", "src": "a.png", "pipeline": "feather:1000000"}]}`, "layers[0].pipeline"},
+		{"huge font", `{"width": 100, "height": 100, "layers": [{"type": "text", "text": "a", "fontSize": 1000, "dpi": 7200}]}`, "layers[0].fontSize"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTemplate([]byte(tt.json))
+		var te *TemplateError
+		if !errors.As(err, &te) {
+			t.Errorf("%s: got %v, want a TemplateError", tt.name, err)
+			continue
+		}
+		if te.Path != tt.path {
+			t.Errorf("%s: got path %q, want %q (%v)", tt.name, te.Path, tt.path, err)
+		}
+	}
+}
+
+func TestTemplateValidateNonFinite(t *testing.T) {
+	tpl := &Template{Width: 10, Height: 10, Layers: []TemplateLayer{{Image: &TemplateImage{
+		Src: "a.png",
+		Ops: []TemplateImageOp{{Op: "hue", Args: []float64{math.NaN()}}},
+	}}}}
+	var te *TemplateError
+	if err := tpl.Validate(); !errors.As(err, &te) || te.Path != "layers[0].ops[0].args[0]" {
+		t.Errorf("got %v, want an error at layers[0].ops[0].args[0]", err)
+	}
+}
+
+func TestTemplatePipelineSizeLimit(t *testing.T) {
+	tpl := &Template{Width: 10, Height: 10, Layers: []TemplateLayer{{Image: &TemplateImage{
+		Src:      "a.png",
+		Pipeline: "resize:0x16384",
+	}}}}
+	if err := tpl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	//按比例计算出的宽度为16384*1000
+	_, err := tpl.Scene(sizeLoader{1000, 1}, nil)
+	var te *TemplateError
+	if !errors.As(err, &te) || te.Path != "layers[0].pipeline" {
+		t.Fatalf("got %v, want an error at layers[0].pipeline", err)
+	}
+	var pe *PipelineError
+	if !errors.As(err, &pe) || pe.Token != "resize:0x16384" {
+		t.Errorf("got %v, want a PipelineError for resize", err)
+	}
+
+	//旋转后的大小依赖图片的大小
+	tpl.Layers[0].Image.Pipeline = "rotate:45"
+	if _, err := tpl.Scene(sizeLoader{16000, 1}, nil); !errors.As(err, &te) || te.Path != "layers[0].pipeline" {
+		t.Errorf("got %v, want an error at layers[0].pipeline", err)
+	}
+	if _, err := tpl.Scene(sizeLoader{20, 10}, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTemplateResizeKeepAspectLimit(t *testing.T) {
+	tpl := &Template{Width: 10, Height: 10, Layers: []TemplateLayer{{Image: &TemplateImage{
+		Src: "a.png",
+		Ops: []TemplateImageOp{{Op: "resize", Args: []float64{16384, 0}}},
+	}}}}
+	//按比例计算出的高度为16384*1000
+	_, err := tpl.Scene(sizeLoader{1, 1000}, nil)
+	var te *TemplateError
+	if !errors.As(err, &te) || te.Path != "layers[0].ops[0].args" {
+		t.Fatalf("got %v, want an error at layers[0].ops[0].args", err)
+	}
+
+	tpl.Layers[0].Image.Ops[0].Args = []float64{8, 0}
+	scene, err := tpl.Scene(sizeLoader{2, 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := scene.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Image().Bounds(); b != image.Rect(0, 0, 10, 10) {
+		t.Errorf("got bounds %v", b)
+	}
+	if c := img.Image().At(0, 0); c != (color.RGBA{}) {
+		t.Errorf("got %v, want transparent", c)
+	}
+}
+
+//记录加载的图片地址和字体的loader
+type recordLoader struct {
+	images, fonts []string
+}
+
+func (l *recordLoader) LoadImage(src string) (*Image, error) {
+	l.images = append(l.images, src)
+	return NewBaseImage(8, 8), nil
+}
+
+func (l *recordLoader) LoadFont(name string) (IDrawString, error) {
+	l.fonts = append(l.fonts, name)
+	d, _ := BuiltinFont("")
+	return d, nil
+}
+
+func TestTemplateVars(t *testing.T) {
+	tpl, err := ParseTemplate([]byte(`{"width": 20, "height": 20, "layers": [
+		{"type": "image", "name": "avatar", "src": "avatars/{{ id }}.png"},
+		{"type": "text", "name": "title", "text": "hello {{name}}, {{name}}", "outStr": "{{more}}"},
+		{"type": "text", "name": "lines", "lines": ["{{name}}", "id {{id}}"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader := &recordLoader{}
+	scene, err := tpl.Scene(loader, map[string]string{"id": "42", "name": "Tom", "more": "..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loader.images) != 1 || loader.images[0] != "avatars/42.png" {
+		t.Errorf("loaded images %v, want avatars/42.png", loader.images)
+	}
+	title := scene.Layer("title").Item().(*Text)
+	if title.s != "hello Tom, Tom" || title.outStr != "..." {
+		t.Errorf("got text %q outStr %q", title.s, title.outStr)
+	}
+	lines := scene.Layer("lines").Item().(*Text)
+	if len(lines.lines) != 2 || lines.lines[0] != "Tom" || lines.lines[1] != "id 42" {
+		t.Errorf("got lines %q", lines.lines)
+	}
+
+	//未定义的变量返回错误 错误中包含字段和变量名
+	tests := []struct {
+		vars map[string]string
+		path string
+	}{
+		{map[string]string{"name": "Tom", "more": ""}, "layers[0].src"},
+		{map[string]string{"id": "1", "more": ""}, "layers[1].text"},
+		{map[string]string{"id": "1", "name": "Tom"}, "layers[1].outStr"},
+	}
+	for _, tt := range tests {
+		_, err := tpl.Scene(&recordLoader{}, tt.vars)
+		var te *TemplateError
+		if !errors.As(err, &te) || te.Path != tt.path || !strings.Contains(err.Error(), "undefined variable") {
+			t.Errorf("vars %v: got %v, want an undefined variable error at %s", tt.vars, err, tt.path)
+		}
+	}
+}
+
+func TestTemplateParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		path string
+	}{
+		{"unknown field", `{"width": 10, "height": 10, "color": "#fff"}`, ""},
+		{"unknown layer field", `{"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "size": 3}]}`, "layers[0]"},
+		{"text field on image", `{"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "fontSize": 3}]}`, "layers[0]"},
+		{"unknown layer type", `{"width": 10, "height": 10, "layers": [{"type": "video", "src": "a.mp4"}]}`, "layers[0].type"},
+		{"missing layer type", `{"width": 10, "height": 10, "layers": [{"src": "a.png"}]}`, "layers[0].type"},
+		{"trailing data", `{"width": 10, "height": 10} {}`, ""},
+		{"negative cut", `{"width": 10, "height": 10, "layers": [{"type": "image", "src": "a.png", "ops": [{"op": "cut", "args": [-1, 0, 5, 5]}]}]}`, "layers[0].ops[0].args"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTemplate([]byte(tt.json))
+		var te *TemplateError
+		if !errors.As(err, &te) {
+			t.Errorf("%s: got %v, want a TemplateError", tt.name, err)
+			continue
+		}
+		if te.Path != tt.path {
+			t.Errorf("%s: got path %q, want %q (%v)", tt.name, te.Path, tt.path, err)
+		}
+	}
+}
+
+func TestTemplateCutOutsideImage(t *testing.T) {
+	tpl := &Template{Width: 10, Height: 10, Layers: []TemplateLayer{{Image: &TemplateImage{
+		Src: "a.png",
+		Ops: []TemplateImageOp{{Op: "cut", Args: []float64{8, 0, 4, 4}}},
+	}}}}
+	if err := tpl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var te *TemplateError
+	if _, err := tpl.Scene(sizeLoader{8, 8}, nil); !errors.As(err, &te) || te.Path != "layers[0].ops[0].args" {
+		t.Errorf("got %v, want an error at layers[0].ops[0].args", err)
+	}
+	//起点在图片内时超出的部分是透明的
+	tpl.Layers[0].Image.Ops[0].Args = []float64{7, 7, 4, 4}
+	if _, err := tpl.Scene(sizeLoader{8, 8}, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTemplateMarshalRoundTrip(t *testing.T) {
+	visible := false
+	opacity := uint32(40)
+	autoLine := true
+	tpl := &Template{Width: 300, Height: 200, Background: "#ffeedd", Layers: []TemplateLayer{
+		{Image: &TemplateImage{
+			TemplateLayerBase: TemplateLayerBase{Name: "photo", Area: []int{10, 10, 100, 80}, ZIndex: 2, Visible: &visible, Rotate: 15, Blend: "multiply"},
+			Src:               "{{photo}}",
+			Ops:               []TemplateImageOp{{Op: "resize", Args: []float64{100, 0}, ResizeType: "lanczos3"}, {Op: "hue", Args: []float64{12.5}}},
+			Pipeline:          "radius:8",
+			Fit:               "cover",
+			Align:             "top",
+		}},
+		{Text: &TemplateText{
+			TemplateLayerBase: TemplateLayerBase{Name: "title", Area: []int{0, 100, 300, 50}, Opacity: &opacity},
+			Text:              "hi {{name}}",
+			FontSize:          24,
+			TextAlign:         "center",
+			Color:             "#333",
+			AutoLine:          &autoLine,
+		}},
+	}}
+	data, err := json.Marshal(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseTemplate(data)
+	if err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	//Marshal会补上type
+	tpl.Layers[0].Image.Type = "image"
+	tpl.Layers[1].Text.Type = "text"
+	if !reflect.DeepEqual(parsed, tpl) {
+		t.Errorf("round trip got %+v, want %+v", parsed, tpl)
+	}
+	again, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("marshal again got %s, want %s", again, data)
+	}
+}
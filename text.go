@@ -13,13 +13,42 @@ import (
 	"io/ioutil"
 	"math"
 	"net/http"
+	"sync"
 )
 
 var (
-	SiYuanHeiYi     = func() IDrawString { return NewTTFDraw(mustLoadTTFBytes(fonts.SiYuanHeiTiTTF())) }
-	SiYuanHeiYiBold = func() IDrawString { return NewOTFDraw(mustLoadOTFBytes(fonts.SiYuanHeiTiOTFBold())) }
+	//内置字体只解析一次 所有Text共用
+	siYuanHeiYiFont     = onceTTF(fonts.SiYuanHeiTiTTF)
+	siYuanHeiYiBoldFont = onceOTF(fonts.SiYuanHeiTiOTFBold)
+
+	SiYuanHeiYi     = func() IDrawString { return NewTTFDraw(siYuanHeiYiFont()) }
+	SiYuanHeiYiBold = func() IDrawString { return NewOTFDraw(siYuanHeiYiBoldFont()) }
 )
 
+//返回一个只在第一次调用时解析字体的函数 可以在多个goroutine中使用
+func onceTTF(data func() []byte) func() *truetype.Font {
+	var once sync.Once
+	var f *truetype.Font
+	return func() *truetype.Font {
+		once.Do(func() {
+			f = mustLoadTTFBytes(data())
+		})
+		return f
+	}
+}
+
+//返回一个只在第一次调用时解析字体的函数 可以在多个goroutine中使用
+func onceOTF(data func() []byte) func() *opentype.Font {
+	var once sync.Once
+	var f *opentype.Font
+	return func() *opentype.Font {
+		once.Do(func() {
+			f = mustLoadOTFBytes(data())
+		})
+		return f
+	}
+}
+
 // SplitText 带有字符串长度的结构体
 type SplitText struct {
 	//字符串
@@ -260,13 +289,14 @@ type Text struct {
 	//是否自动分行
 	autoLine bool
 	lines    []string
+	//混合模式
+	blend BlendMode
 }
 
 func NewText(s string) *Text {
 	return &Text{
 		d:              SiYuanHeiYi(),
 		fontSize:       24,
-		dpi:            72,
 		textAlign:      "left",
 		color:          color.RGBA{A: 255},
 		s:              s,
@@ -279,7 +309,6 @@ func NewLineText(linesText []string) *Text {
 	return &Text{
 		d:         SiYuanHeiYi(),
 		fontSize:  24,
-		dpi:       72,
 		textAlign: "left",
 		color:     color.RGBA{A: 255},
 		lines:     linesText,
@@ -295,6 +324,16 @@ func (t *Text) SetTextAlign(textAlign string) *Text {
 	return t
 }
 
+// SetBlendMode 设置文字与下层图片的混合模式 默认BlendNormal
+func (t *Text) SetBlendMode(mode BlendMode) *Text {
+	t.blend = mode
+	return t
+}
+
+func (t *Text) blendMode() BlendMode {
+	return t.blend
+}
+
 // SetArea 设置文本放置的区域 默认整个图片 (x,y)起点坐标 w宽度 h长度
 func (t *Text) SetArea(x, y, w, h int) *Text {
 	t.area = image.Rect(x, y, x+w, y+h)
@@ -307,7 +346,7 @@ func (t *Text) SetFontSize(px int) *Text {
 	return t
 }
 
-// SetDpi 设置dpi 默认72
+// SetDpi 设置dpi 默认使用绘制上下文的dpi 没有绘制上下文时为72
 func (t *Text) SetDpi(dpi int) *Text {
 	t.dpi = dpi
 	return t
@@ -377,14 +416,95 @@ func (t *Text) SetOverHidden(overHidden bool) *Text {
 	return t
 }
 
-//实现FillItem接口
-func (t *Text) draw(dst draw.Image) (draw.Image, error) {
-	t.initDraw()
-	face, err := t.d.Face()
+// Draw 实现FillItem接口
+func (t *Text) Draw(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+	if err := rc.Err(); err != nil {
+		return nil, err
+	}
+	fontOptions, lines, err := t.layout(dst.Bounds(), rc)
 	if err != nil {
 		return nil, err
 	}
 
+	//绘制字体
+	clipDst := rc.ClipImage(dst)
+	target := clipDst
+	var layer *image.RGBA
+	linear := rc.linear()
+	if t.blend != BlendNormal || linear {
+		//先绘制到透明图层上 再按混合模式合成 在线性光中合成时文字边缘的亮度更准确
+		//图层只覆盖文字的范围
+		r, err := t.layerBounds(lines, fontOptions, clipDst.Bounds())
+		if err != nil {
+			return nil, err
+		}
+		layer = image.NewRGBA(r)
+		target = layer
+	}
+	for _, line := range lines {
+		if err = rc.Err(); err != nil {
+			return nil, err
+		}
+		err = t.d.DrawString(target, line.str, DrawOptions{
+			FontOptions: fontOptions,
+			Color:       t.color,
+			Dot:         line.dot,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if layer != nil {
+		composite(clipDst, layer.Rect, layer, layer.Rect.Min, nil, image.Point{}, draw.Over, t.blend, linear)
+	}
+	return dst, nil
+}
+
+//可以计算字符串绘制范围的字体
+type boundsDrawer interface {
+	stringBounds(s string, opts DrawOptions) (image.Rectangle, error)
+}
+
+//所有行绘制的范围 字体不能计算范围时为bounds
+func (t *Text) layerBounds(lines []textLine, opts FontOptions, bounds image.Rectangle) (image.Rectangle, error) {
+	b, ok := t.d.(boundsDrawer)
+	if !ok {
+		return bounds, nil
+	}
+	var r image.Rectangle
+	for _, line := range lines {
+		lr, err := b.stringBounds(line.str, DrawOptions{FontOptions: opts, Dot: line.dot})
+		if err != nil {
+			return r, err
+		}
+		r = r.Union(lr)
+	}
+	return r.Intersect(bounds), nil
+}
+
+//一行文字和它的基线起点
+type textLine struct {
+	str string
+	dot fixed.Point26_6
+}
+
+//绘制区域 未设置时为bounds
+func (t *Text) drawArea(bounds image.Rectangle) image.Rectangle {
+	area := t.area
+	if area.Max.X == 0 && area.Max.Y == 0 {
+		area.Max = bounds.Max
+	}
+	return area
+}
+
+//分行并计算每一行的绘制位置 bounds为目标图片的范围
+func (t *Text) layout(bounds image.Rectangle, rc *RenderContext) (FontOptions, []textLine, error) {
+	fontOptions := t.fontOptions(rc.dpi())
+	face, err := t.d.Face(fontOptions)
+	if err != nil {
+		return fontOptions, nil, err
+	}
+
 	//行高
 	lineHeight := t.lineHeight
 	if lineHeight <= 0 {
@@ -392,10 +512,7 @@ func (t *Text) draw(dst draw.Image) (draw.Image, error) {
 	}
 
 	//绘制区域
-	area := t.area
-	if area.Max.X == 0 && area.Max.Y == 0 {
-		area.Max = dst.Bounds().Max
-	}
+	area := t.drawArea(bounds)
 	maxWidth := float64(area.Max.X - area.Min.X)
 	maxHeight := float64(area.Max.Y - area.Min.Y)
 
@@ -408,10 +525,10 @@ func (t *Text) draw(dst draw.Image) (draw.Image, error) {
 		splitTextList, err = t.dealLineText(face, maxWidth, maxHeight, lineHeight)
 	}
 	if err != nil {
-		return nil, err
+		return fontOptions, nil, err
 	}
 
-	//绘制字体
+	lines := make([]textLine, len(splitTextList))
 	for i, text := range splitTextList {
 		//计算相对于绘制区域开始绘制位置
 		var startX float64
@@ -425,13 +542,12 @@ func (t *Text) draw(dst draw.Image) (draw.Image, error) {
 		}
 		//计算偏移量
 		deviation := int(float64(lineHeight)/2 - text.MaxY + (text.MaxY-text.MinY)/2)
-		t.d.SetDot(freetype.Pt(area.Min.X+int(startX), area.Min.Y+i*lineHeight+deviation))
-		err = t.d.DrawString(text.Str, dst)
-		if err != nil {
-			return nil, err
+		lines[i] = textLine{
+			str: text.Str,
+			dot: freetype.Pt(area.Min.X+int(startX), area.Min.Y+i*lineHeight+deviation),
 		}
 	}
-	return dst, nil
+	return fontOptions, lines, nil
 }
 func (t *Text) Copy() *Text {
 	return &Text{
@@ -449,6 +565,7 @@ func (t *Text) Copy() *Text {
 		lines:          t.lines,
 		autoLine:       t.autoLine,
 		overHidden:     t.overHidden,
+		blend:          t.blend,
 	}
 }
 
@@ -460,10 +577,15 @@ type CalcTextResult struct {
 	Width         int
 }
 
+// Calc 使用72dpi计算分行和尺寸 没有单独设置dpi并且绘制时使用其他dpi时需要使用CalcContext
 func (t *Text) Calc() (*CalcTextResult, error) {
-	t.initDraw()
+	return t.CalcContext(nil)
+}
+
+// CalcContext 使用绘制上下文的dpi计算分行和尺寸 结果与使用rc绘制时相同 rc为nil时使用72dpi
+func (t *Text) CalcContext(rc *RenderContext) (*CalcTextResult, error) {
 	//用于计算字体长度
-	face, err := t.d.Face()
+	face, err := t.d.Face(t.fontOptions(rc.dpi()))
 	if err != nil {
 		return nil, err
 	}
@@ -557,30 +679,45 @@ func (t *Text) Height() (int, error) {
 	}
 	return result.Height, nil
 }
-func (t *Text) initDraw() {
-	t.d.SetDpi(float64(t.dpi))
-	t.d.SetColor(t.color)
-	t.d.SetSize(float64(t.fontSize))
+//返回绘制使用的字体大小和dpi defaultDpi为没有单独设置dpi时使用的dpi
+func (t *Text) fontOptions(defaultDpi float64) FontOptions {
+	dpi := float64(t.dpi)
+	if dpi <= 0 {
+		dpi = defaultDpi
+	}
+	return FontOptions{
+		Size: float64(t.fontSize),
+		Dpi:  dpi,
+	}
 }
 
-type IDrawString interface {
-	SetSize(size float64)
-	SetDpi(dpi float64)
-	SetDot(p fixed.Point26_6)
-	SetColor(c color.RGBA)
-	DrawString(s string, dst draw.Image) error
-	Face() (font.Face, error)
+// FontOptions 字体大小和dpi
+type FontOptions struct {
+	//字体大小 单位像素
+	Size float64
+	Dpi  float64
 }
-type OTFDraw struct {
-	font     *opentype.Font
-	color    color.RGBA
-	fontSize float64
-	dpi      float64
-	dot      fixed.Point26_6
+
+// DrawOptions 每次绘制字符串时使用的参数
+type DrawOptions struct {
+	FontOptions
+	//字体颜色
+	Color color.Color
+	//基线起点
+	Dot fixed.Point26_6
 }
 
-func (o *OTFDraw) SetColor(c color.RGBA) {
-	o.color = c
+// IDrawString 字体 只保存不可变的字体数据 绘制参数在每次调用时传入
+// 实现需要能在多个goroutine中同时使用
+type IDrawString interface {
+	// DrawString 使用opts将字符串绘制到dst上
+	DrawString(dst draw.Image, s string, opts DrawOptions) error
+	// Face 返回用于计算字符串尺寸的font.Face
+	Face(opts FontOptions) (font.Face, error)
+}
+
+type OTFDraw struct {
+	font *opentype.Font
 }
 
 func NewOTFDraw(font *opentype.Font) *OTFDraw {
@@ -599,46 +736,37 @@ func NewOTFDrawFromHttp(url string) *OTFDraw {
 	}
 }
 
-func (o *OTFDraw) Face() (font.Face, error) {
-	return o.face()
+func (o *OTFDraw) Face(opts FontOptions) (font.Face, error) {
+	return o.face(opts)
 }
-func (o *OTFDraw) face() (font.Face, error) {
-	return opentype.NewFace(o.font, &opentype.FaceOptions{
-		Size:    o.fontSize,
-		DPI:     o.dpi,
-		Hinting: font.HintingNone,
+func (o *OTFDraw) face(opts FontOptions) (*cachedFace, error) {
+	key := faceKey{font: o.font, size: opts.Size, dpi: opts.Dpi, hinting: font.HintingNone}
+	return defaultFaceCache.get(key, func() (font.Face, error) {
+		return opentype.NewFace(o.font, &opentype.FaceOptions{
+			Size:    opts.Size,
+			DPI:     opts.Dpi,
+			Hinting: font.HintingNone,
+		})
 	})
 }
-func (o *OTFDraw) SetSize(size float64) {
-	o.fontSize = size
-}
-func (o *OTFDraw) SetDpi(dpi float64) {
-	o.dpi = dpi
-}
-func (o *OTFDraw) SetDot(p fixed.Point26_6) {
-	o.dot = p
-}
-func (o *OTFDraw) DrawString(s string, dst draw.Image) error {
-	face, err := o.face()
+func (o *OTFDraw) DrawString(dst draw.Image, s string, opts DrawOptions) error {
+	face, err := o.face(opts.FontOptions)
 	if err != nil {
 		return err
 	}
-	d := font.Drawer{
-		Dst:  dst,
-		Src:  image.NewUniform(o.color),
-		Face: face,
-		Dot:  o.dot,
-	}
-	d.DrawString(s)
+	face.drawString(dst, image.NewUniform(opts.Color), opts.Dot, s)
 	return nil
 }
+func (o *OTFDraw) stringBounds(s string, opts DrawOptions) (image.Rectangle, error) {
+	face, err := o.face(opts.FontOptions)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return face.stringBounds(opts.Dot, s), nil
+}
 
 type TTFDraw struct {
-	font     *truetype.Font
-	color    color.RGBA
-	fontSize float64
-	dpi      float64
-	dot      fixed.Point26_6
+	font *truetype.Font
 }
 
 func NewTTFDrawFromFile(path string) *TTFDraw {
@@ -656,47 +784,40 @@ func NewTTFDraw(font *truetype.Font) *TTFDraw {
 		font: font,
 	}
 }
-func (t *TTFDraw) SetColor(c color.RGBA) {
-	t.color = c
-}
-func (t *TTFDraw) SetSize(size float64) {
-	t.fontSize = size
-}
-func (t *TTFDraw) SetDpi(dpi float64) {
-	t.dpi = dpi
-}
-func (t *TTFDraw) SetDot(p fixed.Point26_6) {
-	t.dot = p
+func (t *TTFDraw) DrawString(dst draw.Image, s string, opts DrawOptions) error {
+	face, err := t.face(ttfDrawOptions(opts.FontOptions))
+	if err != nil {
+		return err
+	}
+	face.drawString(dst, image.NewUniform(opts.Color), opts.Dot, s)
+	return nil
 }
-func (t *TTFDraw) face() font.Face {
-	return truetype.NewFace(t.font, &truetype.Options{
-		Size:    t.fontSize,
-		DPI:     t.dpi,
-		Hinting: font.HintingNone,
+func (t *TTFDraw) stringBounds(s string, opts DrawOptions) (image.Rectangle, error) {
+	face, err := t.face(ttfDrawOptions(opts.FontOptions))
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return face.stringBounds(opts.Dot, s), nil
+}
+func (t *TTFDraw) Face(opts FontOptions) (font.Face, error) {
+	return t.face(opts)
+}
+//绘制时的字体选项 与之前使用freetype.Context绘制时相同 字体大小按pxToPoint换算
+//dpi不是72时绘制的大小与Face计算尺寸时使用的大小不同
+func ttfDrawOptions(opts FontOptions) FontOptions {
+	opts.Size = pxToPoint(opts.Size, opts.Dpi)
+	return opts
+}
+func (t *TTFDraw) face(opts FontOptions) (*cachedFace, error) {
+	key := faceKey{font: t.font, size: opts.Size, dpi: opts.Dpi, hinting: font.HintingNone}
+	return defaultFaceCache.get(key, func() (font.Face, error) {
+		return truetype.NewFace(t.font, &truetype.Options{
+			Size:    opts.Size,
+			DPI:     opts.Dpi,
+			Hinting: font.HintingNone,
+		}), nil
 	})
 }
-func (t *TTFDraw) DrawString(s string, dst draw.Image) error {
-	ctx := t.context()
-	ctx.SetDst(dst)
-	ctx.SetClip(dst.Bounds())
-	_, err := ctx.DrawString(s, t.dot)
-	return err
-}
-func (t *TTFDraw) context() *freetype.Context {
-	ctx := freetype.NewContext()
-	//设置要绘制的图像
-	ctx.SetSrc(image.NewUniform(t.color))
-	//设置字体大小
-	ctx.SetFontSize(pxToPoint(t.fontSize, t.dpi))
-	//设置字体
-	ctx.SetFont(t.font)
-	//设置dpi
-	ctx.SetDPI(t.dpi)
-	return ctx
-}
-func (t *TTFDraw) Face() (font.Face, error) {
-	return t.face(), nil
-}
 
 func LoadTTF(path string) (*truetype.Font, error) {
 	data, err := ioutil.ReadFile(path)
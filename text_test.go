@@ -0,0 +1,224 @@
+package imagedraw
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+func testTTF(t testing.TB) *TTFDraw {
+	t.Helper()
+	f, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTTFDraw(f)
+}
+
+func testOTF(t testing.TB) *OTFDraw {
+	t.Helper()
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewOTFDraw(f)
+}
+
+//有颜色的像素的外接矩形
+func inkBounds(img *image.RGBA) image.Rectangle {
+	r := image.Rectangle{}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] > 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+//dpi不是72时TTFDraw绘制的大小与之前使用freetype.Context时相同
+func TestTTFDrawSizeMatchesFreetypeContext(t *testing.T) {
+	d := testTTF(t)
+	for _, dpi := range []float64{72, 144} {
+		opts := DrawOptions{FontOptions: FontOptions{Size: 20, Dpi: dpi}, Color: color.RGBA{A: 255}, Dot: freetype.Pt(10, 100)}
+		got := image.NewRGBA(image.Rect(0, 0, 400, 150))
+		if err := d.DrawString(got, "Hxg", opts); err != nil {
+			t.Fatal(err)
+		}
+
+		want := image.NewRGBA(got.Rect)
+		ctx := freetype.NewContext()
+		ctx.SetSrc(image.NewUniform(opts.Color))
+		ctx.SetFontSize(pxToPoint(opts.Size, dpi))
+		ctx.SetFont(d.font)
+		ctx.SetDPI(dpi)
+		ctx.SetDst(want)
+		ctx.SetClip(want.Rect)
+		if _, err := ctx.DrawString("Hxg", opts.Dot); err != nil {
+			t.Fatal(err)
+		}
+
+		gb, wb := inkBounds(got), inkBounds(want)
+		diff := func(a, b int) bool { return a-b > 1 || b-a > 1 }
+		if diff(gb.Min.X, wb.Min.X) || diff(gb.Min.Y, wb.Min.Y) || diff(gb.Max.X, wb.Max.X) || diff(gb.Max.Y, wb.Max.Y) {
+			t.Errorf("dpi %v: ink bounds %v, freetype.Context %v", dpi, gb, wb)
+		}
+	}
+}
+
+//同一个字体在多个goroutine中同时绘制和计算尺寸 使用go test -race运行
+func TestSharedFontConcurrent(t *testing.T) {
+	for _, d := range []IDrawString{testTTF(t), testOTF(t)} {
+		//缓存为0时每次创建新的face 两种情况都需要并发安全
+		for _, cacheSize := range []int{64, 0} {
+			SetFaceCacheSize(cacheSize)
+			base := NewText("shared font 并发").SetFont(d).SetFontSize(24).SetArea(0, 0, 300, 100)
+			want := image.NewRGBA(image.Rect(0, 0, 300, 100))
+			if _, err := base.Copy().Draw(want, NewRenderContext(want)); err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			errs := make(chan error, 16)
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					text := base.Copy()
+					dst := image.NewRGBA(want.Rect)
+					if _, err := text.Draw(dst, NewRenderContext(dst)); err != nil {
+						errs <- err
+						return
+					}
+					if _, err := text.Calc(); err != nil {
+						errs <- err
+						return
+					}
+					//变换后的文字使用字形轮廓
+					rotated := image.NewRGBA(want.Rect)
+					if _, err := NewTransformed(text).Rotate(15, AnchorCenter).Draw(rotated, NewRenderContext(rotated)); err != nil {
+						errs <- err
+						return
+					}
+					if !bytes.Equal(dst.Pix, want.Pix) {
+						errs <- fmt.Errorf("%T cache %d: concurrent draw differs from serial draw", d, cacheSize)
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+		}
+	}
+	SetFaceCacheSize(64)
+}
+
+var benchParagraph = strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+
+func benchmarkText(b *testing.B, d IDrawString, cacheSize int, draw bool) {
+	SetFaceCacheSize(cacheSize)
+	defer SetFaceCacheSize(64)
+	dst := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	text := NewText(benchParagraph).SetFont(d).SetFontSize(16).SetLineHeight(20).SetArea(0, 0, 600, 400)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if draw {
+			_, err = text.Draw(dst, NewRenderContext(dst))
+		} else {
+			_, err = text.Calc()
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTTFDrawCached(b *testing.B)   { benchmarkText(b, testTTF(b), 64, true) }
+func BenchmarkTTFDrawUncached(b *testing.B) { benchmarkText(b, testTTF(b), 0, true) }
+func BenchmarkTTFCalcCached(b *testing.B)   { benchmarkText(b, testTTF(b), 64, false) }
+func BenchmarkTTFCalcUncached(b *testing.B) { benchmarkText(b, testTTF(b), 0, false) }
+func BenchmarkOTFDrawCached(b *testing.B)   { benchmarkText(b, testOTF(b), 64, true) }
+func BenchmarkOTFDrawUncached(b *testing.B) { benchmarkText(b, testOTF(b), 0, true) }
+func BenchmarkOTFCalcCached(b *testing.B)   { benchmarkText(b, testOTF(b), 64, false) }
+func BenchmarkOTFCalcUncached(b *testing.B) { benchmarkText(b, testOTF(b), 0, false) }
+
+//隐藏字体计算绘制范围的方法 图层使用整个画布
+type fullLayerFont struct {
+	IDrawString
+}
+
+//混合模式和线性光中只分配文字范围的图层 结果与使用整个画布的图层相同
+func TestTextLayerBounds(t *testing.T) {
+	defer SetColorSpace(DefaultColorSpace())
+	for _, d := range []IDrawString{testTTF(t), testOTF(t)} {
+		for _, dpi := range []int{72, 144} {
+			for _, cs := range []ColorSpace{ColorSpaceSRGB, ColorSpaceLinear} {
+				SetColorSpace(cs)
+				draw := func(d IDrawString) *image.RGBA {
+					dst := randomRGBA(300, 120, 4)
+					text := NewText("Hxg 混合 jpQ").SetFont(d).SetFontSize(28).SetDpi(dpi).SetArea(10, 5, 280, 110).
+						SetColor(color.RGBA{R: 200, G: 40, A: 255}).SetBlendMode(BlendMultiply)
+					if _, err := text.Draw(dst, NewRenderContext(dst)); err != nil {
+						t.Fatal(err)
+					}
+					return dst
+				}
+				got, want := draw(d), draw(fullLayerFont{d})
+				if !bytes.Equal(got.Pix, want.Pix) {
+					t.Errorf("%T dpi %d color space %d: text layer bounds cut off glyphs", d, dpi, cs)
+				}
+			}
+		}
+	}
+}
+
+//没有单独设置dpi时CalcContext使用绘制上下文的dpi 分行与绘制时相同
+func TestTextCalcContextDpi(t *testing.T) {
+	text := NewText("the quick brown fox jumps over the lazy dog").SetFont(testTTF(t)).SetFontSize(16).
+		SetLineHeight(40).SetMaxLineNum(10).SetArea(0, 0, 200, 400)
+	dst := image.NewRGBA(image.Rect(0, 0, 200, 400))
+	rc := NewRenderContext(dst)
+	rc.Dpi = 144
+
+	result, err := text.CalcContext(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, lines, err := text.layout(dst.Bounds(), rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.SplitTextList) != len(lines) {
+		t.Fatalf("CalcContext got %d lines, Draw %d", len(result.SplitTextList), len(lines))
+	}
+	for i, line := range lines {
+		if result.SplitTextList[i].Str != line.str {
+			t.Errorf("line %d: CalcContext got %q, Draw %q", i, result.SplitTextList[i].Str, line.str)
+		}
+	}
+
+	at72, err := text.Calc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(at72.SplitTextList) >= len(result.SplitTextList) {
+		t.Errorf("72dpi got %d lines, 144dpi %d lines", len(at72.SplitTextList), len(result.SplitTextList))
+	}
+	//单独设置的dpi优先
+	text.SetDpi(72)
+	if fixed, err := text.CalcContext(rc); err != nil || len(fixed.SplitTextList) != len(at72.SplitTextList) {
+		t.Errorf("SetDpi(72) got %v %v, want %d lines", fixed, err, len(at72.SplitTextList))
+	}
+}
@@ -0,0 +1,443 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Matrix 二维仿射变换矩阵 点(x,y)变换为(A*x+B*y+C, D*x+E*y+F) 坐标系与图片相同 y轴向下
+type Matrix struct {
+	A, B, C float64
+	D, E, F float64
+}
+
+// IdentityMatrix 单位矩阵 不做任何变换
+func IdentityMatrix() Matrix {
+	return Matrix{A: 1, E: 1}
+}
+
+// TranslateMatrix 平移
+func TranslateMatrix(x, y float64) Matrix {
+	return Matrix{A: 1, C: x, E: 1, F: y}
+}
+
+// ScaleMatrix 以原点为中心缩放
+func ScaleMatrix(sx, sy float64) Matrix {
+	return Matrix{A: sx, E: sy}
+}
+
+// RotateMatrix 以原点为中心顺时针旋转deg度
+func RotateMatrix(deg float64) Matrix {
+	sin, cos := math.Sincos(deg * math.Pi / 180)
+	return Matrix{A: cos, B: -sin, D: sin, E: cos}
+}
+
+// ShearMatrix 错切 x方向偏移sx*y y方向偏移sy*x
+func ShearMatrix(sx, sy float64) Matrix {
+	return Matrix{A: 1, B: sx, D: sy, E: 1}
+}
+
+// Multiply 返回先执行n再执行m的变换
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.B*n.D,
+		B: m.A*n.B + m.B*n.E,
+		C: m.A*n.C + m.B*n.F + m.C,
+		D: m.D*n.A + m.E*n.D,
+		E: m.D*n.B + m.E*n.E,
+		F: m.D*n.C + m.E*n.F + m.F,
+	}
+}
+
+// Translate 在m之后平移
+func (m Matrix) Translate(x, y float64) Matrix {
+	return TranslateMatrix(x, y).Multiply(m)
+}
+
+// Scale 在m之后缩放
+func (m Matrix) Scale(sx, sy float64) Matrix {
+	return ScaleMatrix(sx, sy).Multiply(m)
+}
+
+// Rotate 在m之后顺时针旋转deg度
+func (m Matrix) Rotate(deg float64) Matrix {
+	return RotateMatrix(deg).Multiply(m)
+}
+
+// Shear 在m之后错切
+func (m Matrix) Shear(sx, sy float64) Matrix {
+	return ShearMatrix(sx, sy).Multiply(m)
+}
+
+// Apply 变换一个点
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m.A*x + m.B*y + m.C, m.D*x + m.E*y + m.F
+}
+
+//所有值都是有限的数 旋转NaN、Inf度等会得到非有限的值
+func (m Matrix) finite() bool {
+	for _, v := range [6]float64{m.A, m.B, m.C, m.D, m.E, m.F} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Invert 逆矩阵 矩阵不可逆时第二个返回值为false
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.E - m.B*m.D
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.E / det,
+		B: -m.B / det,
+		C: (m.B*m.F - m.E*m.C) / det,
+		D: -m.D / det,
+		E: m.A / det,
+		F: (m.D*m.C - m.A*m.F) / det,
+	}, true
+}
+
+// Transform 对路径的所有点执行变换 返回一个新的路径
+func (p *Path) Transform(m Matrix) *Path {
+	return p.mapPoints(func(pt pathPoint) pathPoint {
+		x, y := m.Apply(pt.x, pt.y)
+		return pathPoint{x, y}
+	})
+}
+
+// TransformOptions 旋转和仿射变换的选项
+type TransformOptions struct {
+	//为true时画布保持原图大小 超出的部分剪切 否则扩大画布以容纳变换后的整张图片
+	Crop bool
+	//空白处填充的颜色 nil为透明
+	Background color.Color
+	//采样方式 零值为CubicConvolution 与不传选项相同 AreaAverage与BilinearInterpolation相同
+	ResizeType ResizeType
+	//采样和填充背景时计算颜色的空间 零值时使用图片对象上设置的颜色空间
+	ColorSpace ColorSpace
+}
+
+//未指定时与零值相同 扩大画布 透明背景 三次卷积插值
+func pickTransformOptions(opts []TransformOptions) TransformOptions {
+	if len(opts) == 0 {
+		return TransformOptions{}
+	}
+	return opts[0]
+}
+
+// Rotate 顺时针旋转deg度 90、180、270度时直接移动像素 不会模糊
+func (i *Image) Rotate(deg float64, opts ...TransformOptions) *Image {
+	o := i.transformOptions(opts)
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	src, ok := i.img.(*image.RGBA)
+	if !ok {
+		src = convertImage(i.img).(*image.RGBA)
+	}
+	var rotated *image.RGBA
+	switch deg {
+	case 0:
+		rotated = convertRGBA(src)
+	case 90:
+		rotated = rotate90RGBA(src)
+	case 180:
+		rotated = rotate180RGBA(src)
+	case 270:
+		rotated = rotate270RGBA(src)
+	default:
+		w, h := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+		m := TranslateMatrix(-w/2, -h/2).Rotate(deg).Translate(w/2, h/2)
+		return NewImage(affine(src, m, o))
+	}
+	if o.Crop && (rotated.Rect.Dx() != src.Rect.Dx() || rotated.Rect.Dy() != src.Rect.Dy()) {
+		//宽高互换后居中放在原图大小的画布上
+		canvas := image.NewRGBA(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()))
+		x := (canvas.Rect.Dx() - rotated.Rect.Dx()) / 2
+		y := (canvas.Rect.Dy() - rotated.Rect.Dy()) / 2
+		draw.Draw(canvas, rotated.Rect.Add(image.Pt(x, y)), rotated, image.Point{}, draw.Src)
+		rotated = canvas
+	}
+	return NewImage(fillBackground(rotated, o.Background, o.ColorSpace.linear()))
+}
+
+// FlipH 水平翻转并且返回一个新的对象
+func (i *Image) FlipH() *Image {
+	return NewImage(remapRGBA(i.img, false, func(x, y, w, h int) (int, int) { return w - 1 - x, y }))
+}
+
+// FlipV 垂直翻转并且返回一个新的对象
+func (i *Image) FlipV() *Image {
+	return NewImage(remapRGBA(i.img, false, func(x, y, w, h int) (int, int) { return x, h - 1 - y }))
+}
+
+// Transpose 沿左上到右下的对角线翻转并且返回一个新的对象 宽高互换
+func (i *Image) Transpose() *Image {
+	return NewImage(remapRGBA(i.img, true, func(x, y, w, h int) (int, int) { return y, x }))
+}
+
+// Affine 仿射变换并且返回一个新的对象 m将原图的坐标映射到新图的坐标
+//
+// 不剪切时画布为变换后图片的外接矩形 m中的平移不影响结果
+func (i *Image) Affine(m Matrix, opts ...TransformOptions) *Image {
+	return NewImage(affine(i.img, m, i.transformOptions(opts)))
+}
+
+//选项中没有指定颜色空间时使用图片对象上的颜色空间
+func (i *Image) transformOptions(opts []TransformOptions) TransformOptions {
+	o := pickTransformOptions(opts)
+	if o.ColorSpace == ColorSpaceDefault {
+		o.ColorSpace = i.colorSpace
+	}
+	return o
+}
+
+func rotate90RGBA(src *image.RGBA) *image.RGBA {
+	return remapRGBA(src, true, func(x, y, w, h int) (int, int) { return y, h - 1 - x })
+}
+
+func rotate180RGBA(src *image.RGBA) *image.RGBA {
+	return remapRGBA(src, false, func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y })
+}
+
+func rotate270RGBA(src *image.RGBA) *image.RGBA {
+	return remapRGBA(src, true, func(x, y, w, h int) (int, int) { return w - 1 - y, x })
+}
+
+//按坐标映射复制像素 新图中的(x,y)取原图中fn返回的像素 w、h为原图的宽高 swap为true时新图宽高互换
+func remapRGBA(img image.Image, swap bool, fn func(x, y, w, h int) (int, int)) *image.RGBA {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := sw, sh
+	if swap {
+		dw, dh = sh, sw
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	parallelRows(0, dh, dw, func(from, to int) {
+		for y := from; y < to; y++ {
+			j := dst.PixOffset(0, y)
+			for x := 0; x < dw; x++ {
+				sx, sy := fn(x, y, sw, sh)
+				i := src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+				copy(dst.Pix[j:j+4], src.Pix[i:i+4])
+				j += 4
+			}
+		}
+	})
+	return dst
+}
+
+//任意位置采样时使用的卷积核和半径
+func transformKernel(resizeType ResizeType) (float64, func(x float64) float64) {
+	switch resizeType {
+	case CubicConvolution:
+		return 2, cubicKernel(DefaultCubicA)
+	case Lanczos3:
+		return 3, lanczosKernel(3)
+	case MitchellNetravali:
+		return 2, bcSplineKernel(1.0/3, 1.0/3)
+	case CatmullRom:
+		return 2, bcSplineKernel(0, 0.5)
+	}
+	return 1, func(x float64) float64 {
+		return math.Max(0, 1-math.Abs(x))
+	}
+}
+
+//在原图的任意位置采样 坐标以原图左上角为原点 像素中心在+0.5处 每个goroutine使用单独的对象
+type rgbaSampler struct {
+	src     *image.RGBA
+	nearest bool
+	support float64
+	kernel  func(x float64) float64
+	//为true时原图以外取最近的边缘像素 否则视为透明
+	clamp bool
+	//为true时在线性光中计算
+	linear bool
+	wx, wy []float64
+}
+
+func newRGBASampler(src *image.RGBA, resizeType ResizeType, clamp, linear bool) *rgbaSampler {
+	support, kernel := transformKernel(resizeType)
+	taps := int(math.Ceil(support))*2 + 1
+	return &rgbaSampler{
+		src:     src,
+		nearest: resizeType == NearestNeighbor,
+		support: support,
+		kernel:  kernel,
+		clamp:   clamp,
+		linear:  linear,
+		wx:      make([]float64, taps),
+		wy:      make([]float64, taps),
+	}
+}
+
+//将(fx,fy)处的颜色写入dst 不在原图范围内时dst不变
+func (s *rgbaSampler) sample(dst []uint8, fx, fy float64) {
+	src := s.src
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if s.nearest {
+		ix, iy := int(math.Floor(fx)), int(math.Floor(fy))
+		if s.clamp {
+			ix, iy = clampIndex(ix, sw), clampIndex(iy, sh)
+		}
+		if ix >= 0 && ix < sw && iy >= 0 && iy < sh {
+			i := src.PixOffset(src.Rect.Min.X+ix, src.Rect.Min.Y+iy)
+			copy(dst, src.Pix[i:i+4])
+		}
+		return
+	}
+	support := s.support
+	u, v := fx-0.5, fy-0.5
+	if s.clamp {
+		//超出边缘之后的采样结果都与边缘相同
+		u = math.Max(-support, math.Min(u, float64(sw-1)+support))
+		v = math.Max(-support, math.Min(v, float64(sh-1)+support))
+	} else if u <= -support || v <= -support || u >= float64(sw-1)+support || v >= float64(sh-1)+support {
+		return
+	}
+	x0, y0 := int(math.Floor(u-support))+1, int(math.Floor(v-support))+1
+	wx, wy := s.wx, s.wy
+	var sumX, sumY float64
+	for k := range wx {
+		wx[k] = s.kernel(u - float64(x0+k))
+		wy[k] = s.kernel(v - float64(y0+k))
+		sumX += wx[k]
+		sumY += wy[k]
+	}
+	if sumX == 0 || sumY == 0 {
+		return
+	}
+	var sum [4]float64
+	for ky := range wy {
+		sy := y0 + ky
+		if s.clamp {
+			sy = clampIndex(sy, sh)
+		}
+		if sy < 0 || sy >= sh || wy[ky] == 0 {
+			continue
+		}
+		row := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+sy)
+		for kx := range wx {
+			sx := x0 + kx
+			if s.clamp {
+				sx = clampIndex(sx, sw)
+			}
+			if sx < 0 || sx >= sw || wx[kx] == 0 {
+				continue
+			}
+			weight := wx[kx] * wy[ky]
+			p := src.Pix[row+sx*4 : row+sx*4+4]
+			if s.linear {
+				c := linearPixel(p)
+				for k := range c {
+					sum[k] += c[k] * weight
+				}
+				continue
+			}
+			for c := 0; c < 4; c++ {
+				sum[c] += float64(p[c]) * weight
+			}
+		}
+	}
+	//原图以外的采样点权重计入总和 相当于透明像素
+	norm := sumX * sumY
+	if s.linear {
+		setSRGBPixel(dst, [4]float64{sum[0] / norm, sum[1] / norm, sum[2] / norm, sum[3] / norm})
+		return
+	}
+	a := clampUint8(sum[3] / norm)
+	dst[3] = a
+	for c := 0; c < 3; c++ {
+		dst[c] = minUint8(clampUint8(sum[c]/norm), a)
+	}
+}
+
+//sw*sh的图片经过m变换后四个角的外接矩形 去掉浮点误差后取整 返回左上角和宽高
+func affineBounds(sw, sh int, m Matrix) (float64, float64, int, int) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range [4][2]float64{{0, 0}, {float64(sw), 0}, {0, float64(sh)}, {float64(sw), float64(sh)}} {
+		x, y := m.Apply(c[0], c[1])
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	const eps = 1e-6
+	minX, minY = math.Floor(minX+eps), math.Floor(minY+eps)
+	return minX, minY, int(math.Ceil(maxX-eps) - minX), int(math.Ceil(maxY-eps) - minY)
+}
+
+//Rotate不剪切时返回的图片大小
+func rotatedSize(w, h int, deg float64) (int, int) {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	switch deg {
+	case 0, 180:
+		return w, h
+	case 90, 270:
+		return h, w
+	}
+	m := TranslateMatrix(-float64(w)/2, -float64(h)/2).Rotate(deg).Translate(float64(w)/2, float64(h)/2)
+	_, _, dw, dh := affineBounds(w, h, m)
+	return dw, dh
+}
+
+//仿射变换 对新图的每个像素中心用逆矩阵找到原图中的位置并采样 原图以外视为透明 边缘自然抗锯齿
+//
+//m含有NaN、Inf时无法计算画布大小 返回原图的副本 仍然填充背景
+func affine(img image.Image, m Matrix, o TransformOptions) draw.Image {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = convertImage(img).(*image.RGBA)
+	}
+	if !m.finite() {
+		dst := image.NewRGBA(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()))
+		draw.Draw(dst, dst.Rect, src, src.Rect.Min, draw.Src)
+		return fillBackground(dst, o.Background, o.ColorSpace.linear())
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := sw, sh
+	if !o.Crop {
+		var minX, minY float64
+		minX, minY, dw, dh = affineBounds(sw, sh, m)
+		m = m.Translate(-minX, -minY)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	inv, ok := m.Invert()
+	if !ok || sw == 0 || sh == 0 {
+		return fillBackground(dst, o.Background, o.ColorSpace.linear())
+	}
+	parallelRows(0, dh, dw, func(from, to int) {
+		sampler := newRGBASampler(src, o.ResizeType, false, o.ColorSpace.linear())
+		for y := from; y < to; y++ {
+			for x := 0; x < dw; x++ {
+				fx, fy := inv.Apply(float64(x)+0.5, float64(y)+0.5)
+				j := dst.PixOffset(x, y)
+				sampler.sample(dst.Pix[j:j+4], fx, fy)
+			}
+		}
+	})
+	return fillBackground(dst, o.Background, o.ColorSpace.linear())
+}
+
+//在背景色上绘制img background为nil时直接返回img
+func fillBackground(img *image.RGBA, background color.Color, linear bool) *image.RGBA {
+	if background == nil {
+		return img
+	}
+	canvas := image.NewRGBA(img.Rect)
+	draw.Draw(canvas, canvas.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+	composite(canvas, canvas.Rect, img, img.Rect.Min, nil, image.Point{}, draw.Over, BlendNormal, linear)
+	return canvas
+}
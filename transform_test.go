@@ -0,0 +1,169 @@
+package imagedraw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func rgbaOf(img *Image) *image.RGBA {
+	return img.img.(*image.RGBA)
+}
+
+func sameRGBA(a, b *image.RGBA) bool {
+	return a.Rect.Size() == b.Rect.Size() && bytes.Equal(a.Pix, b.Pix)
+}
+
+//90度的倍数和翻转直接移动像素 互相组合的结果逐字节相同
+func TestRotateRightAngles(t *testing.T) {
+	src := NewImage(randomRGBA(7, 4, 6))
+	r90 := src.Rotate(90)
+	if r90.Width() != 4 || r90.Height() != 7 {
+		t.Fatalf("rotate 90 size %dx%d", r90.Width(), r90.Height())
+	}
+	if !sameRGBA(rgbaOf(r90.Rotate(90).Rotate(90).Rotate(90)), rgbaOf(src)) {
+		t.Error("rotating 90 four times changed the image")
+	}
+	cases := []struct {
+		name string
+		a, b *Image
+	}{
+		{"90 = transpose + flipH", r90, src.Transpose().FlipH()},
+		{"270 = transpose + flipV", src.Rotate(270), src.Transpose().FlipV()},
+		{"180 = flipH + flipV", src.Rotate(180), src.FlipH().FlipV()},
+		{"-90 = 270", src.Rotate(-90), src.Rotate(270)},
+		{"450 = 90", src.Rotate(450), r90},
+		{"360 = 0", src.Rotate(360), src},
+		{"flipH twice", src.FlipH().FlipH(), src},
+		{"transpose twice", src.Transpose().Transpose(), src},
+	}
+	for _, c := range cases {
+		if !sameRGBA(rgbaOf(c.a), rgbaOf(c.b)) {
+			t.Errorf("%s: images differ", c.name)
+		}
+	}
+	if cropped := src.Rotate(90, TransformOptions{Crop: true}); cropped.Width() != 7 || cropped.Height() != 4 {
+		t.Errorf("cropped rotate 90 size %dx%d", cropped.Width(), cropped.Height())
+	}
+}
+
+func TestMatrixInvert(t *testing.T) {
+	m := TranslateMatrix(-5, 3).Rotate(30).Scale(2, 0.5).Shear(0.3, -0.1)
+	inv, ok := m.Invert()
+	if !ok {
+		t.Fatal("matrix not invertible")
+	}
+	for _, pt := range [][2]float64{{0, 0}, {10, -4}, {3.5, 7.25}} {
+		x, y := inv.Apply(m.Apply(pt[0], pt[1]))
+		if math.Abs(x-pt[0]) > 1e-9 || math.Abs(y-pt[1]) > 1e-9 {
+			t.Errorf("round trip %v got (%v,%v)", pt, x, y)
+		}
+	}
+	//y轴向下时顺时针旋转90度 x轴正方向转到y轴正方向
+	if x, y := RotateMatrix(90).Apply(1, 0); math.Abs(x) > 1e-9 || math.Abs(y-1) > 1e-9 {
+		t.Errorf("rotate 90 maps (1,0) to (%v,%v)", x, y)
+	}
+	if _, ok := ScaleMatrix(0, 1).Invert(); ok {
+		t.Error("singular matrix inverted")
+	}
+}
+
+//单位矩阵和整数平移在像素中心采样 结果与原图相同
+func TestAffineIdentity(t *testing.T) {
+	src := NewImage(randomRGBA(9, 6, 7))
+	for _, resizeType := range []ResizeType{BilinearInterpolation, NearestNeighbor, CatmullRom, Lanczos3, CubicConvolution} {
+		for _, m := range []Matrix{IdentityMatrix(), TranslateMatrix(3, -2)} {
+			got := src.Affine(m, TransformOptions{ResizeType: resizeType})
+			if !sameRGBA(rgbaOf(got), rgbaOf(src)) {
+				t.Errorf("resize type %d matrix %v: affine changed the image", resizeType, m)
+			}
+		}
+	}
+}
+
+//任意角度旋转90度与无损旋转相同 每个通道最多差1 宽高的差为偶数时旋转后的角在整数坐标上
+func TestAffineRotateMatchesRightAngle(t *testing.T) {
+	src := NewImage(randomRGBA(8, 6, 8))
+	w, h := float64(src.Width()), float64(src.Height())
+	m := TranslateMatrix(-w/2, -h/2).Rotate(90).Translate(w/2, h/2)
+	got, want := rgbaOf(src.Affine(m)), rgbaOf(src.Rotate(90))
+	if got.Rect.Size() != want.Rect.Size() {
+		t.Fatalf("size %v, want %v", got.Rect.Size(), want.Rect.Size())
+	}
+	for i := range got.Pix {
+		if absDiff(got.Pix[i], want.Pix[i]) > 1 {
+			t.Fatalf("byte %d got %d, want %d", i, got.Pix[i], want.Pix[i])
+		}
+	}
+}
+
+func TestRotateArbitrarySize(t *testing.T) {
+	src := NewBaseImage(40, 20)
+	rotated := src.Rotate(30)
+	//以中心旋转后四个角的外接矩形取整 x为-2.32到42.32 y为-8.66到28.66
+	if rotated.Width() != 46 || rotated.Height() != 38 {
+		t.Errorf("rotate 30 size %dx%d", rotated.Width(), rotated.Height())
+	}
+	if cropped := src.Rotate(30, TransformOptions{Crop: true}); cropped.Width() != 40 || cropped.Height() != 20 {
+		t.Errorf("cropped rotate 30 size %dx%d", cropped.Width(), cropped.Height())
+	}
+}
+
+func TestPathTransform(t *testing.T) {
+	p := MustParseSVGPath("M0 0 H10 V5 H0 Z").Transform(TranslateMatrix(3, 4).Scale(2, 2))
+	if b := p.bounds(); b != image.Rect(6, 8, 26, 18) {
+		t.Errorf("bounds %v", b)
+	}
+}
+
+//零值的选项与不传选项相同 只设置背景不会改变采样方式
+func TestTransformOptionsZeroValue(t *testing.T) {
+	//保存过的数值不能改变
+	if CubicConvolution != 0 || BilinearInterpolation != 1 || AreaAverage != 6 {
+		t.Fatal("ResizeType values changed")
+	}
+	src := NewImage(randomRGBA(30, 20, 9))
+	want := rgbaOf(src.Rotate(30))
+	if !sameRGBA(rgbaOf(src.Rotate(30, TransformOptions{})), want) {
+		t.Error("zero TransformOptions differs from no options")
+	}
+	if !sameRGBA(rgbaOf(src.Rotate(30, TransformOptions{ResizeType: CubicConvolution})), want) {
+		t.Error("CubicConvolution differs from the default")
+	}
+	withBackground := rgbaOf(src.Rotate(30, TransformOptions{Background: color.RGBA{G: 255, A: 255}}))
+	//完全被原图覆盖的像素与背景无关
+	for i := 0; i < len(want.Pix); i += 4 {
+		if want.Pix[i+3] == 255 && !bytes.Equal(want.Pix[i:i+4], withBackground.Pix[i:i+4]) {
+			t.Fatalf("pixel %d got %v, want %v", i/4, withBackground.Pix[i:i+4], want.Pix[i:i+4])
+		}
+	}
+}
+
+//非有限的角度和矩阵不改变图片
+func TestAffineNonFinite(t *testing.T) {
+	src := NewImage(randomRGBA(7, 5, 2))
+	want := rgbaOf(src)
+	for _, deg := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if got := rgbaOf(src.Rotate(deg)); !sameRGBA(got, want) {
+			t.Errorf("Rotate(%v) changed the image to %v", deg, got.Rect)
+		}
+	}
+	if got := rgbaOf(src.Affine(ScaleMatrix(math.Inf(1), 1))); !sameRGBA(got, want) {
+		t.Errorf("Affine with an infinite scale changed the image to %v", got.Rect)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	item := NewImage(randomRGBA(7, 5, 2)).SetArea(3, 4, 7, 5)
+	tr := NewTransformed(item).Rotate(math.NaN(), AnchorCenter)
+	if area := tr.drawArea(dst.Rect); area != image.Rect(3, 4, 10, 9) {
+		t.Errorf("got draw area %v, want the untransformed area", area)
+	}
+	if _, err := tr.Draw(dst, NewRenderContext(dst)); err != nil {
+		t.Fatal(err)
+	}
+	if ink := inkBounds(dst); !ink.In(image.Rect(3, 4, 10, 9)) || ink.Empty() {
+		t.Errorf("got ink bounds %v, want the item drawn untransformed", ink)
+	}
+}
@@ -0,0 +1,204 @@
+package imagedraw
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Transformed 变换后再绘制的元素 可以包装任意FillItem 如旋转的文字、倾斜的图片
+//
+// 文字使用内置的OTFDraw、TTFDraw字体时按字形轮廓变换后直接填充 边缘抗锯齿并且不会模糊
+// 其他元素先绘制到透明图层上 再按采样方式变换后按元素的混合模式和绘制方式合成
+// 绘制方式为draw.Src时替换变换后绘制区域的外接矩形 与Scene中偏移的图层相同
+type Transformed struct {
+	item FillItem
+	//依次执行的变换 area为元素的绘制区域
+	steps      []func(area image.Rectangle) Matrix
+	resizeType ResizeType
+}
+
+//可以返回绘制区域的元素 用于计算锚点的位置
+type areaItem interface {
+	drawArea(bounds image.Rectangle) image.Rectangle
+}
+
+// NewTransformed 包装一个元素 默认不做任何变换 非文字元素使用双线性插值
+func NewTransformed(item FillItem) *Transformed {
+	return &Transformed{
+		item:       item,
+		resizeType: BilinearInterpolation,
+	}
+}
+
+//在之前的变换之后执行m 以锚点为中心
+func (t *Transformed) around(anchor Anchor, m Matrix) *Transformed {
+	t.steps = append(t.steps, func(area image.Rectangle) Matrix {
+		ax, ay := anchor.ratio()
+		x := float64(area.Min.X) + float64(area.Dx())*ax
+		y := float64(area.Min.Y) + float64(area.Dy())*ay
+		return TranslateMatrix(x, y).Multiply(m).Multiply(TranslateMatrix(-x, -y))
+	})
+	return t
+}
+
+// Rotate 以元素绘制区域的锚点为中心顺时针旋转deg度
+func (t *Transformed) Rotate(deg float64, anchor Anchor) *Transformed {
+	return t.around(anchor, RotateMatrix(deg))
+}
+
+// RotateAt 以目标图片上的(x,y)为中心顺时针旋转deg度
+func (t *Transformed) RotateAt(deg, x, y float64) *Transformed {
+	return t.Transform(TranslateMatrix(-x, -y).Rotate(deg).Translate(x, y))
+}
+
+// Scale 以元素绘制区域的锚点为中心缩放
+func (t *Transformed) Scale(sx, sy float64, anchor Anchor) *Transformed {
+	return t.around(anchor, ScaleMatrix(sx, sy))
+}
+
+// Skew 以元素绘制区域的锚点为中心倾斜 degX、degY为横向、纵向倾斜的角度 与css的skew相同
+func (t *Transformed) Skew(degX, degY float64, anchor Anchor) *Transformed {
+	return t.around(anchor, ShearMatrix(math.Tan(degX*math.Pi/180), math.Tan(degY*math.Pi/180)))
+}
+
+// Transform 在之前的变换之后执行m m以目标图片的坐标为准
+func (t *Transformed) Transform(m Matrix) *Transformed {
+	t.steps = append(t.steps, func(area image.Rectangle) Matrix {
+		return m
+	})
+	return t
+}
+
+// SetResizeType 设置非文字元素变换时的采样方式 默认双线性插值
+func (t *Transformed) SetResizeType(resizeType ResizeType) *Transformed {
+	t.resizeType = resizeType
+	return t
+}
+
+//绘制区域 bounds为目标图片的范围 变换后为原区域的外接矩形 无法变换时为原区域
+func (t *Transformed) drawArea(bounds image.Rectangle) image.Rectangle {
+	area := itemArea(t.item, bounds)
+	m := t.matrix(bounds)
+	if !m.finite() {
+		return area
+	}
+	r := image.Rectangle{}
+	for k, c := range [4]image.Point{area.Min, {area.Max.X, area.Min.Y}, area.Max, {area.Min.X, area.Max.Y}} {
+		x, y := m.Apply(float64(c.X), float64(c.Y))
+		pt := image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x)), int(math.Ceil(y)))
+		if k == 0 {
+			r = pt
+		} else {
+			r = image.Rect(minInt(r.Min.X, pt.Min.X), minInt(r.Min.Y, pt.Min.Y), maxInt(r.Max.X, pt.Max.X), maxInt(r.Max.Y, pt.Max.Y))
+		}
+	}
+	return r
+}
+
+//元素的绘制区域 未知时为整个目标图片
+func itemArea(item FillItem, bounds image.Rectangle) image.Rectangle {
+	if a, ok := item.(areaItem); ok {
+		return a.drawArea(bounds)
+	}
+	return bounds
+}
+
+//所有变换合并后的矩阵
+func (t *Transformed) matrix(bounds image.Rectangle) Matrix {
+	area := itemArea(t.item, bounds)
+	m := IdentityMatrix()
+	for _, step := range t.steps {
+		m = step(area).Multiply(m)
+	}
+	return m
+}
+
+// Draw 实现FillItem接口
+func (t *Transformed) Draw(dst draw.Image, rc *RenderContext) (draw.Image, error) {
+	if err := rc.Err(); err != nil {
+		return nil, err
+	}
+	bounds := dst.Bounds()
+	m := t.matrix(bounds)
+	if !m.finite() {
+		//无法变换时按原样绘制
+		return t.item.Draw(dst, rc)
+	}
+	if text, ok := t.item.(*Text); ok {
+		if outliner, ok := text.d.(glyphOutliner); ok {
+			return t.drawText(dst, rc, text, outliner, m)
+		}
+	}
+
+	//先绘制到与目标图片相同大小的透明图层上 图层上不裁剪 变换后再裁剪
+	layerContext := *rc
+	layerContext.Clip = image.Rectangle{}
+	layer, err := t.item.Draw(image.NewRGBA(bounds), &layerContext)
+	if err != nil {
+		return nil, err
+	}
+	//affine的坐标以图片左上角为原点
+	origin := TranslateMatrix(float64(bounds.Min.X), float64(bounds.Min.Y))
+	local := TranslateMatrix(-float64(bounds.Min.X), -float64(bounds.Min.Y)).Multiply(m).Multiply(origin)
+	transformed := affine(layer, local, TransformOptions{Crop: true, ResizeType: t.resizeType, ColorSpace: rc.colorSpace(ColorSpaceDefault)})
+	//图层上的混合模式和绘制方式没有效果 变换后再按元素的设置合成
+	op, r := t.drawOp(), bounds
+	if op == draw.Src {
+		//只替换变换后的绘制区域
+		r = t.drawArea(bounds).Intersect(bounds)
+	}
+	composite(rc.ClipImage(dst), r, transformed, r.Min.Sub(bounds.Min), nil, image.Point{}, op, t.blendMode(), rc.linear())
+	return dst, nil
+}
+
+//被包装的元素的绘制方式 只区分draw.Src和draw.Over
+func (t *Transformed) drawOp() draw.Op {
+	if o, ok := t.item.(opItem); ok && o.drawOp() == draw.Src {
+		return draw.Src
+	}
+	return draw.Over
+}
+
+//被包装的元素的混合模式
+func (t *Transformed) blendMode() BlendMode {
+	if b, ok := t.item.(blendItem); ok {
+		return b.blendMode()
+	}
+	return BlendNormal
+}
+
+//变换文字的轮廓后填充
+func (t *Transformed) drawText(dst draw.Image, rc *RenderContext, text *Text, outliner glyphOutliner, m Matrix) (draw.Image, error) {
+	fontOptions, lines, err := text.layout(dst.Bounds(), rc)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPath()
+	for _, line := range lines {
+		if err = rc.Err(); err != nil {
+			return nil, err
+		}
+		linePath, err := outliner.outline(line.str, fontOptions, line.dot)
+		if err != nil {
+			return nil, err
+		}
+		p.Append(linePath)
+	}
+	fillPath(dst, rc, p.Transform(m), text.color, text.blend)
+	return dst, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
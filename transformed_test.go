@@ -0,0 +1,69 @@
+package imagedraw
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+//以绘制区域中心旋转90度的文字与整张图片旋转90度后的结果相同 边缘允许少量误差
+func TestTransformedTextRotate90(t *testing.T) {
+	for _, d := range []IDrawString{testTTF(t), testOTF(t)} {
+		newText := func() *Text {
+			return NewText("Hxg 90").SetFont(d).SetFontSize(24).SetColor(color.RGBA{A: 255}).SetArea(10, 40, 100, 40)
+		}
+		plain := image.NewRGBA(image.Rect(0, 0, 120, 120))
+		if _, err := newText().Draw(plain, NewRenderContext(plain)); err != nil {
+			t.Fatal(err)
+		}
+		want := rgbaOf(NewImage(plain).Rotate(90))
+
+		got := image.NewRGBA(plain.Rect)
+		if _, err := NewTransformed(newText()).Rotate(90, AnchorCenter).Draw(got, NewRenderContext(got)); err != nil {
+			t.Fatal(err)
+		}
+
+		gb, wb := inkBounds(got), inkBounds(want)
+		diff := func(a, b int) bool { return a-b > 1 || b-a > 1 }
+		if gb.Empty() || diff(gb.Min.X, wb.Min.X) || diff(gb.Min.Y, wb.Min.Y) || diff(gb.Max.X, wb.Max.X) || diff(gb.Max.Y, wb.Max.Y) {
+			t.Errorf("%T: ink bounds %v, want %v", d, gb, wb)
+		}
+		total, count := 0, 0
+		for i := 3; i < len(got.Pix); i += 4 {
+			if got.Pix[i] > 0 || want.Pix[i] > 0 {
+				total += absDiff(got.Pix[i], want.Pix[i])
+				count++
+			}
+		}
+		if count == 0 || total/count > 24 {
+			t.Errorf("%T: mean alpha difference %d over %d pixels", d, total/maxInt(count, 1), count)
+		}
+	}
+}
+
+//非文字元素变换后绘制在drawArea返回的外接矩形内
+func TestTransformedImageInsideDrawArea(t *testing.T) {
+	for _, deg := range []float64{30, 90, 135} {
+		tr := NewTransformed(uniformImage(30, 20, color.RGBA{R: 255, A: 255}).SetArea(20, 10, 30, 20)).Rotate(deg, AnchorCenter)
+		dst := image.NewRGBA(image.Rect(0, 0, 80, 60))
+		if _, err := tr.Draw(dst, NewRenderContext(dst)); err != nil {
+			t.Fatal(err)
+		}
+		area := tr.drawArea(dst.Rect)
+		ink := inkBounds(dst)
+		if ink.Empty() || !ink.In(area) {
+			t.Errorf("%v degrees: ink bounds %v outside draw area %v", deg, ink, area)
+		}
+		//外接矩形紧贴旋转后的图片
+		if ink.Dx() < area.Dx()-2 || ink.Dy() < area.Dy()-2 {
+			t.Errorf("%v degrees: ink bounds %v much smaller than draw area %v", deg, ink, area)
+		}
+		covered := 0.0
+		for i := 3; i < len(dst.Pix); i += 4 {
+			covered += float64(dst.Pix[i]) / 255
+		}
+		if covered < 600*0.95 || covered > 600*1.05 {
+			t.Errorf("%v degrees: covered %.1f pixels, want about 600", deg, covered)
+		}
+	}
+}